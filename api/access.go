@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/hex"
+	"sort"
+)
+
+// A postField reads one field of a post for Post.Get, returning false if the
+// post doesn't have it.
+type postField func(p *Post) (interface{}, bool)
+
+func postString(get func(p *Post) string) postField {
+	return func(p *Post) (interface{}, bool) { return get(p), true }
+}
+
+func postInt(get func(p *Post) int64) postField {
+	return func(p *Post) (interface{}, bool) { return get(p), true }
+}
+
+func postBool(get func(p *Post) bool) postField {
+	return func(p *Post) (interface{}, bool) { return get(p), true }
+}
+
+// fileValue reads a field of the post's file, if it has one.
+func fileValue(get func(f *File) interface{}) postField {
+	return func(p *Post) (interface{}, bool) {
+		if p.File == nil {
+			return nil, false
+		}
+		return get(p.File), true
+	}
+}
+
+// opValue reads a field of the thread that only its OP has.
+func opValue(get func(t *Thread) interface{}) postField {
+	return func(p *Post) (interface{}, bool) {
+		if p.Thread == nil || p.Thread.op() != p {
+			return nil, false
+		}
+		return get(p.Thread), true
+	}
+}
+
+// postFields maps the names accepted by Post.Get to the fields they read.
+var postFields = map[string]postField{
+	"no":           postInt(func(p *Post) int64 { return p.Id }),
+	"resto":        postInt(func(p *Post) int64 { return p.resto }),
+	"time":         postInt(func(p *Post) int64 { return p.Time.Unix() }),
+	"now":          postString(func(p *Post) string { return p.Now }),
+	"name":         postString(func(p *Post) string { return p.Name }),
+	"trip":         postString(func(p *Post) string { return p.Trip }),
+	"id":           postString(func(p *Post) string { return p.Special }),
+	"capcode":      postString(func(p *Post) string { return p.Capcode }),
+	"country":      postString(func(p *Post) string { return p.Country }),
+	"country_name": postString(func(p *Post) string { return p.CountryName }),
+	"board_flag":   postString(func(p *Post) string { return p.BoardFlag }),
+	"flag_name":    postString(func(p *Post) string { return p.FlagName }),
+	"email":        postString(func(p *Post) string { return p.Email }),
+	"sub":          postString(func(p *Post) string { return p.Subject }),
+	"com":          postString(func(p *Post) string { return p.CommentHTML() }),
+	"text":         postString(func(p *Post) string { return p.PlainText() }),
+	"board":        postString(func(p *Post) string { return p.board() }),
+	"sage":         postBool(func(p *Post) bool { return p.IsSage() }),
+
+	"tim":         fileValue(func(f *File) interface{} { return f.Id }),
+	"filename":    fileValue(func(f *File) interface{} { return f.Name }),
+	"ext":         fileValue(func(f *File) interface{} { return f.Ext }),
+	"fsize":       fileValue(func(f *File) interface{} { return int64(f.Size) }),
+	"md5":         fileValue(func(f *File) interface{} { return hex.EncodeToString(f.MD5) }),
+	"w":           fileValue(func(f *File) interface{} { return int64(f.Width) }),
+	"h":           fileValue(func(f *File) interface{} { return int64(f.Height) }),
+	"tn_w":        fileValue(func(f *File) interface{} { return int64(f.ThumbWidth) }),
+	"tn_h":        fileValue(func(f *File) interface{} { return int64(f.ThumbHeight) }),
+	"filedeleted": fileValue(func(f *File) interface{} { return f.Deleted }),
+	"spoiler":     fileValue(func(f *File) interface{} { return f.Spoiler }),
+
+	"sticky":       opValue(func(t *Thread) interface{} { return t.Sticky() }),
+	"closed":       opValue(func(t *Thread) interface{} { return t.Closed() }),
+	"archived":     opValue(func(t *Thread) interface{} { return t.Archived() }),
+	"replies":      opValue(func(t *Thread) interface{} { return int64(t.Replies()) }),
+	"images":       opValue(func(t *Thread) interface{} { return int64(t.Images()) }),
+	"bumplimit":    opValue(func(t *Thread) interface{} { return t.BumpLimit() }),
+	"imagelimit":   opValue(func(t *Thread) interface{} { return t.ImageLimit() }),
+	"semantic_url": opValue(func(t *Thread) interface{} { return t.op().semantic_url }),
+}
+
+// Get returns a field of the post by name, for code such as scripting layers
+// and user-written filters that refer to fields by name. Names are the keys of
+// the API's JSON ("no", "sub", "country_name", "fsize" and so on), plus
+// "board", "text" for the comment as plain text, and "sage". Values are
+// normalized: numbers are int64, flags are bool, times are UNIX timestamps,
+// MD5s are hex, and everything else is a string.
+//
+// The second result is false if there is no such field, or if the post
+// doesn't have it: file fields of a post without a file, or thread fields
+// like "replies" of a post that isn't its thread's OP.
+func (self *Post) Get(name string) (interface{}, bool) {
+	get, ok := postFields[name]
+	if !ok {
+		return nil, false
+	}
+	return get(self)
+}
+
+// PostFieldNames returns the names Post.Get accepts, in sorted order, e.g. for
+// checking a configuration file.
+func PostFieldNames() []string {
+	names := make([]string, 0, len(postFields))
+	for name := range postFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostGet(t *testing.T) {
+	thread, err := ParseThread(strings.NewReader(`{"posts":[
+		{"no":1,"resto":0,"time":1346971121,"sub":"hi","country":"US","country_name":"United States","replies":1,"sticky":1,
+		 "tim":100,"filename":"a","ext":".png","fsize":10,"md5":"AAAAAAAAAAAAAAAAAAAAAA==","w":4,"h":3},
+		{"no":2,"resto":1,"time":1346971130,"com":"a &gt; b","email":"sage"}
+	]}`), "g")
+	try(t, err)
+	op, reply := thread.Posts[0], thread.Posts[1]
+	get := func(p *Post, name string) interface{} {
+		v, ok := p.Get(name)
+		assert(t, ok, "The field should be present")
+		return v
+	}
+
+	assert(t, get(op, "no") == int64(1), "Post numbers should be int64")
+	assert(t, get(op, "country_name") == "United States", "Strings should be returned as they are")
+	assert(t, get(op, "time") == int64(1346971121), "Times should be UNIX timestamps")
+	assert(t, get(op, "fsize") == int64(10) && get(op, "w") == int64(4), "File sizes and dimensions should be int64")
+	assert(t, get(op, "md5") == "00000000000000000000000000000000", "MD5s should be hex")
+	assert(t, get(op, "sticky") == true && get(op, "replies") == int64(1), "Thread fields should be read from the OP")
+	assert(t, get(op, "board") == "g", "The board should be available")
+	assert(t, get(reply, "text") == "a > b", "The plain text should be available")
+	assert(t, get(reply, "sage") == true, "Sage should be available")
+
+	_, ok := reply.Get("fsize")
+	assert(t, !ok, "Posts without a file have no file fields")
+	_, ok = reply.Get("replies")
+	assert(t, !ok, "Replies have no thread fields")
+	_, ok = op.Get("nonsense")
+	assert(t, !ok, "Unknown fields should not be found")
+
+	for _, name := range PostFieldNames() {
+		_, known := postFields[name]
+		assert(t, known, "Every listed name should be accepted")
+	}
+}
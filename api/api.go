@@ -1,12 +1,22 @@
 // Package api pulls 4chan board and thread data from the JSON API into native Go data structures.
+//
+// The package also builds for GOOS=js GOARCH=wasm, so browser-based viewers
+// can use it. There, requests are made with the browser's fetch API by the
+// default HTTPClient. Functions that make requests block until the rate limit
+// allows them, so they must be called from their own goroutine rather than
+// directly from a JavaScript callback, which would stall the event loop.
 package api
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	pathpkg "path"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -18,14 +28,29 @@ var (
 	// If it is set to less than 10 seconds, it will be re-set to 10 seconds
 	// before being used.
 	UpdateCooldown time.Duration = 15 * time.Second
-	cooldown       <-chan time.Time
-	cooldownMutex  sync.Mutex
+	// A random amount of time of up to UpdateJitter is added to each
+	// UpdateCooldown, and waited before a thread's first Update, so that
+	// many threads updated on the same schedule don't all fire at once.
+	UpdateJitter time.Duration
+	// The time zone that Post.Time is given in. If it is nil, the local time
+	// zone is used. 4chan itself displays times in America/New_York, which
+	// is what Post.Now reflects.
+	Location *time.Location
+	// The client that every request is made with, including file downloads.
+	// It can be replaced to use a different transport, for example one that
+	// goes through a proxy, or a fetch-based one with custom options on
+	// js/wasm.
+	HTTPClient = http.DefaultClient
+
+	cooldown    <-chan time.Time
+	updateMutex sync.Mutex
 )
 
 const (
 	APIURL    = "a.4cdn.org"
 	ImageURL  = "i.4cdn.org"
 	StaticURL = "s.4cdn.org"
+	SiteURL   = "boards.4chan.org"
 )
 
 func prefix() string {
@@ -36,16 +61,23 @@ func prefix() string {
 	}
 }
 
-func get(base, path string, modify func(*http.Request) error) (*http.Response, error) {
-	url := prefix() + pathpkg.Join(base, path)
-	cooldownMutex.Lock()
-	if cooldown != nil {
-		<-cooldown
+// get fetches path from the server at base, wrapping any error in a
+// RequestError.
+func get(ctx context.Context, base, path string, modify func(*http.Request) error) (*http.Response, error) {
+	resp, err := request(ctx, base, path, modify)
+	if err != nil {
+		return nil, requestError(path, err)
 	}
+	return resp, nil
+}
+
+func request(ctx context.Context, base, path string, modify func(*http.Request) error) (*http.Response, error) {
+	url := prefix() + pathpkg.Join(base, path)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 	if modify != nil {
 		err = modify(req)
 		if err != nil {
@@ -53,58 +85,226 @@ func get(base, path string, modify func(*http.Request) error) (*http.Response, e
 		}
 	}
 
-	resp, err := http.DefaultClient.Do(req)
-	cooldown = time.After(1 * time.Second)
-	cooldownMutex.Unlock()
+	// only use the cached copy if the caller isn't making their own
+	// conditional request
+	var cached []byte
+	if ResponseCache != nil && req.Header.Get("If-Modified-Since") == "" {
+		if body, modified, ok := ResponseCache.Get(url); ok {
+			cached = body
+			req.Header.Set("If-Modified-Since", modified.UTC().Format(http.TimeFormat))
+		}
+	}
+
+	resp, err := do(ctx, req)
+	if err == ErrNotModified && cached != nil {
+		markCached(ctx)
+		return cachedResponse(req, cached), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ResponseCache != nil {
+		return cacheResponse(url, resp)
+	}
+	return resp, nil
+}
+
+// do sends a request once the rate limit allows it, turning unsuccessful
+// responses into errors.
+func do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	var a Attempt
+	resp, err := send(ctx, req, &a)
+	if OnAttempt != nil {
+		a.URL, a.Err = req.URL.String(), err
+		a.Attempt = countAttempt(a.URL, err)
+		OnAttempt(a)
+	}
 	return resp, err
 }
 
-func getDecode(base, path string, dest interface{}, modify func(*http.Request) error) error {
-	resp, err := get(base, path, modify)
+// send does the work of do, recording how long the request waited and took
+// in a.
+func send(ctx context.Context, req *http.Request, a *Attempt) (*http.Response, error) {
+	if err := breakerAllow(req.URL.Host); err != nil {
+		return nil, err
+	}
+	start := DefaultClock.Now()
+	if err := requestScheduler.acquire(ctx); err != nil {
+		breakerCancel(req.URL.Host)
+		a.Wait = DefaultClock.Now().Sub(start)
+		return nil, err
+	}
+	defer requestScheduler.release()
+	if cooldown != nil {
+		select {
+		case <-cooldown:
+		case <-ctx.Done():
+			breakerCancel(req.URL.Host)
+			a.Wait = DefaultClock.Now().Sub(start)
+			return nil, ctx.Err()
+		}
+	}
+	if RequestCoordinator != nil {
+		if err := RequestCoordinator.Wait(ctx); err != nil {
+			breakerCancel(req.URL.Host)
+			a.Wait = DefaultClock.Now().Sub(start)
+			return nil, err
+		}
+	}
+	sent := DefaultClock.Now()
+	a.Wait = sent.Sub(start)
+	reportBudgetDelay(req.URL.String(), start, sent)
+	lastSent = sent
+	recordSend(sent)
+	resp, err := HTTPClient.Do(req)
+	a.Duration = DefaultClock.Now().Sub(sent)
+	if resp != nil {
+		a.StatusCode = resp.StatusCode
+	}
+	cooldown = DefaultClock.After(requestInterval())
+	breakerRecord(req.URL.Host, resp, err)
+	if AuditLog != nil {
+		resp, err = audit(req, resp, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	recordResponse(ctx, req, resp)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotModified:
+		err = ErrNotModified
+	case http.StatusNotFound:
+		err = ErrNotFound
+	default:
+		err = &StatusError{resp.StatusCode, resp.Status}
+	}
+	resp.Body.Close()
+	return nil, err
+}
+
+func getDecode(ctx context.Context, base, path string, dest interface{}, modify func(*http.Request) error) error {
+	resp, err := get(ctx, base, path, modify)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	return json.NewDecoder(resp.Body).Decode(dest)
+	if err = decode(resp.Body, dest); err != nil {
+		return requestError(path, err)
+	}
+	return nil
 }
 
 // Direct mapping from the API's JSON to a Go type.
 type jsonPost struct {
-	No             int64            `json:"no"`             // Post number         1-9999999999999
-	Resto          int64            `json:"resto"`          // Reply to            0 (is thread), 1-999999999999
-	Sticky         int              `json:"sticky"`         // Stickied thread?    0 (no), 1 (yes)
-	Closed         int              `json:"closed"`         // Closed thread?      0 (no), 1 (yes)
-	Now            string           `json:"now"`            // Date and time       MM\/DD\/YY(Day)HH:MM (:SS on some boards)
-	Time           int64            `json:"time"`           // UNIX timestamp      UNIX timestamp
-	Name           string           `json:"name"`           // Name                text or empty
-	Trip           string           `json:"trip"`           // Tripcode            text (format: !tripcode!!securetripcode)
-	Id             string           `json:"id"`             // ID                  text (8 characters), Mod, Admin
-	Capcode        string           `json:"capcode"`        // Capcode             none, mod, admin, admin_highlight, developer
-	Country        string           `json:"country"`        // Country code        ISO 3166-1 alpha-2, XX (unknown)
-	CountryName    string           `json:"country_name"`   // Country name        text
-	Email          string           `json:"email"`          // Email               text or empty
-	Sub            string           `json:"sub"`            // Subject             text or empty
-	Com            string           `json:"com"`            // Comment             text (includes escaped HTML) or empty
-	Tim            int64            `json:"tim"`            // Renamed filename    UNIX timestamp + microseconds
-	FileName       string           `json:"filename"`       // Original filename   text
-	Ext            string           `json:"ext"`            // File extension      .jpg, .png, .gif, .pdf, .swf
-	Fsize          int              `json:"fsize"`          // File size           1-8388608
-	Md5            []byte           `json:"md5"`            // File MD5            byte slice
-	Width          int              `json:"w"`              // Image width         1-10000
-	Height         int              `json:"h"`              // Image height        1-10000
-	TnW            int              `json:"tn_w"`           // Thumbnail width     1-250
-	TnH            int              `json:"tn_h"`           // Thumbnail height    1-250
-	FileDeleted    int              `json:"filedeleted"`    // File deleted?       0 (no), 1 (yes)
-	Spoiler        int              `json:"spoiler"`        // Spoiler image?      0 (no), 1 (yes)
-	CustomSpoiler  int              `json:"custom_spoiler"` // Custom spoilers?	1-99
-	OmittedPosts   int              `json:"omitted_posts"`  // # replies omitted	1-10000
-	OmittedImages  int              `json:"omitted_images"` // # images omitted	1-10000
-	Replies        int              `json:"replies"`        // total # of replies	0-99999
-	Images         int              `json:"images"`         // total # of images	0-99999
-	BumpLimit      int              `json:"bumplimit"`      // bump limit?			0 (no), 1 (yes)
-	ImageLimit     int              `json:"imagelimit"`     // image limit?		0 (no), 1 (yes)
-	CapcodeReplies map[string][]int `json:"capcode_replies"`
-	LastModified   int64            `json:"last_modified"`
+	No             int64           `json:"no"`             // Post number         1-9999999999999
+	Resto          int64           `json:"resto"`          // Reply to            0 (is thread), 1-999999999999
+	Sticky         int             `json:"sticky"`         // Stickied thread?    0 (no), 1 (yes)
+	Closed         int             `json:"closed"`         // Closed thread?      0 (no), 1 (yes)
+	Now            string          `json:"now"`            // Date and time       MM\/DD\/YY(Day)HH:MM (:SS on some boards)
+	Time           int64           `json:"time"`           // UNIX timestamp      UNIX timestamp
+	Name           string          `json:"name"`           // Name                text or empty
+	Trip           string          `json:"trip"`           // Tripcode            text (format: !tripcode!!securetripcode)
+	Id             string          `json:"id"`             // ID                  text (8 characters), Mod, Admin
+	Capcode        string          `json:"capcode"`        // Capcode             none, mod, admin, admin_highlight, developer
+	Country        string          `json:"country"`        // Country code        ISO 3166-1 alpha-2, XX (unknown)
+	CountryName    string          `json:"country_name"`   // Country name        text
+	BoardFlag      string          `json:"board_flag"`     // Board flag code     text, on boards with board_flags
+	FlagName       string          `json:"flag_name"`      // Board flag name     text
+	Email          string          `json:"email"`          // Email               text or empty
+	Sub            string          `json:"sub"`            // Subject             text or empty
+	Com            jsonComment     `json:"com"`            // Comment             text (includes escaped HTML) or empty
+	Tim            int64           `json:"tim"`            // Renamed filename    UNIX timestamp + microseconds
+	FileName       string          `json:"filename"`       // Original filename   text
+	Ext            string          `json:"ext"`            // File extension      .jpg, .png, .gif, .pdf, .swf
+	Fsize          int             `json:"fsize"`          // File size           1-8388608
+	Md5            []byte          `json:"md5"`            // File MD5            byte slice
+	Width          int             `json:"w"`              // Image width         1-10000
+	Height         int             `json:"h"`              // Image height        1-10000
+	TnW            int             `json:"tn_w"`           // Thumbnail width     1-250
+	TnH            int             `json:"tn_h"`           // Thumbnail height    1-250
+	FileDeleted    int             `json:"filedeleted"`    // File deleted?       0 (no), 1 (yes)
+	Spoiler        int             `json:"spoiler"`        // Spoiler image?      0 (no), 1 (yes)
+	CustomSpoiler  int             `json:"custom_spoiler"` // Custom spoilers?	1-99
+	OmittedPosts   int             `json:"omitted_posts"`  // # replies omitted	1-10000
+	OmittedImages  int             `json:"omitted_images"` // # images omitted	1-10000
+	Replies        int             `json:"replies"`        // total # of replies	0-99999
+	Images         int             `json:"images"`         // total # of images	0-99999
+	BumpLimit      int             `json:"bumplimit"`      // bump limit?			0 (no), 1 (yes)
+	ImageLimit     int             `json:"imagelimit"`     // image limit?		0 (no), 1 (yes)
+	CapcodeReplies *CapcodeReplies `json:"capcode_replies"`
+	LastModified   int64           `json:"last_modified"`
+	Archived       int             `json:"archived"`     // Archived thread?    0 (no), 1 (yes)
+	ArchivedOn     int64           `json:"archived_on"`  // Time archived       UNIX timestamp
+	SemanticURL    string          `json:"semantic_url"` // Thread URL slug    text
+}
+
+// Errors from requests are wrapped in a RequestError, so they should be
+// checked for with errors.Is, e.g. errors.Is(err, ErrNotFound).
+var (
+	// ErrNotFound is returned when the requested board, page or thread
+	// doesn't exist, for example because the thread has been pruned.
+	ErrNotFound = errors.New("api: not found")
+	// ErrNotModified is returned by requests conditional on the resource
+	// having changed when it hasn't.
+	ErrNotModified = errors.New("api: not modified")
+)
+
+// ErrBoardNotFound is returned by LookupBoard, and by requests when
+// VerifyBoards is set, for boards that aren't in the board list.
+var ErrBoardNotFound = errors.New("api: board not found")
+
+// ErrEmptyThread is returned when parsing a thread that doesn't contain any
+// posts.
+var ErrEmptyThread = errors.New("api: thread has no posts")
+
+// A RequestError is returned when fetching something from the API fails. It
+// records what was being fetched, and wraps the cause, such as ErrNotFound, a
+// *StatusError, or an error from parsing the response, which can be checked
+// for with errors.Is and errors.As.
+type RequestError struct {
+	Path   string // the path that was requested, like "/a/thread/123.json"
+	Board  string // the board the request was for, if any
+	Thread int64  // the thread the request was for, if any
+	Err    error
+}
+
+func (self *RequestError) Error() string {
+	return "api: fetch " + self.Path + ": " + strings.TrimPrefix(self.Err.Error(), "api: ")
+}
+
+func (self *RequestError) Unwrap() error {
+	return self.Err
+}
+
+// requestError wraps err in a RequestError for path, unless it is one
+// already.
+func requestError(path string, err error) error {
+	if _, ok := err.(*RequestError); ok {
+		return err
+	}
+	e := &RequestError{Path: path, Err: err}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 1 {
+		e.Board = parts[0]
+	}
+	if len(parts) == 3 && parts[1] == "thread" {
+		e.Thread, _ = strconv.ParseInt(strings.TrimSuffix(parts[2], ".json"), 10, 64)
+	}
+	return e
+}
+
+// A StatusError is the cause of a RequestError when the API responds with an
+// unexpected status code. 404 and 304 responses are ErrNotFound and
+// ErrNotModified instead.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (self *StatusError) Error() string {
+	return "api: unexpected status " + self.Status
 }
 
 // A Post represents all of the attributes of a 4chan post, organized in a more directly usable fashion.
@@ -113,6 +313,7 @@ type Post struct {
 	Id           int64
 	Thread       *Thread
 	Time         time.Time
+	Now          string // the date and time as displayed on the site
 	Subject      string
 	LastModified int64
 
@@ -126,7 +327,12 @@ type Post struct {
 	image_limit    bool
 	sticky         bool
 	closed         bool
-	custom_spoiler int // the number of custom spoilers on a given board
+	archived       bool
+	archived_on    int64
+	custom_spoiler int    // the number of custom spoilers on a given board
+	semantic_url   string // the slug at the end of the thread's URL
+
+	resto int64 // the thread this post is a reply to, or 0 for an OP
 
 	// Poster info
 	Name    string
@@ -138,49 +344,161 @@ type Post struct {
 	// Country and CountryName are empty unless the board uses country info
 	Country     string
 	CountryName string
+	// BoardFlag and FlagName are the code and name of the flag the poster
+	// picked, on boards with their own set of flags (see Board.BoardFlags)
+	BoardFlag string
+	FlagName  string
 
 	// Message body
 	Comment string
 
+	comment_z []byte // the compressed comment of a compacted post
+
 	// File info if any, otherwise nil
 	File *File
 
 	// only when they do this on /q/
-	CapcodeReplies map[string][]int
+	CapcodeReplies *CapcodeReplies
+
+	// Annotations holds the results of enrichers, keyed by the name they
+	// were registered with. It is nil if there are none.
+	Annotations map[string]interface{}
+}
+
+func (self *Post) String() string {
+	var b strings.Builder
+	self.writeString(&b)
+	return b.String()
 }
 
-func (self *Post) String() (s string) {
-	s += fmt.Sprintf("#%d %s%s on %s:\n", self.Id, self.Name, self.Trip, self.Time.Format(time.RFC822))
+func (self *Post) writeString(b *strings.Builder) {
+	fmt.Fprintf(b, "#%d %s%s on %s:\n", self.Id, self.Name, self.Trip, self.Time.Format(time.RFC822))
 	if self.File != nil {
-		s += self.File.String()
+		b.WriteString(self.File.String())
 	}
-	s += self.Comment
-	return
+	b.WriteString(self.CommentHTML())
+}
+
+// IsThreadOP returns true if the post is the opening post of its thread.
+func (self *Post) IsThreadOP() bool {
+	return self.resto == 0
+}
+
+// LastModifiedTime returns LastModified as a time, or the zero time if it
+// isn't known. Only OP posts have it.
+func (self *Post) LastModifiedTime() time.Time {
+	if self.LastModified == 0 {
+		return time.Time{}
+	}
+	return post_time(self.LastModified)
+}
+
+// PreciseTime returns the time the post was made with millisecond precision
+// when it can be derived, which is useful for ordering posts made within the
+// same second. The renamed filename of an uploaded file is the millisecond
+// timestamp of the upload, so this only works for posts with files; for
+// anything else it is the same as Time.
+func (self *Post) PreciseTime() time.Time {
+	if self.File == nil {
+		return self.Time
+	}
+	t := time.Unix(0, self.File.Id*int64(time.Millisecond))
+	// make sure the filename really is a timestamp from around the time of
+	// the post
+	if d := t.Sub(self.Time); d < -time.Minute || d > time.Minute {
+		return self.Time
+	}
+	return t.In(self.Time.Location())
 }
 
 // ImageURL constructs and returns the URL of the attached image. Returns the
-// empty string if there is none.
+// empty string if there is none, and the URL of 4chan's "file deleted"
+// placeholder if the file was deleted.
 func (self *Post) ImageURL() string {
-	file := self.File
-	if file == nil {
+	if self == nil || self.File == nil {
 		return ""
 	}
+	if self.File.Deleted {
+		return FileDeletedURL(self.IsThreadOP())
+	}
 	return fmt.Sprintf("%s%s/%s/%d%s",
-		prefix(), ImageURL, self.Thread.Board, file.Id, file.Ext)
+		prefix(), ImageURL, self.board(), self.File.Id, self.File.Ext)
 }
 
 // ThumbURL constructs and returns the thumbnail URL of the attached image.
-// Returns the empty string if there is none.
+// Returns the empty string if there is none, and the URL of 4chan's "file
+// deleted" placeholder if the file was deleted.
 func (self *Post) ThumbURL() string {
-	file := self.File
-	if file == nil {
+	if self == nil || self.File == nil {
 		return ""
 	}
+	if self.File.Deleted {
+		return FileDeletedURL(self.IsThreadOP())
+	}
 	return fmt.Sprintf("%s%s/%s/%ds%s",
-		prefix(), ImageURL, self.Thread.Board, file.Id, ".jpg")
+		prefix(), ImageURL, self.board(), self.File.Id, ".jpg")
+}
+
+func (self *Post) board() string {
+	if self.Thread == nil {
+		return ""
+	}
+	return self.Thread.Board
+}
+
+// CapcodeReplies lists the IDs of the posts in a thread made by staff members,
+// grouped by capcode. It is only present on an OP post, and only on boards
+// where staff replies are tracked (e.g. /q/).
+type CapcodeReplies struct {
+	Admin     []int64 `json:"admin"`
+	Mod       []int64 `json:"mod"`
+	Developer []int64 `json:"developer"`
+	Manager   []int64 `json:"manager"`
 }
 
-// A File represents an uploaded file's metadata.
+// Get returns the IDs of the replies made under the given capcode ("admin",
+// "mod", "developer" or "manager").
+func (self *CapcodeReplies) Get(capcode string) []int64 {
+	if self == nil {
+		return nil
+	}
+	switch capcode {
+	case "admin", "admin_highlight":
+		return self.Admin
+	case "mod":
+		return self.Mod
+	case "developer":
+		return self.Developer
+	case "manager":
+		return self.Manager
+	}
+	return nil
+}
+
+// Capcode returns the capcode the post with the given ID was made under, or
+// the empty string if it is not a staff reply.
+func (self *CapcodeReplies) Capcode(id int64) string {
+	for _, capcode := range []string{"admin", "mod", "developer", "manager"} {
+		for _, reply := range self.Get(capcode) {
+			if reply == id {
+				return capcode
+			}
+		}
+	}
+	return ""
+}
+
+// Len returns the total number of staff replies.
+func (self *CapcodeReplies) Len() int {
+	if self == nil {
+		return 0
+	}
+	return len(self.Admin) + len(self.Mod) + len(self.Developer) + len(self.Manager)
+}
+
+// A File represents an uploaded file's metadata. If Deleted is set, the file
+// has been deleted from the post, and 4chan only gives part of the metadata
+// for it; Name and Ext may be empty, and there is no image to fetch.
 type File struct {
 	Id          int64  // Id is what 4chan renames images to (UNIX + microtime, e.g. 1346971121077)
 	Name        string // Original filename
@@ -193,6 +511,34 @@ type File struct {
 	ThumbHeight int
 	Deleted     bool
 	Spoiler     bool
+	Post        *Post // the post the file is attached to
+}
+
+// URL returns the URL of the file, like Post.ImageURL. It returns the empty
+// string for a nil File.
+func (self *File) URL() string {
+	if self == nil {
+		return ""
+	}
+	return self.post().ImageURL()
+}
+
+// ThumbURL returns the URL of the file's thumbnail, like Post.ThumbURL. It
+// returns the empty string for a nil File.
+func (self *File) ThumbURL() string {
+	if self == nil {
+		return ""
+	}
+	return self.post().ThumbURL()
+}
+
+// post returns the post the file is attached to, making up a stand-in if it
+// isn't known.
+func (self *File) post() *Post {
+	if self.Post != nil {
+		return self.Post
+	}
+	return &Post{File: self}
 }
 
 func (self *File) String() string {
@@ -206,11 +552,25 @@ func (self *Post) CountryFlagURL() string {
 	if self.Country == "" {
 		return ""
 	}
-	// lol /pol/
-	if self.Thread.Board == "pol" {
-		return fmt.Sprintf("%s://%s/image/country/troll/%s.gif", prefix(), StaticURL, self.Country)
+	return flagURL(CountryFlagPath, self.board(), self.Country)
+}
+
+// BoardFlagURL returns the URL of the icon of the board flag the poster
+// picked, if the board has its own flags and one was picked.
+func (self *Post) BoardFlagURL() string {
+	if self.BoardFlag == "" {
+		return ""
+	}
+	return flagURL(BoardFlagPath, self.board(), self.BoardFlag)
+}
+
+// FlagURL returns the URL of whichever flag the post has, preferring a board
+// flag over a country flag, or the empty string if it has neither.
+func (self *Post) FlagURL() string {
+	if url := self.BoardFlagURL(); url != "" {
+		return url
 	}
-	return fmt.Sprintf("%s://%s/image/country/%s.gif", prefix(), StaticURL, self.Country)
+	return self.CountryFlagURL()
 }
 
 // A Thread represents a thread of posts. It may or may not contain the actual replies.
@@ -221,12 +581,27 @@ type Thread struct {
 
 	date_recieved time.Time
 	cooldown      <-chan time.Time
+
+	// how to compact posts brought in by Update
+	compact CompactOptions
+
+	// where the thread was in bump order when it was fetched from an index
+	// page or the catalog; see PagePosition and BumpPosition
+	page, page_index, bump_position int
 }
 
 // GetIndex hits the API for an index of thread stubs from the given board and
 // page.
 func GetIndex(board string, page int) ([]*Thread, error) {
-	resp, err := get(APIURL, fmt.Sprintf("/%s/%d.json", board, page + 1), nil)
+	return getIndex(context.Background(), board, page)
+}
+
+func getIndex(ctx context.Context, board string, page int) ([]*Thread, error) {
+	if err := validPage(board, page); err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/%s/%d.json", board, page+1)
+	resp, err := get(ctx, APIURL, path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -234,12 +609,17 @@ func GetIndex(board string, page int) ([]*Thread, error) {
 
 	threads, err := ParseIndex(resp.Body, board)
 	if err != nil {
-		return nil, err
+		return nil, requestError(path, err)
 	}
 
-	now := time.Now()
-	for _, t := range threads {
+	now := DefaultClock.Now()
+	per_page := cachedPerPage(board)
+	for i, t := range threads {
 		t.date_recieved = now
+		t.page, t.page_index = page+1, i
+		if per_page > 0 {
+			t.bump_position = page*per_page + i + 1
+		}
 	}
 	return threads, err
 }
@@ -247,18 +627,40 @@ func GetIndex(board string, page int) ([]*Thread, error) {
 // GetThreads hits the API for a list of the thread IDs of all the active
 // threads on a given board.
 func GetThreads(board string) ([][]int64, error) {
+	if err := validBoard(board); err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/%s/threads.json", board)
+	resp, err := get(context.Background(), APIURL, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	threads, err := ParseThreads(resp.Body)
+	if err != nil {
+		return nil, requestError(path, err)
+	}
+	return threads, nil
+}
+
+// ParseThreads converts a JSON response for a board's thread list into the
+// thread IDs on each page, as returned by GetThreads.
+func ParseThreads(r io.Reader) ([][]int64, error) {
 	p := make([]struct {
 		Page    int `json:"page"`
 		Threads []struct {
 			No int64 `json:"no"`
 		} `json:"threads"`
 	}, 0, 10)
-	if err := getDecode(APIURL, fmt.Sprintf("/%s/threads.json", board), &p, nil); err != nil {
+	if err := decode(r, &p); err != nil {
 		return nil, err
 	}
 	n := make([][]int64, len(p))
 	for _, page := range p {
 		// Pages are 1 based in the json api
+		if page.Page < 1 || page.Page > len(n) {
+			return nil, fmt.Errorf("api: thread list has page %d out of %d", page.Page, len(n))
+		}
 		n[page.Page-1] = make([]int64, len(page.Threads))
 		for j, thread := range page.Threads {
 			n[page.Page-1][j] = thread.No
@@ -272,12 +674,22 @@ func GetThreads(board string) ([][]int64, error) {
 // updated, use an existing thread's Update() method if possible because that
 // uses If-Modified-Since in the request, which reduces unnecessary server
 // load.
+//
+// If CacheThreads is set, threads are remembered and fetching the same thread
+// again updates and returns the remembered Thread.
 func GetThread(board string, thread_id int64) (*Thread, error) {
-	return getThread(board, thread_id, time.Unix(0, 0))
+	if CacheThreads {
+		return cachedThread(ThreadKey{board, thread_id})
+	}
+	return getThread(context.Background(), board, thread_id, time.Unix(0, 0))
 }
 
-func getThread(board string, thread_id int64, stale_time time.Time) (*Thread, error) {
-	resp, err := get(APIURL, fmt.Sprintf("/%s/thread/%d.json", board, thread_id), func(req *http.Request) error {
+func getThread(ctx context.Context, board string, thread_id int64, stale_time time.Time) (*Thread, error) {
+	if err := validThread(board, thread_id); err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/%s/thread/%d.json", board, thread_id)
+	resp, err := get(ctx, APIURL, path, func(req *http.Request) error {
 		if stale_time.Unix() != 0 {
 			req.Header.Add("If-Modified-Since", stale_time.UTC().Format(http.TimeFormat))
 		}
@@ -289,13 +701,17 @@ func getThread(board string, thread_id int64, stale_time time.Time) (*Thread, er
 	defer resp.Body.Close()
 
 	thread, err := ParseThread(resp.Body, board)
-	thread.date_recieved = time.Now()
+	if err != nil {
+		return nil, requestError(path, err)
+	}
+	thread.date_recieved = DefaultClock.Now()
 
-	return thread, err
+	return thread, nil
 }
 
 // ParseIndex converts a JSON response for multiple threads into a native Go
-// data structure
+// data structure. Like all of the Parse functions, it returns an error rather
+// than panicking on malformed input.
 func ParseIndex(r io.Reader, board string) ([]*Thread, error) {
 	var t struct {
 		Threads []struct {
@@ -303,22 +719,15 @@ func ParseIndex(r io.Reader, board string) ([]*Thread, error) {
 		} `json:"threads"`
 	}
 
-	if err := json.NewDecoder(r).Decode(&t); err != nil {
+	if err := decode(r, &t); err != nil {
 		return nil, err
 	}
 
 	threads := make([]*Thread, len(t.Threads))
 	for i, json_thread := range t.Threads {
-		thread := &Thread{Posts: make([]*Post, len(t.Threads[i].Posts)), Board: board}
-		for k, v := range json_thread.Posts {
-			thread.Posts[k] = json_to_native(v, thread)
-			if v.No == 0 {
-				thread.OP = thread.Posts[k]
-			}
-		}
-		// TODO: fix this up
-		if thread.OP == nil {
-			thread.OP = thread.Posts[0]
+		thread, err := native_thread(json_thread.Posts, board)
+		if err != nil {
+			return nil, err
 		}
 		threads[i] = thread
 	}
@@ -327,28 +736,39 @@ func ParseIndex(r io.Reader, board string) ([]*Thread, error) {
 }
 
 // ParseThread converts a JSON response for one thread into a native Go data
-// structure.
+// structure. It returns an error rather than panicking on malformed input.
 func ParseThread(r io.Reader, board string) (*Thread, error) {
 	var t struct {
 		Posts []*jsonPost `json:"posts"`
 	}
 
-	if err := json.NewDecoder(r).Decode(&t); err != nil {
+	if err := decode(r, &t); err != nil {
 		return nil, err
 	}
 
-	thread := &Thread{Posts: make([]*Post, len(t.Posts)), Board: board}
-	for k, v := range t.Posts {
-		thread.Posts[k] = json_to_native(v, thread)
-		if v.No == 0 {
-			thread.OP = thread.Posts[k]
+	return native_thread(t.Posts, board)
+}
+
+func native_thread(posts []*jsonPost, board string) (*Thread, error) {
+	thread := &Thread{Posts: make([]*Post, 0, len(posts)), Board: board}
+	for _, v := range posts {
+		if v == nil {
+			continue
+		}
+		post := json_to_native(v, thread)
+		thread.Posts = append(thread.Posts, post)
+		if v.Resto == 0 && thread.OP == nil {
+			thread.OP = post
 		}
 	}
-	// TODO: fix this up
+	if len(thread.Posts) == 0 {
+		return nil, ErrEmptyThread
+	}
+	// fall back to the first post if the OP is missing for some reason
 	if thread.OP == nil {
 		thread.OP = thread.Posts[0]
 	}
-
+	enrich(thread)
 	return thread, nil
 }
 
@@ -357,17 +777,23 @@ func json_to_native(v *jsonPost, thread *Thread) *Post {
 		Id:             v.No,
 		sticky:         v.Sticky == 1,
 		closed:         v.Closed == 1,
-		Time:           time.Unix(v.Time, 0),
+		archived:       v.Archived == 1,
+		archived_on:    v.ArchivedOn,
+		Time:           post_time(v.Time),
+		Now:            v.Now,
 		Name:           v.Name,
 		Trip:           v.Trip,
 		Special:        v.Id,
 		Capcode:        v.Capcode,
 		Country:        v.Country,
 		CountryName:    v.CountryName,
+		BoardFlag:      v.BoardFlag,
+		FlagName:       v.FlagName,
 		Email:          v.Email,
 		Subject:        v.Sub,
-		Comment:        v.Com,
+		Comment:        string(v.Com),
 		custom_spoiler: v.CustomSpoiler,
+		semantic_url:   v.SemanticURL,
 		replies:        v.Replies,
 		images:         v.Images,
 		omitted_posts:  v.OmittedPosts,
@@ -377,8 +803,9 @@ func json_to_native(v *jsonPost, thread *Thread) *Post {
 		Thread:         thread,
 		CapcodeReplies: v.CapcodeReplies,
 		LastModified:   v.LastModified,
+		resto:          v.Resto,
 	}
-	if len(v.FileName) > 0 {
+	if len(v.FileName) > 0 || v.FileDeleted == 1 {
 		p.File = &File{
 			Id:          v.Tim,
 			Name:        v.FileName,
@@ -391,143 +818,291 @@ func json_to_native(v *jsonPost, thread *Thread) *Post {
 			ThumbHeight: v.TnH,
 			Deleted:     v.FileDeleted == 1,
 			Spoiler:     v.Spoiler == 1,
+			Post:        p,
 		}
 	}
+	if ParseFields != AllFields {
+		p.selectFields()
+	}
 	return p
 }
 
+func post_time(unix int64) time.Time {
+	t := time.Unix(unix, 0)
+	if Location != nil {
+		t = t.In(Location)
+	}
+	return t
+}
+
+// Jitter returns a random duration from 0 up to max, for spreading out
+// requests that would otherwise be made at the same time.
+func Jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
 // Update an existing thread in-place.
 func (self *Thread) Update() (new_posts, deleted_posts int, err error) {
-	cooldownMutex.Lock()
+	updateMutex.Lock()
+	if self.cooldown == nil && UpdateJitter > 0 {
+		self.cooldown = DefaultClock.After(Jitter(UpdateJitter))
+	}
 	if self.cooldown != nil {
 		<-self.cooldown
 	}
 	var thread *Thread
-	thread, err = getThread(self.Board, self.Id(), self.date_recieved)
+	thread, err = getThread(context.Background(), self.Board, self.Id(), self.date_recieved)
 	if UpdateCooldown < 10*time.Second {
 		UpdateCooldown = 10 * time.Second
 	}
-	self.cooldown = time.After(UpdateCooldown)
-	cooldownMutex.Unlock()
+	self.cooldown = DefaultClock.After(UpdateCooldown + Jitter(UpdateJitter))
+	updateMutex.Unlock()
+	if errors.Is(err, ErrNotModified) {
+		return 0, 0, nil
+	}
 	if err != nil {
 		return 0, 0, err
 	}
+	new_posts, deleted_posts = diffPosts(self.Posts, thread.Posts)
+	if self.compact != (CompactOptions{}) {
+		for _, post := range thread.Posts {
+			post.compact(self.compact)
+		}
+	}
+	self.Posts = thread.Posts
+	self.OP = thread.OP
+	self.date_recieved = thread.date_recieved
+	for _, post := range self.Posts {
+		post.Thread = self
+	}
+	return
+}
+
+// diffPosts counts the posts that were appended to and deleted from a thread
+// going from old to new.
+func diffPosts(old, new []*Post) (new_posts, deleted_posts int) {
 	var a, b int
 	// traverse both threads in parallel to check for deleted/appended posts
-	for a, b = 0, 0; a < len(self.Posts); a, b = a+1, b+1 {
-		if self.Posts[a].Id == thread.Posts[b].Id {
+	for a, b = 0, 0; a < len(old) && b < len(new); a, b = a+1, b+1 {
+		if old[a].Id == new[b].Id {
 			continue
 		}
 		// a post has been deleted, go back one to compare with the next
 		b--
 		deleted_posts++
 	}
-	new_posts = len(thread.Posts) - b
-	self.Posts = thread.Posts
+	// anything left over at the end of the old thread is gone too
+	deleted_posts += len(old) - a
+	new_posts = len(new) - b
 	return
 }
 
-// Id returns the thread OP's post ID.
+// Files returns the files attached to posts in the thread, in order. Each
+// File's Post is the post it was attached to.
+func (self *Thread) Files() []*File {
+	files := make([]*File, 0, len(self.Posts))
+	for _, post := range self.Posts {
+		if post.File != nil {
+			files = append(files, post.File)
+		}
+	}
+	return files
+}
+
+// FilePosts returns the posts in the thread that have a file attached.
+func (self *Thread) FilePosts() []*Post {
+	posts := make([]*Post, 0, len(self.Posts))
+	for _, post := range self.Posts {
+		if post.File != nil {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+// op returns the thread's OP, or an empty post if there isn't one, so that the
+// getters below are safe to use on nil or incomplete threads.
+func (self *Thread) op() *Post {
+	if self == nil || self.OP == nil {
+		return &Post{}
+	}
+	return self.OP
+}
+
+// Id returns the thread OP's post ID, or 0 if the thread has no OP.
 func (self *Thread) Id() int64 {
-	return self.OP.Id
+	return self.op().Id
 }
 
-func (self *Thread) String() (s string) {
+func (self *Thread) String() string {
+	if self == nil {
+		return ""
+	}
+	var b strings.Builder
 	for _, post := range self.Posts {
-		s += post.String() + "\n\n"
+		post.writeString(&b)
+		b.WriteString("\n\n")
 	}
-	return
+	return b.String()
 }
 
 // Replies returns the number of replies the thread OP has.
 func (self *Thread) Replies() int {
-	return self.OP.replies
+	return self.op().replies
 }
 
 // Images returns the number of images in the thread.
 func (self *Thread) Images() int {
-	return self.OP.images
+	return self.op().images
 }
 
 // OmittedPosts returns the number of posts omitted in a thread list overview.
 func (self *Thread) OmittedPosts() int {
-	return self.OP.omitted_posts
+	return self.op().omitted_posts
 }
 
 // OmittedImages returns the number of image posts omitted in a thread list overview.
 func (self *Thread) OmittedImages() int {
-	return self.OP.omitted_images
+	return self.op().omitted_images
 }
 
 // BumpLimit returns true if the thread is at its bump limit, or false otherwise.
 func (self *Thread) BumpLimit() bool {
-	return self.OP.bump_limit
+	return self.op().bump_limit
 }
 
 // ImageLimit returns true if the thread can no longer accept image posts, or false otherwise.
 func (self *Thread) ImageLimit() bool {
-	return self.OP.image_limit
+	return self.op().image_limit
 }
 
 // Closed returns true if the thread is closed for replies, or false otherwise.
 func (self *Thread) Closed() bool {
-	return self.OP.closed
+	return self.op().closed
 }
 
 // Sticky returns true if the thread is stickied, or false otherwise.
 func (self *Thread) Sticky() bool {
-	return self.OP.sticky
+	return self.op().sticky
+}
+
+// Archived returns true if the thread has been moved to the board's archive,
+// after which it can't be replied to and won't change any more.
+func (self *Thread) Archived() bool {
+	return self.op().archived
+}
+
+// ArchivedTime returns when the thread was archived, or the zero time if it
+// hasn't been.
+func (self *Thread) ArchivedTime() time.Time {
+	if on := self.op().archived_on; on != 0 {
+		return post_time(on)
+	}
+	return time.Time{}
 }
 
 // CustomSpoiler returns the ID of its custom spoiler image, if there is one.
 func (self *Thread) CustomSpoiler() int {
-	return self.OP.custom_spoiler
+	return self.op().custom_spoiler
 }
 
 // CustomSpoilerURL builds and returns the URL of the custom spoiler image, or
-// an empty string if none exists.
+// an empty string if none exists. The ssl argument is ignored; the scheme
+// follows SSL.
+//
+// Deprecated: use Board.CustomSpoilerURLs, which lists every custom spoiler
+// of a board.
 func (self *Thread) CustomSpoilerURL(id int, ssl bool) string {
-	if id > self.OP.custom_spoiler {
+	if id < 1 || id > self.op().custom_spoiler {
 		return ""
 	}
-	return fmt.Sprintf("%s://%s/image/spoiler-%s%d.png", prefix(), StaticURL, self.Board, id)
+	return customSpoilerURL(self.Board, id)
+}
+
+func customSpoilerURL(board string, id int) string {
+	return staticURL(fmt.Sprintf("/image/spoiler-%s%d.png", board, id))
 }
 
-// A Board is the name and title of a single board.
+// A Board is the name, title and settings of a single board.
 type Board struct {
-	Board string `json:"board"`
-	Title string `json:"title"`
+	Board   string `json:"board"`
+	Title   string `json:"title"`
+	Pages   int    `json:"pages"`    // the number of index pages
+	PerPage int    `json:"per_page"` // the number of threads on each index page
+	// the number of custom spoiler images the board has, numbered from 1
+	CustomSpoilers int `json:"custom_spoilers"`
+	// the flags posters can pick from on boards with their own flags, by
+	// code
+	BoardFlags map[string]string `json:"board_flags"`
 }
 
-// Board names/descriptions will be cached here after a call to LookupBoard or GetBoards
+// CustomSpoilerURLs returns the URLs of the board's custom spoiler images, in
+// order, or nil if it only uses the default spoiler.
+func (self Board) CustomSpoilerURLs() []string {
+	if self.CustomSpoilers <= 0 {
+		return nil
+	}
+	urls := make([]string, self.CustomSpoilers)
+	for i := range urls {
+		urls[i] = customSpoilerURL(self.Board, i+1)
+	}
+	return urls
+}
+
+// Board names/descriptions will be cached here after a call to LookupBoard or
+// GetBoards. It should not be modified while requests are being made.
 var Boards []Board
 
-// LookupBoard returns the Board corresponding to the board name (without slashes)
+// LookupBoard returns the Board corresponding to the board name (without
+// slashes), or ErrBoardNotFound if there is no such board.
 func LookupBoard(name string) (Board, error) {
-	if Boards == nil {
-		_, err := GetBoards()
-		if err != nil {
-			return Board{}, fmt.Errorf("Board '%s' not found: %v", name, err)
+	boards := cachedBoards()
+	if boards == nil {
+		var err error
+		if boards, err = GetBoards(); err != nil {
+			return Board{}, fmt.Errorf("api: looking up board %q: %w", name, err)
 		}
 	}
-	for _, b := range Boards {
+	for _, b := range boards {
 		if name == b.Board {
 			return b, nil
 		}
 	}
-	return Board{}, fmt.Errorf("Board '%s' not found", name)
+	return Board{}, ErrBoardNotFound
 }
 
-// Get the list of boards.
+// Get the list of boards. If the list has changed since it was last fetched,
+// OnBoardsChange is called with the changes.
 func GetBoards() ([]Board, error) {
+	return getBoards(context.Background())
+}
+
+func getBoards(ctx context.Context) ([]Board, error) {
+	resp, err := get(ctx, APIURL, "/boards.json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	boards, err := ParseBoards(resp.Body)
+	if err != nil {
+		return nil, requestError("/boards.json", err)
+	}
+	setBoards(boards)
+	return boards, nil
+}
+
+// ParseBoards converts a JSON response for the board list into native Go
+// data structures. Unlike GetBoards, it doesn't update Boards.
+func ParseBoards(r io.Reader) ([]Board, error) {
 	var b struct {
 		Boards []Board `json:"boards"`
 	}
-	err := getDecode(APIURL, "/boards.json", &b, nil)
-	if err != nil {
+	if err := decode(r, &b); err != nil {
 		return nil, err
 	}
-	Boards = b.Boards
 	return b.Boards, nil
 }
 
@@ -544,31 +1119,61 @@ type catalog []struct {
 
 // GetCatalog hits the API for a catalog listing of a board.
 func GetCatalog(board string) (Catalog, error) {
-	if len(board) == 0 {
-		return nil, fmt.Errorf("api: GetCatalog: No board name given")
+	return getCatalog(context.Background(), board)
+}
+
+func getCatalog(ctx context.Context, board string) (Catalog, error) {
+	if err := validBoard(board); err != nil {
+		return nil, err
 	}
-	var c catalog
-	err := getDecode(APIURL, fmt.Sprintf("/%s/catalog.json", board), &c, nil)
+	path := fmt.Sprintf("/%s/catalog.json", board)
+	resp, err := get(ctx, APIURL, path, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+	cat, err := ParseCatalog(resp.Body, board)
+	if err != nil {
+		return nil, requestError(path, err)
+	}
+	return cat, nil
+}
 
+// ParseCatalog converts a JSON response for a board's catalog into a native
+// Go data structure.
+func ParseCatalog(r io.Reader, board string) (Catalog, error) {
+	var c catalog
+	if err := decode(r, &c); err != nil {
+		return nil, err
+	}
+	return native_catalog(c, board), nil
+}
+
+func native_catalog(c catalog, board string) Catalog {
 	cat := make(Catalog, len(c))
+	bump_position := 0
 	for i, page := range c {
 		extracted := struct {
 			Page    int
 			Threads []*Thread
-		}{page.Page, make([]*Thread, len(page.Threads))}
-		for j, post := range page.Threads {
+		}{page.Page, make([]*Thread, 0, len(page.Threads))}
+		for _, post := range page.Threads {
+			if post == nil {
+				continue
+			}
 			thread := &Thread{Posts: make([]*Post, 1), Board: board}
 			post := json_to_native(post, thread)
 			thread.Posts[0] = post
-			extracted.Threads[j] = thread
+			thread.page_index = len(extracted.Threads)
+			extracted.Threads = append(extracted.Threads, thread)
 			if thread.OP == nil {
 				thread.OP = thread.Posts[0]
 			}
+			bump_position++
+			thread.page, thread.bump_position = page.Page, bump_position
+			enrich(thread)
 		}
 		cat[i] = extracted
 	}
-	return cat, nil
+	return cat
 }
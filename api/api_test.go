@@ -2,7 +2,9 @@ package api
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func try(t *testing.T, err error) {
@@ -27,14 +29,87 @@ func TestParseThread(t *testing.T) {
 
 	assert(t, thread.OP.Name == "Anonymous", "OP's name should be Anonymous")
 	assert(t, thread.Id() == 3856791, "Thread id should be 3856791")
+	assert(t, thread.OP.IsThreadOP() && !thread.Posts[1].IsThreadOP(), "Only the first post should be the OP")
 	assert(t, thread.OP.File != nil, "OP post should have a file")
 	assert(t, len(thread.Posts) == 38, "Thread should have 38 posts")
+	files := thread.Files()
+	assert(t, len(files) == len(thread.FilePosts()), "There should be as many files as posts with files")
+	assert(t, files[0].Post == thread.OP, "First file should belong to OP")
 	imageURL := thread.OP.ImageURL()
 	assert(t, imageURL == "http://i.4cdn.org/ck/1346968817055.jpg", "Image URL should be 'http://i.4cdn.org/ck/1346968817055.jpg' (got '"+imageURL+"')")
 	thumbURL := thread.OP.ThumbURL()
 	assert(t, thumbURL == "http://i.4cdn.org/ck/1346968817055s.jpg", "Thumb URL should be 'http://i.4cdn.org/ck/1346968817055s.jpg' (got '"+thumbURL+"')")
 }
 
+func TestCapcodeReplies(t *testing.T) {
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"capcode_replies":{"admin":[3,5],"developer":[4]}}]}`), "q")
+	try(t, err)
+
+	cr := thread.OP.CapcodeReplies
+	assert(t, cr != nil, "OP should have capcode replies")
+	assert(t, cr.Len() == 3, "There should be 3 capcode replies")
+	assert(t, len(cr.Get("admin")) == 2, "There should be 2 admin replies")
+	assert(t, cr.Capcode(4) == "developer", "Post 4 should be a developer reply")
+	assert(t, cr.Capcode(2) == "", "Post 2 should not be a capcode reply")
+
+	var none *CapcodeReplies
+	assert(t, none.Len() == 0 && none.Capcode(3) == "", "nil CapcodeReplies should be empty")
+}
+
+func TestLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("No time zone database:", err)
+	}
+	Location = loc
+	defer func() { Location = nil }()
+
+	file, err := os.Open("example.json")
+	try(t, err)
+	defer file.Close()
+	thread, err := ParseThread(file, "ck")
+	try(t, err)
+
+	assert(t, thread.OP.Now == "09/06/12(Thu)18:00", "OP's Now should be '09/06/12(Thu)18:00' (got '"+thread.OP.Now+"')")
+	assert(t, thread.OP.Time.Location() == loc, "OP's Time should be in America/New_York")
+	now := thread.OP.Time.Format("01/02/06(Mon)15:04")
+	assert(t, now == thread.OP.Now, "OP's Time should match Now (got '"+now+"')")
+}
+
+func TestPreciseTime(t *testing.T) {
+	p := &Post{Time: time.Unix(1346968817, 0), File: &File{Id: 1346968817055}}
+	assert(t, p.PreciseTime().Equal(time.Unix(1346968817, 55000000)), "PreciseTime should come from the file's timestamp")
+
+	p.File = nil
+	assert(t, p.PreciseTime().Equal(p.Time), "PreciseTime without a file should be Time")
+}
+
+func TestLastModifiedTime(t *testing.T) {
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"last_modified":1530792000},{"no":2,"resto":1}]}`), "a")
+	try(t, err)
+	assert(t, thread.OP.LastModifiedTime().Equal(time.Unix(1530792000, 0)), "OP's LastModifiedTime should be set")
+	assert(t, thread.Posts[1].LastModifiedTime().IsZero(), "Reply's LastModifiedTime should be zero")
+}
+
+func TestThreadKey(t *testing.T) {
+	file, err := os.Open("example.json")
+	try(t, err)
+	defer file.Close()
+	thread, err := ParseThread(file, "ck")
+	try(t, err)
+	assert(t, thread.Key() == ThreadKey{"ck", 3856791}, "Thread key should be ck/3856791")
+}
+
+func TestEmptyThread(t *testing.T) {
+	_, err := ParseThread(strings.NewReader(`{"posts":[]}`), "a")
+	assert(t, err == ErrEmptyThread, "Thread without posts should give ErrEmptyThread")
+
+	var thread *Thread
+	assert(t, thread.Id() == 0 && thread.Replies() == 0 && !thread.Sticky(), "Getters on a nil thread should return zero values")
+	thread = &Thread{Board: "a"}
+	assert(t, thread.Id() == 0 && thread.Images() == 0 && !thread.Closed(), "Getters on a thread without an OP should return zero values")
+}
+
 func TestGetIndex(t *testing.T) {
 	threads, err := GetIndex("a", 0)
 	try(t, err)
@@ -52,3 +127,43 @@ func TestGetThreads(t *testing.T) {
 		}
 	}
 }
+
+func TestGallery(t *testing.T) {
+	thread, err := ParseThread(strings.NewReader(`{"posts":[
+		{"no":1,"resto":0,"time":1346971121,"tim":1346971121077,"filename":"a","ext":".png","fsize":10,"md5":"AAAAAAAAAAAAAAAAAAAAAA==","w":4,"h":3},
+		{"no":2,"resto":1,"time":1346971130},
+		{"no":3,"resto":1,"time":1346971140,"tim":1346971140000,"filename":"b","ext":".jpg","filedeleted":1}
+	]}`), "g")
+	try(t, err)
+	g := NewGallery(thread)
+	assert(t, g.Version == GalleryVersion, "The schema version should be set")
+	assert(t, len(g.Items) == 1, "Only the file that hasn't been deleted should be listed")
+	item := g.Items[0]
+	assert(t, item.Thread == 1 && item.Post == 1 && item.Width == 4, "The item should describe the file")
+	assert(t, item.MD5 == "00000000000000000000000000000000", "The MD5 should be hex encoded")
+}
+
+func TestDiffPosts(t *testing.T) {
+	posts := func(ids ...int64) []*Post {
+		p := make([]*Post, len(ids))
+		for i, id := range ids {
+			p[i] = &Post{Id: id}
+		}
+		return p
+	}
+	n, d := diffPosts(posts(1, 2, 3, 4), posts(1, 3, 4, 5, 6))
+	assert(t, n == 2 && d == 1, "One post should be deleted and two added")
+	n, d = diffPosts(posts(1, 2, 3), posts(1))
+	assert(t, n == 0 && d == 2, "Posts missing from the end should be deleted")
+}
+
+func TestJitter(t *testing.T) {
+	assert(t, Jitter(0) == 0, "No jitter should be added when it's disabled")
+	spread := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := Jitter(time.Second)
+		assert(t, d >= 0 && d < time.Second, "Jitter should be less than the maximum")
+		spread[d] = true
+	}
+	assert(t, len(spread) > 1, "Jitter should vary")
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// An Attempt describes a single request to the API, as reported to
+// OnAttempt.
+type Attempt struct {
+	URL string
+	// Attempt counts the consecutive attempts at URL: it is 1 unless the
+	// previous attempts at the same URL failed, in which case it is one more
+	// than the number of failures in a row.
+	Attempt int
+	// Wait is how long the request was held back by the rate limit before
+	// being sent, and Duration is how long it took once it was sent.
+	Wait, Duration time.Duration
+	// StatusCode is the status of the response, or 0 if there wasn't one.
+	StatusCode int
+	// Err is the outcome of the attempt, e.g. ErrNotModified, ErrCircuitOpen,
+	// or the context's error if the request was abandoned while waiting.
+	Err error
+}
+
+// OnAttempt, if set, is called after every request to the API, including
+// requests that were never sent because the circuit breaker was open or the
+// context was done while they were waiting. It is meant for tools that show
+// what the package is doing, such as a request dashboard, and is called
+// synchronously, so it should return quickly.
+var OnAttempt func(a Attempt)
+
+var (
+	failedAttempts      = make(map[string]int)
+	failedAttemptsMutex sync.Mutex
+)
+
+// countAttempt returns the attempt number of a request to url that ended with
+// err, and records the outcome for the next attempt.
+func countAttempt(url string, err error) int {
+	failedAttemptsMutex.Lock()
+	defer failedAttemptsMutex.Unlock()
+	n := failedAttempts[url] + 1
+	if err == nil || err == ErrNotModified || err == ErrNotFound {
+		delete(failedAttempts, url)
+	} else {
+		failedAttempts[url] = n
+	}
+	return n
+}
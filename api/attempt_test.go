@@ -0,0 +1,41 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOnAttempt(t *testing.T) {
+	defer func(c *http.Client) { HTTPClient = c }(HTTPClient)
+	statuses := []int{http.StatusTeapot, http.StatusTeapot, http.StatusOK, http.StatusOK}
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"posts":[{"no":1,"resto":0}]}`)),
+			Request:    req,
+		}, nil
+	})}
+	var attempts []Attempt
+	defer func() { OnAttempt = nil }()
+	OnAttempt = func(a Attempt) { attempts = append(attempts, a) }
+
+	for i := 0; i < 3; i++ {
+		cooldown = nil
+		GetThread("g", 1)
+	}
+	assert(t, len(attempts) == 3, "Every request should be reported")
+	for i, a := range attempts {
+		assert(t, a.Attempt == i+1, "Attempts should be counted until one succeeds")
+		assert(t, strings.HasSuffix(a.URL, "/g/thread/1.json"), "The URL should be reported")
+	}
+	assert(t, attempts[0].StatusCode == http.StatusTeapot && attempts[0].Err != nil, "The failure should be reported")
+	assert(t, attempts[2].StatusCode == http.StatusOK && attempts[2].Err == nil, "The success should be reported")
+	cooldown = nil
+	GetThread("g", 1)
+	assert(t, attempts[3].Attempt == 1, "The count should start over after a success")
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// If AuditLog is set, a record of every API request is appended to it
+	// as a line of JSON (an AuditEntry), for keeping track of where archived
+	// data came from.
+	AuditLog io.Writer = nil
+	// If AuditBodies is true, the audit log includes the full body of each
+	// response, not just its hash.
+	AuditBodies bool = false
+	auditMutex  sync.Mutex
+)
+
+// An AuditEntry is the record of a single request written to AuditLog.
+type AuditEntry struct {
+	Time         time.Time `json:"time"`
+	URL          string    `json:"url"`
+	Status       int       `json:"status,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Size         int       `json:"size"`
+	SHA256       string    `json:"sha256,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Body         []byte    `json:"body,omitempty"`
+}
+
+// audit writes an entry for the request to AuditLog. The response body has
+// to be read in order to hash it, so a response with an equivalent body is
+// returned in its place.
+func audit(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	entry := AuditEntry{Time: DefaultClock.Now().UTC(), URL: req.URL.String()}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Status = resp.StatusCode
+		entry.LastModified = resp.Header.Get("Last-Modified")
+		body, rerr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if rerr != nil {
+			entry.Error = rerr.Error()
+			err = rerr
+		}
+		sum := sha256.Sum256(body)
+		entry.Size = len(body)
+		entry.SHA256 = hex.EncodeToString(sum[:])
+		if AuditBodies {
+			entry.Body = body
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	line, jerr := json.Marshal(entry)
+	if jerr == nil {
+		auditMutex.Lock()
+		AuditLog.Write(append(line, '\n'))
+		auditMutex.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	AuditLog, AuditBodies = &buf, true
+	defer func() { AuditLog, AuditBodies = nil, false }()
+
+	req, err := http.NewRequest("GET", "http://a.4cdn.org/a/thread/1.json", nil)
+	try(t, err)
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header), Body: ioutil.NopCloser(strings.NewReader("hello"))}
+
+	resp, err = audit(req, resp, nil)
+	try(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	try(t, err)
+	assert(t, string(body) == "hello", "Audited response body should be unchanged")
+
+	var entry AuditEntry
+	try(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert(t, entry.URL == "http://a.4cdn.org/a/thread/1.json", "Entry should have the request URL")
+	assert(t, entry.Status == 200 && entry.Size == 5, "Entry should have the status and size")
+	assert(t, entry.SHA256 == "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", "Entry should have the SHA-256 of the body")
+	assert(t, string(entry.Body) == "hello", "Entry should have the body")
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+// The fixtures are example.json, a small thread from /ck/, and the generated
+// large_thread.json and large_catalog.json, which are the size of a thread at
+// its bump limit and a full catalog.
+
+func readFixture(b *testing.B, name string) []byte {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func benchmarkParseThread(b *testing.B, name string) {
+	data := readFixture(b, name)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseThread(bytes.NewReader(data), "ck"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseThreadSmall(b *testing.B) { benchmarkParseThread(b, "example.json") }
+func BenchmarkParseThreadLarge(b *testing.B) { benchmarkParseThread(b, "large_thread.json") }
+
+func BenchmarkParseCatalog(b *testing.B) {
+	data := readFixture(b, "large_catalog.json")
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			b.Fatal(err)
+		}
+		native_catalog(c, "ck")
+	}
+}
+
+func BenchmarkDiffPosts(b *testing.B) {
+	thread, err := ParseThread(bytes.NewReader(readFixture(b, "large_thread.json")), "ck")
+	if err != nil {
+		b.Fatal(err)
+	}
+	// since the old version, every tenth post was deleted and a few new ones
+	// were made
+	old := thread.Posts[:len(thread.Posts)-5]
+	var posts []*Post
+	for i, post := range thread.Posts {
+		if i%10 != 5 {
+			posts = append(posts, post)
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diffPosts(old, posts)
+	}
+}
+
+func BenchmarkThreadString(b *testing.B) {
+	thread, err := ParseThread(bytes.NewReader(readFixture(b, "large_thread.json")), "ck")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = thread.String()
+	}
+}
+
+func BenchmarkParseThreadLargeNoComments(b *testing.B) {
+	defer func() { ParseFields = AllFields }()
+	ParseFields = AllFields &^ FieldComment
+	benchmarkParseThread(b, "large_thread.json")
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var boardsMutex sync.RWMutex
+
+// A BoardChange describes how a board differs between two fetches of the
+// board list. Old is nil for a board that was added, and New is nil for one
+// that was removed.
+type BoardChange struct {
+	Board    string
+	Old, New *Board
+}
+
+// OnBoardsChange, if set, is called whenever a fetch of the board list finds
+// that boards were added or removed, or that their settings changed, since
+// the list was last fetched. It isn't called for the first fetch.
+var OnBoardsChange func(changes []BoardChange)
+
+func cachedBoards() []Board {
+	boardsMutex.RLock()
+	defer boardsMutex.RUnlock()
+	return Boards
+}
+
+func setBoards(boards []Board) {
+	boardsMutex.Lock()
+	old := Boards
+	Boards = boards
+	boardsMutex.Unlock()
+	if old != nil && OnBoardsChange != nil {
+		if changes := diffBoards(old, boards); len(changes) > 0 {
+			OnBoardsChange(changes)
+		}
+	}
+}
+
+// diffBoards lists the differences between two board lists, in the order the
+// boards appear in them.
+func diffBoards(old, new []Board) []BoardChange {
+	before := make(map[string]*Board, len(old))
+	for i := range old {
+		before[old[i].Board] = &old[i]
+	}
+	var changes []BoardChange
+	seen := make(map[string]bool, len(new))
+	for i := range new {
+		b := &new[i]
+		seen[b.Board] = true
+		if o, ok := before[b.Board]; !ok || !reflect.DeepEqual(*o, *b) {
+			changes = append(changes, BoardChange{b.Board, o, b})
+		}
+	}
+	for i := range old {
+		if !seen[old[i].Board] {
+			changes = append(changes, BoardChange{old[i].Board, &old[i], nil})
+		}
+	}
+	return changes
+}
+
+// RefreshBoards fetches the board list every interval until ctx is done, so
+// that Boards stays up to date and OnBoardsChange is called when it changes.
+// Errors fetching the list are ignored; the next refresh tries again. It
+// returns ctx's error.
+func RefreshBoards(ctx context.Context, interval time.Duration) error {
+	for {
+		GetBoards()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-DefaultClock.After(interval):
+		}
+	}
+}
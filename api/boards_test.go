@@ -0,0 +1,44 @@
+package api
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDiffBoards(t *testing.T) {
+	old := []Board{{Board: "a", Pages: 10}, {Board: "g", Pages: 10}, {Board: "x"}}
+	new := []Board{{Board: "a", Pages: 10}, {Board: "g", Pages: 11}, {Board: "v"}}
+	changes := diffBoards(old, new)
+	assert(t, len(changes) == 3, "There should be three changes")
+	assert(t, changes[0].Board == "g" && changes[0].Old.Pages == 10 && changes[0].New.Pages == 11, "/g/ should have changed")
+	assert(t, changes[1].Board == "v" && changes[1].Old == nil, "/v/ should have been added")
+	assert(t, changes[2].Board == "x" && changes[2].New == nil, "/x/ should have been removed")
+}
+
+func TestOnBoardsChange(t *testing.T) {
+	defer func(boards []Board) { Boards = boards; OnBoardsChange = nil }(Boards)
+	var got []BoardChange
+	OnBoardsChange = func(changes []BoardChange) { got = changes }
+
+	Boards = nil
+	setBoards([]Board{{Board: "g"}})
+	assert(t, got == nil, "The first fetch should not count as a change")
+	setBoards([]Board{{Board: "g"}})
+	assert(t, got == nil, "An unchanged list should not count as a change")
+	setBoards([]Board{{Board: "g"}, {Board: "v"}})
+	assert(t, len(got) == 1 && got[0].Board == "v", "An added board should be reported")
+}
+
+func TestCustomSpoilerURLs(t *testing.T) {
+	defer func(ssl bool) { SSL = ssl }(SSL)
+	SSL = true
+	file, err := os.Open("boards_example.json")
+	try(t, err)
+	defer file.Close()
+	boards, err := ParseBoards(file)
+	try(t, err)
+	assert(t, boards[0].Board == "a" && boards[0].CustomSpoilers == 1, "The number of custom spoilers should be parsed")
+	urls := boards[0].CustomSpoilerURLs()
+	assert(t, len(urls) == 1 && urls[0] == "https://s.4cdn.org/image/spoiler-a1.png", "There should be one spoiler URL for /a/")
+	assert(t, boards[1].CustomSpoilerURLs() == nil, "Boards without custom spoilers should have none")
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// BreakerThreshold is the number of consecutive failed requests to a host
+	// after which requests to it are paused. Network errors and server
+	// errors count as failures, as do 403 and 429 responses, which usually
+	// mean the client has been blocked. If it is 0, requests are never
+	// paused.
+	BreakerThreshold int = 5
+	// BreakerCooldown is how long requests to a host are paused for at first.
+	// After the pause, a single request is let through to probe the host;
+	// each time the probe fails, the pause doubles, up to
+	// BreakerMaxCooldown.
+	BreakerCooldown    time.Duration = 30 * time.Second
+	BreakerMaxCooldown time.Duration = 10 * time.Minute
+	// OnBreaker, if set, is called whenever requests to a host are paused
+	// (open is true) or resumed (open is false). retry is how long the pause
+	// lasts.
+	OnBreaker func(host string, open bool, retry time.Duration)
+)
+
+// ErrCircuitOpen is returned without making a request while requests to a
+// host are paused after repeated failures.
+var ErrCircuitOpen = errors.New("api: too many failed requests, host is paused")
+
+type breaker struct {
+	failures int
+	backoff  time.Duration
+	until    time.Time // requests are paused until this time
+	probing  bool      // a probe request is in flight
+}
+
+var (
+	breakers      = make(map[string]*breaker)
+	breakersMutex sync.Mutex
+)
+
+// breakerAllow returns ErrCircuitOpen if requests to host are paused.
+func breakerAllow(host string) error {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+	b := breakers[host]
+	if b == nil || b.until.IsZero() {
+		return nil
+	}
+	if DefaultClock.Now().Before(b.until) || b.probing {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// breakerCancel is called when a request that was allowed is abandoned before
+// being sent, so that it doesn't count as an outstanding probe.
+func breakerCancel(host string) {
+	breakersMutex.Lock()
+	if b := breakers[host]; b != nil {
+		b.probing = false
+	}
+	breakersMutex.Unlock()
+}
+
+// breakerRecord updates the state of host's breaker with the outcome of a
+// request.
+func breakerRecord(host string, resp *http.Response, err error) {
+	failed := err != nil
+	if resp != nil {
+		switch {
+		case resp.StatusCode >= 500, resp.StatusCode == http.StatusForbidden, resp.StatusCode == http.StatusTooManyRequests:
+			failed = true
+		}
+	}
+
+	breakersMutex.Lock()
+	b := breakers[host]
+	if b == nil {
+		b = new(breaker)
+		breakers[host] = b
+	}
+	var (
+		notify bool
+		open   bool
+		retry  time.Duration
+	)
+	if !failed {
+		notify = !b.until.IsZero()
+		*b = breaker{}
+	} else {
+		b.failures++
+		wasProbing := b.probing
+		b.probing = false
+		if BreakerThreshold > 0 && b.failures >= BreakerThreshold {
+			if b.backoff == 0 {
+				b.backoff = BreakerCooldown
+			} else if wasProbing {
+				b.backoff *= 2
+				if b.backoff > BreakerMaxCooldown {
+					b.backoff = BreakerMaxCooldown
+				}
+			}
+			b.until = DefaultClock.Now().Add(b.backoff)
+			notify, open, retry = true, true, b.backoff
+		}
+	}
+	breakersMutex.Unlock()
+
+	if notify && OnBreaker != nil {
+		OnBreaker(host, open, retry)
+	}
+}
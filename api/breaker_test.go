@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	defer func(cooldown time.Duration) {
+		BreakerCooldown = cooldown
+		OnBreaker = nil
+		delete(breakers, "test")
+	}(BreakerCooldown)
+	BreakerCooldown = 20 * time.Millisecond
+
+	var events []bool
+	OnBreaker = func(host string, open bool, retry time.Duration) {
+		events = append(events, open)
+	}
+
+	fail := errors.New("connection refused")
+	for i := 0; i < BreakerThreshold; i++ {
+		try(t, breakerAllow("test"))
+		breakerRecord("test", nil, fail)
+	}
+	assert(t, breakerAllow("test") == ErrCircuitOpen, "Breaker should open after BreakerThreshold failures")
+
+	// the probe fails, so the pause doubles
+	time.Sleep(25 * time.Millisecond)
+	try(t, breakerAllow("test"))
+	assert(t, breakerAllow("test") == ErrCircuitOpen, "Only one probe should be let through")
+	breakerRecord("test", &http.Response{StatusCode: http.StatusServiceUnavailable}, nil)
+	assert(t, breakers["test"].backoff == 40*time.Millisecond, "Failed probe should double the pause")
+
+	time.Sleep(45 * time.Millisecond)
+	try(t, breakerAllow("test"))
+	breakerRecord("test", &http.Response{StatusCode: http.StatusNotFound}, nil)
+	try(t, breakerAllow("test"))
+
+	assert(t, len(events) == 3 && events[0] && events[1] && !events[2], "Breaker should report opening twice and then closing")
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"time"
+)
+
+// A Budget caps how many requests are sent to the API over time. Rather than
+// sending requests as fast as the rate limit allows until the budget runs
+// out, requests are spaced out evenly, so that steady background work, such
+// as updating watched threads, fits within it.
+type Budget struct {
+	// PerHour and PerDay are the most requests to send in an hour and in a
+	// day. Zero means no limit.
+	PerHour int
+	PerDay  int
+}
+
+// If RequestBudget is set, requests are spaced out so as to stay within it.
+// It should not be changed while requests are being made.
+var RequestBudget *Budget
+
+// OnBudgetDelay, if set, is called whenever RequestBudget holds a request
+// back for longer than the rate limit alone would have, with the request's
+// URL and the extra time it waited. It means that work is being scheduled
+// more often than the budget allows, so that intervals such as
+// UpdateCooldown are being stretched. Like OnAttempt, it is called
+// synchronously and should return quickly.
+var OnBudgetDelay func(url string, delay time.Duration)
+
+// lastSent is when the last request was sent. It is only used while holding
+// the scheduler's turn.
+var lastSent time.Time
+
+// Interval returns the time to leave between requests to stay within the
+// budget, or 0 if there is no limit.
+func (self *Budget) Interval() time.Duration {
+	if self == nil {
+		return 0
+	}
+	var d time.Duration
+	if self.PerHour > 0 {
+		d = time.Hour / time.Duration(self.PerHour)
+	}
+	if self.PerDay > 0 {
+		if daily := 24 * time.Hour / time.Duration(self.PerDay); daily > d {
+			d = daily
+		}
+	}
+	return d
+}
+
+// requestInterval returns the time to leave between requests: one second, as
+// the API rules ask, or longer if RequestBudget needs it.
+func requestInterval() time.Duration {
+	if d := RequestBudget.Interval(); d > time.Second {
+		return d
+	}
+	return time.Second
+}
+
+// reportBudgetDelay calls OnBudgetDelay if the request to url, which started
+// waiting at start, was sent later than the one-second rate limit alone would
+// have allowed.
+func reportBudgetDelay(url string, start, sent time.Time) {
+	if OnBudgetDelay == nil || requestInterval() <= time.Second || lastSent.IsZero() {
+		return
+	}
+	allowed := lastSent.Add(time.Second)
+	if start.After(allowed) {
+		allowed = start
+	}
+	if delay := sent.Sub(allowed); delay > 0 {
+		OnBudgetDelay(url, delay)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetInterval(t *testing.T) {
+	var none *Budget
+	assert(t, none.Interval() == 0, "A nil budget shouldn't limit anything")
+	assert(t, (&Budget{PerHour: 120}).Interval() == 30*time.Second, "An hourly budget should space requests over the hour")
+	assert(t, (&Budget{PerHour: 3600, PerDay: 8640}).Interval() == 10*time.Second, "The tighter of the two budgets should win")
+}
+
+func TestRequestBudget(t *testing.T) {
+	defer func(c *http.Client) { HTTPClient = c }(HTTPClient)
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"posts":[{"no":1,"resto":0}]}`)),
+			Request:    req,
+		}, nil
+	})}
+	defer func(c Clock) { DefaultClock = c }(DefaultClock)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+	defer func() { RequestBudget, OnBudgetDelay, lastSent, cooldown = nil, nil, time.Time{}, nil }()
+	RequestBudget = &Budget{PerHour: 120}
+	var delays []time.Duration
+	OnBudgetDelay = func(url string, delay time.Duration) { delays = append(delays, delay) }
+
+	cooldown, lastSent = nil, time.Time{}
+	_, err := GetThread("g", 1)
+	try(t, err)
+	sent := clock.Now()
+
+	done := make(chan error)
+	go func() {
+		_, err := GetThread("g", 1)
+		done <- err
+	}()
+	for waiting := true; waiting; {
+		select {
+		case err = <-done:
+			try(t, err)
+			waiting = false
+		case <-time.After(time.Millisecond):
+			clock.Advance(100 * time.Millisecond)
+		}
+	}
+	assert(t, clock.Now().Sub(sent) >= 30*time.Second, "The second request should wait for the budget's interval")
+	assert(t, len(delays) == 1, "The delay caused by the budget should be reported")
+	assert(t, delays[0] > 0 && delays[0] <= 29*time.Second, "Only the time beyond the rate limit should be reported")
+}
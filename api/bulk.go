@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BulkOptions controls how GetThreadsFull fetches threads.
+type BulkOptions struct {
+	// Workers is the number of threads that are fetched at once. Requests
+	// still go through the package's rate limiting, so this only allows
+	// downloading and parsing to overlap. Defaults to 4.
+	Workers int
+
+	// Priority is the priority the requests are queued at. Bulk fetches
+	// default to PriorityLow so that they don't hold up other requests.
+	Priority Priority
+}
+
+// A ThreadResult is the outcome of fetching a single thread with
+// GetThreadsFull. Exactly one of Thread and Err is non-nil.
+type ThreadResult struct {
+	Id     int64
+	Thread *Thread
+	Err    error
+}
+
+// GetThreadsFull fetches every thread in ids from the given board using a pool
+// of workers. Results are sent on the returned channel as they complete, in no
+// particular order, and the channel is closed once every thread has been
+// fetched. If ctx is done first, the workers give up and the channel is
+// closed early, so a caller that stops reading the results should cancel ctx
+// to release them. opts may be nil to use the defaults.
+func GetThreadsFull(ctx context.Context, board string, ids []int64, opts *BulkOptions) <-chan ThreadResult {
+	workers, priority := 4, PriorityLow
+	if opts != nil {
+		if opts.Workers > 0 {
+			workers = opts.Workers
+		}
+		priority = opts.Priority
+	}
+	ctx = WithPriority(ctx, priority)
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	jobs := make(chan int64)
+	results := make(chan ThreadResult)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				thread, err := getThread(ctx, board, id, time.Unix(0, 0))
+				select {
+				case results <- ThreadResult{id, thread, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+	feed:
+		for _, id := range ids {
+			select {
+			case jobs <- id:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetThreadsFull(t *testing.T) {
+	clock, done := serveStatuses(map[string]string{
+		"/g/thread/1.json": `{"posts":[{"no":1,"resto":0}]}`,
+		"/g/thread/3.json": `{"posts":[{"no":3,"resto":0}]}`,
+	}, nil)
+	defer done()
+
+	got := make(map[int64]ThreadResult)
+	waitFor(clock, func() {
+		for result := range GetThreadsFull(context.Background(), "g", []int64{1, 2, 3}, &BulkOptions{Workers: 2}) {
+			got[result.Id] = result
+		}
+	})
+	assert(t, len(got) == 3, "Every thread should have a result")
+	assert(t, got[1].Thread != nil && got[3].Thread != nil, "Live threads should be fetched")
+	assert(t, errors.Is(got[2].Err, ErrNotFound) && got[2].Thread == nil, "Missing threads should be reported as errors")
+}
+
+func TestGetThreadsFullCancel(t *testing.T) {
+	clock, done := serveStatuses(map[string]string{
+		"/g/thread/1.json": `{"posts":[{"no":1,"resto":0}]}`,
+	}, nil)
+	defer done()
+
+	ids := []int64{1, 1, 1, 1, 1, 1, 1, 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	results := GetThreadsFull(ctx, "g", ids, &BulkOptions{Workers: 2})
+	waitFor(clock, func() { <-results })
+	cancel()
+
+	// without the clock moving, nothing more can be sent, so the channel
+	// only closes if the workers give up
+	closed := make(chan int)
+	go func() {
+		n := 0
+		for range results {
+			n++
+		}
+		closed <- n
+	}()
+	select {
+	case n := <-closed:
+		assert(t, n < len(ids)-1, "The workers should stop fetching once ctx is cancelled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("The results should be closed once ctx is cancelled")
+	}
+}
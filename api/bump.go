@@ -0,0 +1,73 @@
+package api
+
+// PagePosition returns where the thread was listed when it was fetched from an
+// index page or the catalog: the page number, counting from 1 as the site
+// does, and its position on that page, counting from 0. page is 0 if the
+// thread wasn't fetched that way, e.g. by GetThread.
+//
+// Threads are listed in bump order, so a thread near the end of the last page
+// is about to be pruned.
+func (self *Thread) PagePosition() (page, index int) {
+	return self.page, self.page_index
+}
+
+// BumpPosition returns the thread's rank in the board's bump order when it
+// was fetched, counting from 1 for the most recently bumped thread, or 0 if
+// it isn't known. It is always known for threads from the catalog. For
+// threads from GetIndex, it is only known if the board list has already been
+// fetched (by GetBoards or LookupBoard), because the number of threads per
+// page is needed to work it out.
+func (self *Thread) BumpPosition() int {
+	return self.bump_position
+}
+
+// LastPage returns the threads on the last page of the catalog, which are the
+// next to be pruned when new threads are made.
+func (self Catalog) LastPage() []*Thread {
+	if len(self) == 0 {
+		return nil
+	}
+	return self[len(self)-1].Threads
+}
+
+// cachedPerPage returns the number of threads per index page of a board if
+// the board list has been fetched already, or 0 otherwise.
+func cachedPerPage(board string) int {
+	for _, b := range cachedBoards() {
+		if b.Board == board {
+			return b.PerPage
+		}
+	}
+	return 0
+}
+
+// Stickies returns the stickied threads in the catalog.
+func (self Catalog) Stickies() []*Thread {
+	var stickies []*Thread
+	for _, page := range self {
+		for _, thread := range page.Threads {
+			if thread.Sticky() {
+				stickies = append(stickies, thread)
+			}
+		}
+	}
+	return stickies
+}
+
+// NewStickies returns the stickied threads in after that weren't stickied in
+// before, which is usually how announcements show up. Comparing catalogs
+// fetched a while apart finds new stickies without having to remember
+// anything else.
+func NewStickies(before, after Catalog) []*Thread {
+	old := make(map[int64]bool)
+	for _, thread := range before.Stickies() {
+		old[thread.Id()] = true
+	}
+	var stickies []*Thread
+	for _, thread := range after.Stickies() {
+		if !old[thread.Id()] {
+			stickies = append(stickies, thread)
+		}
+	}
+	return stickies
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBumpPosition(t *testing.T) {
+	var c catalog
+	try(t, json.Unmarshal([]byte(`[
+		{"page":1,"threads":[{"no":10},{"no":11}]},
+		{"page":2,"threads":[{"no":12}]}
+	]`), &c))
+	cat := native_catalog(c, "g")
+
+	last := cat.LastPage()
+	assert(t, len(last) == 1 && last[0].Id() == 12, "The last page should have the last thread")
+	page, index := last[0].PagePosition()
+	assert(t, page == 2 && index == 0, "The page position should come from the catalog")
+	assert(t, last[0].BumpPosition() == 3, "The bump position should count across pages")
+
+	thread := &Thread{}
+	page, _ = thread.PagePosition()
+	assert(t, page == 0 && thread.BumpPosition() == 0, "A thread not from an index has no position")
+	thread.MergeFrom(cat[0].Threads[1])
+	assert(t, thread.BumpPosition() == 2, "Merging should pick up the position")
+}
+
+func TestCachedPerPage(t *testing.T) {
+	defer func(boards []Board) { Boards = boards }(Boards)
+	Boards = []Board{{Board: "g", PerPage: 15}}
+	assert(t, cachedPerPage("g") == 15, "The cached board list should be used")
+	assert(t, cachedPerPage("v") == 0, "Unknown boards have no page size")
+}
+
+func TestStickies(t *testing.T) {
+	var before, after catalog
+	try(t, json.Unmarshal([]byte(`[{"page":1,"threads":[{"no":1,"sticky":1},{"no":2}]}]`), &before))
+	try(t, json.Unmarshal([]byte(`[{"page":1,"threads":[{"no":3,"sticky":1},{"no":1,"sticky":1},{"no":2}]}]`), &after))
+
+	stickies := native_catalog(after, "g").Stickies()
+	assert(t, len(stickies) == 2, "Both stickies should be found")
+	stickies = NewStickies(native_catalog(before, "g"), native_catalog(after, "g"))
+	assert(t, len(stickies) == 1 && stickies[0].Id() == 3, "Only the new sticky should be found")
+}
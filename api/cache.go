@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// A ThreadKey identifies a thread across boards.
+type ThreadKey struct {
+	Board string
+	Id    int64
+}
+
+// Key returns the thread's ThreadKey.
+func (self *Thread) Key() ThreadKey {
+	return ThreadKey{self.Board, self.Id()}
+}
+
+// If CacheThreads is true, GetThread keeps every thread it fetches, and asking
+// for the same thread again calls Update on the kept Thread and returns it
+// rather than fetching a new copy. This means repeated calls use conditional
+// requests, but are also subject to UpdateCooldown. Threads are dropped from
+// the cache once they 404.
+var CacheThreads bool = false
+
+var (
+	threadCache      = make(map[ThreadKey]*threadCacheEntry)
+	threadCacheMutex sync.Mutex
+)
+
+type threadCacheEntry struct {
+	sync.Mutex // held while fetching or updating the thread
+	thread     *Thread
+}
+
+func cachedThread(key ThreadKey) (*Thread, error) {
+	threadCacheMutex.Lock()
+	entry, ok := threadCache[key]
+	if !ok {
+		entry = new(threadCacheEntry)
+		threadCache[key] = entry
+	}
+	threadCacheMutex.Unlock()
+
+	entry.Lock()
+	defer entry.Unlock()
+
+	var err error
+	if entry.thread == nil {
+		entry.thread, err = getThread(context.Background(), key.Board, key.Id, time.Unix(0, 0))
+	} else {
+		_, _, err = entry.thread.Update()
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotFound) || entry.thread == nil {
+			ForgetThread(key)
+		}
+		return nil, err
+	}
+	return entry.thread, nil
+}
+
+// ForgetThread removes a thread from the cache used when CacheThreads is set.
+func ForgetThread(key ThreadKey) {
+	threadCacheMutex.Lock()
+	delete(threadCache, key)
+	threadCacheMutex.Unlock()
+}
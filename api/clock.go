@@ -0,0 +1,84 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// A Clock tells the time and makes timers. Everything in the package that
+// waits or looks at the current time goes through DefaultClock, so that tests
+// can replace it with a FakeClock and control time themselves.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// DefaultClock is the clock used throughout the package. It should not be
+// changed while requests are being made.
+var DefaultClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// A FakeClock is a Clock whose time only moves when it is advanced, which
+// makes tests of anything time-dependent fast and deterministic.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (self *FakeClock) Now() time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.now
+}
+
+func (self *FakeClock) After(d time.Duration) <-chan time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- self.now
+	} else {
+		self.timers = append(self.timers, fakeTimer{self.now.Add(d), c})
+	}
+	return c
+}
+
+// Advance moves the clock forward, firing every timer that has come due.
+func (self *FakeClock) Advance(d time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.now = self.now.Add(d)
+	pending := self.timers[:0]
+	for _, timer := range self.timers {
+		if timer.at.After(self.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.c <- self.now
+		}
+	}
+	self.timers = pending
+}
+
+// Waiters returns the number of timers that haven't fired yet. Tests can use
+// it to wait until the code under test is blocked on the clock before
+// advancing it.
+func (self *FakeClock) Waiters() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.timers)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1346971121, 0)
+	clock := NewFakeClock(start)
+	a, b := clock.After(time.Second), clock.After(time.Minute)
+	assert(t, clock.Waiters() == 2, "Both timers should be waiting")
+
+	clock.Advance(2 * time.Second)
+	select {
+	case now := <-a:
+		assert(t, now.Equal(start.Add(2*time.Second)), "The timer should fire with the current time")
+	default:
+		t.Fatal("Failed: The first timer should have fired")
+	}
+	select {
+	case <-b:
+		t.Fatal("Failed: The second timer shouldn't have fired yet")
+	default:
+	}
+	assert(t, clock.Waiters() == 1 && clock.Now().Equal(start.Add(2*time.Second)), "The clock should have moved")
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tagPattern  = regexp.MustCompile(`<[^>]*>`)
+	linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+)
+
+// Links returns all of the http(s) URLs that appear in the post's comment, in
+// the order they appear. 4chan breaks up long words with <wbr> tags, so those
+// are removed before searching in order to recover the full URL.
+func (self *Post) Links() []string {
+	com := strings.Replace(self.CommentHTML(), "<wbr>", "", -1)
+	com = tagPattern.ReplaceAllString(com, " ")
+	com = html.UnescapeString(com)
+
+	matches := linkPattern.FindAllString(com, -1)
+	links := make([]string, 0, len(matches))
+	for _, link := range matches {
+		// trailing punctuation is almost always part of the sentence rather
+		// than the URL
+		link = strings.TrimRight(link, ".,;:!?)'")
+		if link != "" {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// PlainText returns the post's comment as plain text, with line breaks in
+// place of <br> tags and all other markup removed.
+func (self *Post) PlainText() string {
+	com := strings.Replace(self.CommentHTML(), "<wbr>", "", -1)
+	com = strings.Replace(com, "<br>", "\n", -1)
+	com = tagPattern.ReplaceAllString(com, "")
+	return html.UnescapeString(com)
+}
+
+// QuoteLinkHref is used by SanitizedHTML to build the href of each quotelink
+// in a comment. board and thread identify the thread the quoted post is in,
+// and post is the quoted post's number, or 0 if the link pointed at a whole
+// thread. If it is nil, links will point to the post on 4chan.
+var QuoteLinkHref func(board string, thread, post int64) string
+
+// The set of tags 4chan emits in comments, and the attributes that are allowed
+// to survive sanitization for each.
+var allowedTags = map[string]map[string]bool{
+	"a":      {"class": true, "href": true},
+	"b":      {},
+	"br":     {},
+	"code":   {},
+	"em":     {},
+	"i":      {},
+	"pre":    {"class": true},
+	"s":      {},
+	"span":   {"class": true},
+	"strong": {},
+	"sub":    {},
+	"sup":    {},
+	"u":      {},
+	"wbr":    {},
+}
+
+var (
+	tagPartsPattern = regexp.MustCompile(`^<(/?)([a-zA-Z]+)((?:\s[^>]*)?)/?>$`)
+	attrPattern     = regexp.MustCompile(`([a-zA-Z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	quoteHrefRegexp = regexp.MustCompile(`^(?:(?:https?:)?//boards\.4chan(?:nel)?\.org)?(?:/(\w+)/(?:thread|res)/)?(\d+)?(?:#p(\d+))?$`)
+	classPattern    = regexp.MustCompile(`^[\w -]*$`)
+)
+
+// SanitizedHTML returns the post's comment with every tag that 4chan does not
+// itself use stripped out, attributes other than class and href removed, and
+// quotelinks rewritten using QuoteLinkHref. Text is re-escaped, so the result
+// is safe to embed directly into an html/template.
+func (self *Post) SanitizedHTML() template.HTML {
+	var (
+		buf   bytes.Buffer
+		stack []string // open tags that have been written out
+		skip  []string // open tags that were dropped
+	)
+	com := self.CommentHTML()
+	for len(com) > 0 {
+		loc := tagPattern.FindStringIndex(com)
+		if loc == nil {
+			buf.WriteString(html.EscapeString(html.UnescapeString(com)))
+			break
+		}
+		buf.WriteString(html.EscapeString(html.UnescapeString(com[:loc[0]])))
+		tag := com[loc[0]:loc[1]]
+		com = com[loc[1]:]
+
+		parts := tagPartsPattern.FindStringSubmatch(tag)
+		if parts == nil {
+			continue
+		}
+		closing, name := parts[1] == "/", strings.ToLower(parts[2])
+
+		if closing {
+			if n := len(skip); n > 0 && skip[n-1] == name {
+				skip = skip[:n-1]
+				continue
+			}
+			if n := len(stack); n > 0 && stack[n-1] == name {
+				stack = stack[:n-1]
+				buf.WriteString("</" + name + ">")
+			}
+			continue
+		}
+
+		attrs, ok := self.sanitizeAttrs(name, parts[3])
+		if !ok {
+			if name != "br" && name != "wbr" {
+				skip = append(skip, name)
+			}
+			continue
+		}
+		buf.WriteString("<" + name + attrs + ">")
+		if name != "br" && name != "wbr" {
+			stack = append(stack, name)
+		}
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		buf.WriteString("</" + stack[i] + ">")
+	}
+	return template.HTML(buf.String())
+}
+
+// sanitizeAttrs filters the raw attribute string of a tag down to the allowed
+// attributes, returning false if the tag should be dropped entirely.
+func (self *Post) sanitizeAttrs(name, raw string) (string, bool) {
+	allowed, ok := allowedTags[name]
+	if !ok {
+		return "", false
+	}
+	var class, href string
+	for _, m := range attrPattern.FindAllStringSubmatch(raw, -1) {
+		key, val := strings.ToLower(m[1]), html.UnescapeString(m[2]+m[3]+m[4])
+		if !allowed[key] {
+			continue
+		}
+		switch key {
+		case "class":
+			if classPattern.MatchString(val) {
+				class = val
+			}
+		case "href":
+			href = val
+		}
+	}
+
+	attrs := ""
+	if name == "a" {
+		// only quotelinks are kept as links; anything else could point
+		// anywhere
+		if class != "quotelink" {
+			return "", false
+		}
+		href, ok = self.quoteLinkHref(href)
+		if !ok {
+			return "", false
+		}
+		attrs += ` href="` + html.EscapeString(href) + `"`
+	}
+	if class != "" {
+		attrs += ` class="` + class + `"`
+	}
+	return attrs, true
+}
+
+func (self *Post) quoteLinkHref(href string) (string, bool) {
+	m := quoteHrefRegexp.FindStringSubmatch(href)
+	if m == nil || (m[2] == "" && m[3] == "") {
+		return "", false
+	}
+	board := m[1]
+	if board == "" && self.Thread != nil {
+		board = self.Thread.Board
+	}
+	thread, _ := strconv.ParseInt(m[2], 10, 64)
+	post, _ := strconv.ParseInt(m[3], 10, 64)
+	if thread == 0 && self.Thread != nil && self.Thread.OP != nil {
+		thread = self.Thread.OP.Id
+	}
+
+	if QuoteLinkHref != nil {
+		return QuoteLinkHref(board, thread, post), true
+	}
+	return Link{Board: board, Thread: thread, Post: post}.String(), true
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	p := &Post{Comment: `check this out: https://example.com/some/very/long/pa<wbr>th?a=1&amp;b=2.<br>also http://foo.org)`}
+	links := p.Links()
+	assert(t, len(links) == 2, "Post should have 2 links")
+	assert(t, links[0] == "https://example.com/some/very/long/path?a=1&b=2", "First link should be joined across <wbr> (got '"+links[0]+"')")
+	assert(t, links[1] == "http://foo.org", "Second link should have trailing punctuation stripped (got '"+links[1]+"')")
+
+	p = &Post{Comment: "no links here"}
+	assert(t, len(p.Links()) == 0, "Post should have no links")
+}
+
+func TestPlainText(t *testing.T) {
+	p := &Post{Comment: `<span class="quote"><a href="3856791#p3856796" class="quotelink">&gt;&gt;3856796</a></span><br>you come<wbr>dian&#44; you`}
+	text := p.PlainText()
+	assert(t, text == ">>3856796\nyou comedian, you", "Plain text should be '>>3856796\\nyou comedian, you' (got '"+text+"')")
+}
+
+func TestSanitizedHTML(t *testing.T) {
+	thread := &Thread{Board: "ck"}
+	thread.OP = &Post{Id: 3856791, Thread: thread}
+	p := &Post{Thread: thread, Comment: `<span class="quote"><a href="3856791#p3856796" class="quotelink">&gt;&gt;3856796</a></span><br>` +
+		`<script>alert(1)</script><a href="http://evil.com" onclick="x()">click</a> <b style="x">bold</b><s>spoiler`}
+
+	got := string(p.SanitizedHTML())
+	want := `<span class="quote"><a href="http://boards.4chan.org/ck/thread/3856791#p3856796" class="quotelink">&gt;&gt;3856796</a></span><br>` +
+		`alert(1)click <b>bold</b><s>spoiler</s>`
+	assert(t, got == want, "Sanitized HTML should be '"+want+"' (got '"+got+"')")
+
+	QuoteLinkHref = func(board string, thread, post int64) string {
+		return fmt.Sprintf("/%s/%d/%d", board, thread, post)
+	}
+	defer func() { QuoteLinkHref = nil }()
+	p.Comment = `<a href="#p3856800" class="quotelink">&gt;&gt;3856800</a>`
+	got = string(p.SanitizedHTML())
+	want = `<a href="/ck/3856791/3856800" class="quotelink">&gt;&gt;3856800</a>`
+	assert(t, got == want, "Sanitized HTML should be '"+want+"' (got '"+got+"')")
+}
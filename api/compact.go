@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"html"
+	"io/ioutil"
+	"strings"
+)
+
+// CompactOptions say how Thread.Compact reduces the memory used by a thread.
+type CompactOptions struct {
+	// PlainText replaces each comment's HTML with its plain text, escaped
+	// and with <br> line breaks so that it can still be treated as HTML.
+	// Quotes and greentext are still recognised from the text, but the
+	// markup around them, like quotelinks and spoilers, is lost.
+	PlainText bool
+	// DropThumbnails zeroes the thumbnail dimensions of files.
+	DropThumbnails bool
+	// Compress stores comments compressed. Comment is then empty and has to
+	// be read through CommentHTML, which decompresses it every time, so this
+	// trades CPU for memory.
+	Compress bool
+}
+
+// Compact reduces the memory used by a thread that is kept around for a long
+// time, such as a large thread being watched for days. The options apply to
+// the posts the thread has now and to the ones Update brings in later.
+func (self *Thread) Compact(opts CompactOptions) {
+	self.compact = opts
+	for _, post := range self.Posts {
+		post.compact(opts)
+	}
+}
+
+// compressMin is the shortest comment worth compressing; shorter ones tend to
+// get bigger.
+const compressMin = 64
+
+func (self *Post) compact(opts CompactOptions) {
+	if opts.PlainText {
+		text := self.PlainText()
+		self.setComment(strings.Replace(html.EscapeString(text), "\n", "<br>", -1))
+	}
+	if opts.DropThumbnails && self.File != nil {
+		self.File.ThumbWidth, self.File.ThumbHeight = 0, 0
+	}
+	if opts.Compress && len(self.Comment) >= compressMin {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.BestCompression)
+		w.Write([]byte(self.Comment))
+		w.Close()
+		self.comment_z = buf.Bytes()
+		self.Comment = ""
+	}
+}
+
+// CommentHTML returns the post's comment. It is the same as Comment, except
+// that it also works for posts in threads compacted with Compress.
+func (self *Post) CommentHTML() string {
+	if self.comment_z == nil {
+		return self.Comment
+	}
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(self.comment_z)))
+	if err != nil {
+		// only ever compressed by compact, so this can't happen
+		panic("api: corrupt compressed comment: " + err.Error())
+	}
+	return string(data)
+}
+
+// setComment replaces the comment, compressed or not.
+func (self *Post) setComment(com string) {
+	self.Comment = com
+	self.comment_z = nil
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	file, err := os.Open("example.json")
+	try(t, err)
+	defer file.Close()
+	thread, err := ParseThread(file, "ck")
+	try(t, err)
+
+	var texts []string
+	for _, post := range thread.Posts {
+		texts = append(texts, post.PlainText())
+	}
+	long := thread.OP.Comment
+
+	thread.Compact(CompactOptions{Compress: true})
+	assert(t, thread.OP.Comment == "" && thread.OP.CommentHTML() == long, "Compressed comments should read back the same")
+	for i, post := range thread.Posts {
+		assert(t, post.PlainText() == texts[i], "Compression should not change the text")
+	}
+
+	thread.Compact(CompactOptions{PlainText: true, DropThumbnails: true})
+	for i, post := range thread.Posts {
+		assert(t, post.PlainText() == texts[i], "Converting to plain text should keep the text: "+post.PlainText())
+		assert(t, !strings.Contains(post.Comment, "<a"), "Converting to plain text should drop the markup")
+	}
+	assert(t, thread.OP.File.ThumbWidth == 0, "Thumbnail dimensions should be dropped")
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// A Discrepancy is a difference found between two copies of a thread by
+// CompareThreads.
+type Discrepancy struct {
+	Id    int64  // the post that differs
+	Field string // "post" if the post is only in one copy, otherwise the name of the differing field
+	A, B  string // the values in each copy; for "post", "present" or "missing"
+}
+
+func (self Discrepancy) String() string {
+	return fmt.Sprintf("No.%d %s: %q != %q", self.Id, self.Field, self.A, self.B)
+}
+
+// CompareThreads reports the posts present in only one of a and b, and the
+// posts whose file metadata differs between them, ordered by post ID. It is
+// meant for checking an archived copy of a thread against the original.
+func CompareThreads(a, b *Thread) []Discrepancy {
+	var diffs []Discrepancy
+	byId := make(map[int64]*Post, len(b.Posts))
+	for _, post := range b.Posts {
+		byId[post.Id] = post
+	}
+	for _, post := range a.Posts {
+		other, ok := byId[post.Id]
+		if !ok {
+			diffs = append(diffs, Discrepancy{post.Id, "post", "present", "missing"})
+			continue
+		}
+		delete(byId, post.Id)
+		diffs = append(diffs, compareFiles(post.Id, post.File, other.File)...)
+	}
+	for id := range byId {
+		diffs = append(diffs, Discrepancy{id, "post", "missing", "present"})
+	}
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return diffs[i].Id < diffs[j].Id
+	})
+	return diffs
+}
+
+func compareFiles(id int64, a, b *File) []Discrepancy {
+	switch {
+	case a == nil && b == nil:
+		return nil
+	case a == nil:
+		return []Discrepancy{{id, "file", "", b.Name + b.Ext}}
+	case b == nil:
+		return []Discrepancy{{id, "file", a.Name + a.Ext, ""}}
+	}
+	var diffs []Discrepancy
+	field := func(name, x, y string) {
+		if x != y {
+			diffs = append(diffs, Discrepancy{id, name, x, y})
+		}
+	}
+	field("filename", a.Name, b.Name)
+	field("ext", a.Ext, b.Ext)
+	field("fsize", strconv.Itoa(a.Size), strconv.Itoa(b.Size))
+	if !bytes.Equal(a.MD5, b.MD5) {
+		diffs = append(diffs, Discrepancy{id, "md5", fmt.Sprintf("%x", a.MD5), fmt.Sprintf("%x", b.MD5)})
+	}
+	field("w", strconv.Itoa(a.Width), strconv.Itoa(b.Width))
+	field("h", strconv.Itoa(a.Height), strconv.Itoa(b.Height))
+	field("filedeleted", strconv.FormatBool(a.Deleted), strconv.FormatBool(b.Deleted))
+	return diffs
+}
+
+// CompareSources fetches the same thread from two sources, for example Live
+// and a Source backed by a third-party archive, and compares them with
+// CompareThreads.
+func CompareSources(a, b Source, board string, id int64) ([]Discrepancy, error) {
+	ta, err := a.GetThread(board, id)
+	if err != nil {
+		return nil, err
+	}
+	tb, err := b.GetThread(board, id)
+	if err != nil {
+		return nil, err
+	}
+	return CompareThreads(ta, tb), nil
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareThreads(t *testing.T) {
+	live, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"filename":"a","ext":".png","fsize":10,"md5":"AAAA"},{"no":2,"resto":1},{"no":3,"resto":1}]}`), "g")
+	try(t, err)
+	archived, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"filename":"a","ext":".jpg","fsize":10,"md5":"AAAA"},{"no":3,"resto":1},{"no":4,"resto":1}]}`), "g")
+	try(t, err)
+
+	diffs := CompareThreads(live, archived)
+	want := []Discrepancy{
+		{1, "ext", ".png", ".jpg"},
+		{2, "post", "present", "missing"},
+		{4, "post", "missing", "present"},
+	}
+	assert(t, len(diffs) == len(want), "Should find 3 discrepancies")
+	for i := range want {
+		assert(t, diffs[i] == want[i], "Discrepancies should be reported in post order")
+	}
+	assert(t, len(CompareThreads(live, live)) == 0, "A thread should not differ from itself")
+}
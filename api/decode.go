@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// If Strict is true, responses and documents passed to the Parse functions
+// are rejected if any of their posts contain fields that the package doesn't
+// know about, as reported by UnknownFields. This is meant for tests and CI
+// jobs that want to catch changes to the API; normally unknown fields are
+// silently ignored. Other objects, such as boards, often carry fields that
+// the package has no use for, so they aren't checked.
+var Strict bool = false
+
+// A Decoder decodes a single JSON document from r into v, like
+// json.NewDecoder(r).Decode(v).
+type Decoder interface {
+	Decode(r io.Reader, v interface{}) error
+}
+
+// DecoderFunc adapts a function to a Decoder.
+type DecoderFunc func(r io.Reader, v interface{}) error
+
+func (self DecoderFunc) Decode(r io.Reader, v interface{}) error {
+	return self(r, v)
+}
+
+// JSONDecoder decodes every response and document passed to the Parse
+// functions. It defaults to encoding/json, and can be replaced with a faster
+// JSON library for heavy workloads:
+//
+//	api.JSONDecoder = api.DecoderFunc(func(r io.Reader, v interface{}) error {
+//		return jsoniter.NewDecoder(r).Decode(v)
+//	})
+//
+// A replacement has to decode into the same structs that encoding/json would.
+// Strict is checked separately, so it doesn't need to handle that.
+// SalvageThread and UnknownFields always use encoding/json.
+var JSONDecoder Decoder = DecoderFunc(stdDecode)
+
+func stdDecode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func decode(r io.Reader, v interface{}) error {
+	if !Strict {
+		return JSONDecoder.Decode(r, v)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err := JSONDecoder.Decode(bytes.NewReader(data), v); err != nil {
+		return err
+	}
+	unknown, err := UnknownFields(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("api: unknown post fields: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// newDecoder returns a decoder for a stream of posts, which rejects unknown
+// fields if Strict is set.
+func newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if Strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec
+}
+
+// the JSON keys that jsonPost knows about
+var knownPostFields = func() map[string]bool {
+	known := make(map[string]bool)
+	t := reflect.TypeOf(jsonPost{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "" {
+			known[name] = true
+		}
+	}
+	return known
+}()
+
+// UnknownFields reads a thread, index page or catalog document and returns the
+// sorted list of post fields in it that the package doesn't decode.
+func UnknownFields(r io.Reader) ([]string, error) {
+	var doc interface{}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	unknown := make(map[string]bool)
+	findUnknownFields(doc, unknown)
+
+	keys := make([]string, 0, len(unknown))
+	for key := range unknown {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// findUnknownFields walks v looking for post objects, which are recognized by
+// their "no" key, and collects any of their keys that aren't known.
+func findUnknownFields(v interface{}, unknown map[string]bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		_, isPost := v["no"]
+		for key, val := range v {
+			if isPost && !knownPostFields[key] {
+				unknown[key] = true
+			}
+			findUnknownFields(val, unknown)
+		}
+	case []interface{}:
+		for _, val := range v {
+			findUnknownFields(val, unknown)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+const unknownFieldThread = `{"posts":[{"no":1,"resto":0,"com":"hi","m_img":1,"unique_ips":3},{"no":2,"resto":1,"since4pass":2016}]}`
+
+func TestStrict(t *testing.T) {
+	Strict = true
+	defer func() { Strict = false }()
+
+	_, err := ParseThread(strings.NewReader(unknownFieldThread), "a")
+	assert(t, err != nil, "Strict parsing should reject unknown fields")
+
+	file, err := os.Open("example.json")
+	try(t, err)
+	defer file.Close()
+	_, err = ParseThread(file, "ck")
+	try(t, err)
+}
+
+// Every bundled fixture is a real response, so Strict mustn't reject any of
+// them.
+func TestStrictFixtures(t *testing.T) {
+	Strict = true
+	defer func() { Strict = false }()
+
+	for _, test := range []struct {
+		file  string
+		parse func(r io.Reader) error
+	}{
+		{"example.json", func(r io.Reader) error { _, err := ParseThread(r, "ck"); return err }},
+		{"deleted_example.json", func(r io.Reader) error { _, err := ParseThread(r, "g"); return err }},
+		{"large_thread.json", func(r io.Reader) error { _, err := ParseThread(r, "g"); return err }},
+		{"index_example.json", func(r io.Reader) error { _, err := ParseIndex(r, "g"); return err }},
+		{"catalog_example.json", func(r io.Reader) error { _, err := ParseCatalog(r, "g"); return err }},
+		{"large_catalog.json", func(r io.Reader) error { _, err := ParseCatalog(r, "g"); return err }},
+		{"threads_example.json", func(r io.Reader) error { _, err := ParseThreads(r); return err }},
+		{"boards_example.json", func(r io.Reader) error { _, err := ParseBoards(r); return err }},
+	} {
+		file, err := os.Open(test.file)
+		try(t, err)
+		err = test.parse(file)
+		file.Close()
+		if err != nil {
+			t.Errorf("%s should parse with Strict set: %v", test.file, err)
+		}
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	fields, err := UnknownFields(strings.NewReader(unknownFieldThread))
+	try(t, err)
+	assert(t, strings.Join(fields, ",") == "m_img,since4pass,unique_ips", "Unknown fields should be m_img, since4pass and unique_ips (got "+strings.Join(fields, ",")+")")
+}
+
+func TestJSONDecoder(t *testing.T) {
+	defer func(d Decoder) { JSONDecoder = d }(JSONDecoder)
+	calls := 0
+	JSONDecoder = DecoderFunc(func(r io.Reader, v interface{}) error {
+		calls++
+		return json.NewDecoder(r).Decode(v)
+	})
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0}]}`), "g")
+	try(t, err)
+	assert(t, calls == 1 && thread.Id() == 1, "The replacement decoder should be used")
+}
+
+// BenchmarkDecoders compares the default decoder against reading the whole
+// document and unmarshalling it, as a baseline for replacement decoders.
+func BenchmarkDecoders(b *testing.B) {
+	data, err := ioutil.ReadFile("catalog_example.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	decoders := []struct {
+		name string
+		dec  Decoder
+	}{
+		{"default", JSONDecoder},
+		{"unmarshal", DecoderFunc(func(r io.Reader, v interface{}) error {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(data, v)
+		})},
+	}
+	for _, d := range decoders {
+		b.Run(d.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var c catalog
+				if err := d.dec.Decode(bytes.NewReader(data), &c); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
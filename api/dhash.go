@@ -0,0 +1,126 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"sync"
+)
+
+// DHash computes the difference hash of an image: the image is shrunk to 9x8
+// grey pixels, and each bit of the hash says whether a pixel is brighter than
+// the one to its right. Unlike an MD5, it stays (nearly) the same when an
+// image is resized or recompressed, so reposts of an image can be found by
+// comparing hashes with HashDistance.
+func DHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	var grey [h][w]uint64
+	b := img.Bounds()
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			grey[y][x] = averageLuma(img, x0, y0, x1, y1)
+		}
+	}
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// averageLuma averages the luma of the pixels in a rectangle, sampling at
+// most 32x32 of them so that large images don't take too long.
+func averageLuma(img image.Image, x0, y0, x1, y1 int) uint64 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	dx, dy := (x1-x0+31)/32, (y1-y0+31)/32
+	var sum, n uint64
+	for y := y0; y < y1; y += dy {
+		for x := x0; x < x1; x += dx {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += (299*uint64(r) + 587*uint64(g) + 114*uint64(b)) / 1000
+			n++
+		}
+	}
+	return sum / n
+}
+
+// HashDistance is the number of bits that differ between two hashes. Images
+// whose DHashes are within about 10 of each other are likely to be the same
+// picture.
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// A HashIndex stores the perceptual hashes of posts' images so that near
+// duplicates can be looked up.
+type HashIndex interface {
+	// Add records the hash of a post's image.
+	Add(hash uint64, post *Post) error
+	// Similar returns the posts whose hashes are within maxDistance of hash.
+	Similar(hash uint64, maxDistance int) ([]*Post, error)
+}
+
+// HashImages returns a function that can be used as a Downloader's Process
+// function. It computes the DHash of every downloaded image and adds it to
+// index. Files that can't be decoded as images are skipped.
+func HashImages(index HashIndex) func(post *Post, path string) error {
+	return func(post *Post, path string) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		if err == image.ErrFormat {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("api: post #%d: %v", post.Id, err)
+		}
+		return index.Add(DHash(img), post)
+	}
+}
+
+// MemoryHashIndex is a HashIndex kept in memory. Lookups compare against
+// every hash in the index, which is fast enough for a few hundred thousand
+// images. The zero value is ready to use.
+type MemoryHashIndex struct {
+	mu      sync.RWMutex
+	entries []hashEntry
+}
+
+type hashEntry struct {
+	hash uint64
+	post *Post
+}
+
+func (self *MemoryHashIndex) Add(hash uint64, post *Post) error {
+	self.mu.Lock()
+	self.entries = append(self.entries, hashEntry{hash, post})
+	self.mu.Unlock()
+	return nil
+}
+
+func (self *MemoryHashIndex) Similar(hash uint64, maxDistance int) ([]*Post, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	var posts []*Post
+	for _, e := range self.entries {
+		if HashDistance(hash, e.hash) <= maxDistance {
+			posts = append(posts, e.post)
+		}
+	}
+	return posts, nil
+}
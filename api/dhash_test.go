@@ -0,0 +1,43 @@
+package api
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func gradient(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{uint8(255 * x / w)})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	small, large := DHash(gradient(90, 80)), DHash(gradient(900, 800))
+	assert(t, HashDistance(small, large) <= 2, "A resized image should hash nearly the same")
+
+	flipped := gradient(90, 80)
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 45; x++ {
+			a, b := flipped.GrayAt(x, y), flipped.GrayAt(89-x, y)
+			flipped.SetGray(x, y, b)
+			flipped.SetGray(89-x, y, a)
+		}
+	}
+	assert(t, HashDistance(small, DHash(flipped)) > 32, "A mirrored image should hash differently")
+}
+
+func TestMemoryHashIndex(t *testing.T) {
+	var index MemoryHashIndex
+	a, b := &Post{Id: 1}, &Post{Id: 2}
+	try(t, index.Add(0xff, a))
+	try(t, index.Add(0xff00, b))
+
+	posts, err := index.Similar(0xfe, 1)
+	try(t, err)
+	assert(t, len(posts) == 1 && posts[0] == a, "Only the near hash should be similar")
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"sort"
+)
+
+// A Digest is a condensed view of a thread for places that can't show all of
+// it, like previews and notifications: the OP, the replies that got the most
+// replies themselves, and the newest replies. No post appears twice.
+type Digest struct {
+	OP *Post
+	// Top are the most replied-to posts, most replies first.
+	Top []*Post
+	// Latest are the newest posts, in thread order.
+	Latest []*Post
+	// Omitted is the number of posts in the thread that aren't in the
+	// digest.
+	Omitted int
+}
+
+// Digest condenses the thread into its OP, up to top of the most replied-to
+// other posts, and up to latest of the newest other posts. Only posts with at
+// least one reply count towards top.
+func (self *Thread) Digest(top, latest int) *Digest {
+	d := &Digest{OP: self.OP}
+	shown := make(map[int64]bool)
+	if self.OP != nil {
+		shown[self.OP.Id] = true
+	}
+
+	for i := len(self.Posts) - 1; i >= 0 && len(d.Latest) < latest; i-- {
+		if post := self.Posts[i]; !shown[post.Id] {
+			d.Latest = append(d.Latest, post)
+			shown[post.Id] = true
+		}
+	}
+	// collected newest first
+	for i, j := 0, len(d.Latest)-1; i < j; i, j = i+1, j-1 {
+		d.Latest[i], d.Latest[j] = d.Latest[j], d.Latest[i]
+	}
+
+	for _, post := range self.TopRepliedPosts(len(self.Posts)) {
+		if len(d.Top) >= top {
+			break
+		}
+		if !shown[post.Id] {
+			d.Top = append(d.Top, post)
+			shown[post.Id] = true
+		}
+	}
+
+	for _, post := range self.Posts {
+		if !shown[post.Id] {
+			d.Omitted++
+		}
+	}
+	return d
+}
+
+// Posts returns every post in the digest in thread order.
+func (self *Digest) Posts() []*Post {
+	var posts []*Post
+	if self.OP != nil {
+		posts = append(posts, self.OP)
+	}
+	posts = append(posts, self.Top...)
+	posts = append(posts, self.Latest...)
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Id < posts[j].Id
+	})
+	return posts
+}
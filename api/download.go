@@ -0,0 +1,346 @@
+package api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloadRate caps the combined speed of all file downloads, in bytes per
+// second. If it is 0, downloads are only limited by their Downloader's
+// BytesPerSecond.
+var DownloadRate int64 = 0
+
+var downloadThrottle throttle
+
+// A Downloader fetches the files attached to posts from the image server.
+// File downloads are not subject to the API rate limit. The zero value is
+// ready to use.
+type Downloader struct {
+	// BytesPerSecond caps the speed of each individual download. 0 means no
+	// limit.
+	BytesPerSecond int64
+
+	// Naming decides what DownloadTo names files. If it is nil, NameByTim
+	// is used.
+	Naming NamingStrategy
+
+	// Process, if set, is called with each file DownloadFile saves, once it
+	// is in place. An error from it is returned by DownloadFile, but the file
+	// is kept. CheckDimensions can be used here, or wrapped by a function
+	// that also does other post-processing like thumbnailing.
+	Process func(post *Post, path string) error
+
+	// Filter, if set, decides which files are downloaded at all. Asking for
+	// a file it rejects fails with ErrFiltered before anything is fetched.
+	// A FileFilter's Match method can be used here.
+	Filter func(file *File) bool
+
+	// Deleted files can't be downloaded, so asking for one fails with
+	// ErrFileDeleted, unless Placeholders is true, in which case 4chan's
+	// "file deleted" placeholder image is downloaded in its place.
+	Placeholders bool
+}
+
+// ErrFileDeleted is returned when a Downloader is asked for a file that has
+// been deleted.
+var ErrFileDeleted = errors.New("api: file was deleted")
+
+// ErrFiltered is returned when a Downloader is asked for a file that its
+// Filter rejects.
+var ErrFiltered = errors.New("api: file rejected by filter")
+
+// A FileFilter selects files by type, size and dimensions, e.g. to skip tiny
+// images of text and huge videos. Fields left at their zero value don't
+// restrict anything.
+type FileFilter struct {
+	// Exts lists the extensions to accept, such as ".jpg", regardless of
+	// case.
+	Exts []string
+	// MinWidth and MinHeight are the smallest dimensions to accept, and
+	// MaxWidth and MaxHeight the largest.
+	MinWidth, MinHeight int
+	MaxWidth, MaxHeight int
+	// MaxSize is the largest file size to accept, in bytes.
+	MaxSize int
+	// NoSpoilers rejects spoilered files.
+	NoSpoilers bool
+}
+
+// Match reports whether the file passes the filter.
+func (self *FileFilter) Match(file *File) bool {
+	if len(self.Exts) > 0 {
+		ok := false
+		for _, ext := range self.Exts {
+			if strings.EqualFold(ext, file.Ext) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	switch {
+	case file.Width < self.MinWidth, file.Height < self.MinHeight:
+		return false
+	case self.MaxWidth > 0 && file.Width > self.MaxWidth:
+		return false
+	case self.MaxHeight > 0 && file.Height > self.MaxHeight:
+		return false
+	case self.MaxSize > 0 && file.Size > self.MaxSize:
+		return false
+	case self.NoSpoilers && file.Spoiler:
+		return false
+	}
+	return true
+}
+
+// check returns an error if the post's file can't or shouldn't be downloaded.
+func (self *Downloader) check(post *Post) error {
+	if post.File == nil {
+		return fmt.Errorf("api: post #%d has no file", post.Id)
+	}
+	if post.File.Deleted && !self.Placeholders {
+		return ErrFileDeleted
+	}
+	if self.Filter != nil && !self.Filter(post.File) {
+		return ErrFiltered
+	}
+	return nil
+}
+
+// Download writes the file attached to post to w, returning the number of
+// bytes written.
+func (self *Downloader) Download(post *Post, w io.Writer) (int64, error) {
+	if err := self.check(post); err != nil {
+		return 0, err
+	}
+	resp, err := self.fetch(post, 0, time.Time{}, "")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, self.throttled(resp.Body))
+}
+
+// DownloadFile saves the file attached to post to path. The file is first
+// written to path + ".part", and if that already exists from an earlier
+// interrupted attempt, the download picks up where it left off. Once the
+// download is complete, its MD5 is checked against the one the API gave, and
+// only if it matches is the file moved into place.
+//
+// If path already exists, the request is made conditional on the file having
+// changed since it was saved, and nothing is downloaded if it hasn't. The
+// server's ETag for a saved file is kept next to it, at ETagPath(path), and
+// sent as If-None-Match. For files saved without one, the file's
+// modification time, which is set to the server's Last-Modified time, is sent
+// as If-Modified-Since instead.
+func (self *Downloader) DownloadFile(post *Post, path string) error {
+	if err := self.check(post); err != nil {
+		return err
+	}
+	var since time.Time
+	var etag string
+	if fi, err := os.Stat(path); err == nil {
+		since = fi.ModTime()
+		if data, err := ioutil.ReadFile(ETagPath(path)); err == nil {
+			etag = strings.TrimSpace(string(data))
+		}
+	}
+
+	part := path + ".part"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if post.File != nil && offset >= int64(post.File.Size) {
+		// nothing left to download, or the partial file is bogus
+		offset = 0
+	}
+
+	resp, err := self.fetch(post, offset, since, etag)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		f.Close()
+		os.Remove(part)
+		return nil
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		// the server ignored the range; start over
+		if err = f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	if _, err = io.Copy(f, self.throttled(resp.Body)); err != nil {
+		return err
+	}
+
+	// a placeholder won't match the MD5 of the original file
+	if !post.File.Deleted {
+		if err = verifyMD5(f, post.File.MD5); err != nil {
+			f.Close()
+			os.Remove(part)
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(part, path); err != nil {
+		return err
+	}
+	if mtime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(path, mtime, mtime)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		if err = ioutil.WriteFile(ETagPath(path), []byte(etag), 0644); err != nil {
+			return err
+		}
+	} else {
+		// whatever tag there was belonged to the old version of the file
+		os.Remove(ETagPath(path))
+	}
+	if self.Process != nil {
+		return self.Process(post, path)
+	}
+	return nil
+}
+
+// ETagPath returns the path that DownloadFile keeps the ETag of the file at
+// path in.
+func ETagPath(path string) string {
+	return path + ".etag"
+}
+
+// fetch requests the post's file starting from the given byte offset. If etag
+// is set, the request is conditional on the file no longer matching it, or
+// failing that, if since is not zero, on the file having been modified since
+// then.
+func (self *Downloader) fetch(post *Post, offset int64, since time.Time, etag string) (*http.Response, error) {
+	if post.File == nil {
+		return nil, fmt.Errorf("api: post #%d has no file", post.Id)
+	}
+	url := post.ImageURL()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	} else if !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	if err := breakerAllow(req.URL.Host); err != nil {
+		return nil, err
+	}
+	resp, err := HTTPClient.Do(req)
+	breakerRecord(req.URL.Host, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified:
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("api: downloading %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// verifyMD5 checks the contents of f against sum. If sum is empty, there is
+// nothing to check against and the file is assumed to be fine.
+func verifyMD5(f io.ReadSeeker, sum []byte) error {
+	if len(sum) == 0 {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, sum) {
+		return fmt.Errorf("api: MD5 mismatch: expected %x, got %x", sum, got)
+	}
+	return nil
+}
+
+func (self *Downloader) throttled(r io.Reader) io.Reader {
+	if DownloadRate <= 0 && self.BytesPerSecond <= 0 {
+		return r
+	}
+	return &throttledReader{r: r, rate: self.BytesPerSecond}
+}
+
+// throttledReader slows reads down to the per-download rate and the global
+// DownloadRate.
+type throttledReader struct {
+	r    io.Reader
+	rate int64
+	own  throttle
+}
+
+func (self *throttledReader) Read(p []byte) (int, error) {
+	// keep chunks small enough that the sleeps stay smooth
+	max := len(p)
+	for _, rate := range []int64{self.rate, DownloadRate} {
+		if rate > 0 && int64(max) > rate/4+1 {
+			max = int(rate/4 + 1)
+		}
+	}
+	n, err := self.r.Read(p[:max])
+	self.own.take(n, self.rate)
+	downloadThrottle.take(n, DownloadRate)
+	return n, err
+}
+
+// throttle spaces out transfers so that they don't exceed a rate.
+type throttle struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// take accounts for n bytes being transferred at rate bytes per second, and
+// blocks until the transfer is allowed.
+func (self *throttle) take(n int, rate int64) {
+	if rate <= 0 || n <= 0 {
+		return
+	}
+	self.mu.Lock()
+	now := DefaultClock.Now()
+	if self.next.Before(now) {
+		self.next = now
+	}
+	wait := self.next.Sub(now)
+	self.next = self.next.Add(time.Duration(int64(n) * int64(time.Second) / rate))
+	self.mu.Unlock()
+	if wait > 0 {
+		<-DefaultClock.After(wait)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottledReader(t *testing.T) {
+	d := &Downloader{BytesPerSecond: 10000}
+	data := make([]byte, 3000)
+
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, d.throttled(bytes.NewReader(data)))
+	try(t, err)
+	elapsed := time.Since(start)
+
+	assert(t, n == int64(len(data)), "All of the data should be read")
+	assert(t, elapsed >= 200*time.Millisecond, "3000 bytes at 10000 B/s should take at least 200ms (took "+elapsed.String()+")")
+}
+
+func TestVerifyMD5(t *testing.T) {
+	data := []byte("hello")
+	sum := md5.Sum(data)
+	try(t, verifyMD5(bytes.NewReader(data), sum[:]))
+	try(t, verifyMD5(bytes.NewReader(data), nil))
+	assert(t, verifyMD5(bytes.NewReader([]byte("hellp")), sum[:]) != nil, "Corrupt data should fail verification")
+}
+
+func TestCheckDimensions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.png")
+	f, err := os.Create(path)
+	try(t, err)
+	try(t, png.Encode(f, image.NewGray(image.Rect(0, 0, 4, 3))))
+	try(t, f.Close())
+
+	post := &Post{Id: 1, File: &File{Width: 4, Height: 3}}
+	try(t, CheckDimensions(post, path))
+
+	post.File.Height = 5
+	_, ok := CheckDimensions(post, path).(*DimensionError)
+	assert(t, ok, "A size mismatch should give a *DimensionError")
+
+	try(t, ioutil.WriteFile(path, []byte("\x1aE\xdf\xa3 not an image"), 0644))
+	try(t, CheckDimensions(post, path))
+}
+
+func TestFileFilter(t *testing.T) {
+	filter := &FileFilter{Exts: []string{".jpg", ".png"}, MinWidth: 100, MaxSize: 1000, NoSpoilers: true}
+	assert(t, filter.Match(&File{Ext: ".JPG", Width: 200, Size: 500}), "A file meeting every criterion should match")
+	assert(t, !filter.Match(&File{Ext: ".webm", Width: 200, Size: 500}), "Other types should be rejected")
+	assert(t, !filter.Match(&File{Ext: ".png", Width: 50, Size: 500}), "Small images should be rejected")
+	assert(t, !filter.Match(&File{Ext: ".png", Width: 200, Size: 5000}), "Large files should be rejected")
+	assert(t, !filter.Match(&File{Ext: ".png", Width: 200, Size: 500, Spoiler: true}), "Spoilers should be rejected")
+	assert(t, (&FileFilter{}).Match(&File{Ext: ".webm", Size: 1 << 30}), "The zero filter should match everything")
+
+	dir := t.TempDir()
+	d := &Downloader{Filter: filter.Match}
+	_, err := d.DownloadTo(&Post{Id: 1, File: &File{Id: 1, Ext: ".webm"}}, dir)
+	assert(t, err == ErrFiltered, "Rejected files should fail with ErrFiltered")
+	entries, err := ioutil.ReadDir(dir)
+	try(t, err)
+	assert(t, len(entries) == 0, "Nothing should be written for rejected files")
+}
+
+func TestDeletedFile(t *testing.T) {
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"filedeleted":1},{"no":2,"resto":1,"filename":"a","ext":".jpg","tim":100,"md5":"AAAAAAAAAAAAAAAAAAAAAA==","filedeleted":1}]}`), "g")
+	try(t, err)
+	op, reply := thread.Posts[0], thread.Posts[1]
+	assert(t, op.File != nil && op.File.Deleted, "A deleted file should be modelled even without its metadata")
+	assert(t, strings.HasSuffix(op.ImageURL(), "/image/filedeleted.gif"), "A deleted OP file should point at the OP placeholder")
+	assert(t, strings.HasSuffix(reply.File.ThumbURL(), "/image/filedeleted-res.gif"), "A deleted reply file should point at the reply placeholder")
+	var none *File
+	assert(t, none.URL() == "" && (&Post{}).ImageURL() == "", "Missing files should have no URL")
+
+	dir := t.TempDir()
+	_, err = new(Downloader).DownloadTo(reply, dir)
+	assert(t, err == ErrFileDeleted, "Deleted files should be skipped by default")
+
+	defer func(c *http.Client) { HTTPClient = c }(HTTPClient)
+	var fetched string
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetched = req.URL.Path
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("GIF89a")),
+			Request:    req,
+		}, nil
+	})}
+	path, err := (&Downloader{Placeholders: true}).DownloadTo(reply, dir)
+	try(t, err)
+	assert(t, fetched == "/image/filedeleted-res.gif", "The placeholder should be downloaded instead")
+	data, err := ioutil.ReadFile(path)
+	try(t, err)
+	assert(t, string(data) == "GIF89a", "The placeholder should be saved without checking the original's MD5")
+}
+
+func TestDownloadETag(t *testing.T) {
+	const content = "PNG data"
+	sum := md5.Sum([]byte(content))
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"filename":"a","ext":".png","tim":100,"fsize":8,"md5":"`+base64.StdEncoding.EncodeToString(sum[:])+`"}]}`), "g")
+	try(t, err)
+	post := thread.Posts[0]
+
+	defer func(c *http.Client) { HTTPClient = c }(HTTPClient)
+	var inm, ims string
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		inm, ims = req.Header.Get("If-None-Match"), req.Header.Get("If-Modified-Since")
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Etag": {`"v1"`}},
+			Body:       ioutil.NopCloser(strings.NewReader(content)),
+			Request:    req,
+		}
+		if inm == `"v1"` {
+			resp.StatusCode, resp.Body = http.StatusNotModified, ioutil.NopCloser(strings.NewReader(""))
+		}
+		return resp, nil
+	})}
+
+	path := filepath.Join(t.TempDir(), "100.png")
+	d := new(Downloader)
+	try(t, d.DownloadFile(post, path))
+	etag, err := ioutil.ReadFile(ETagPath(path))
+	try(t, err)
+	assert(t, string(etag) == `"v1"`, "The ETag should be saved next to the file")
+
+	// a restored copy of the file has a new modification time, which
+	// shouldn't matter
+	now := time.Now()
+	try(t, os.Chtimes(path, now, now))
+	try(t, d.DownloadFile(post, path))
+	assert(t, inm == `"v1"` && ims == "", "The saved ETag should be sent instead of the modification time")
+	data, err := ioutil.ReadFile(path)
+	try(t, err)
+	assert(t, string(data) == content, "A 304 should leave the file alone")
+	_, err = os.Stat(path + ".part")
+	assert(t, os.IsNotExist(err), "A 304 shouldn't leave a partial download behind")
+}
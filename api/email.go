@@ -0,0 +1,65 @@
+package api
+
+import (
+	"html"
+	"strings"
+)
+
+// emailOptions are the words in the email field that the site treats as
+// options rather than an address.
+var emailOptions = map[string]bool{
+	"sage":       true,
+	"noko":       true,
+	"nonoko":     true,
+	"nokosage":   true,
+	"nonokosage": true,
+	"dump":       true,
+}
+
+// EmailAddress returns the contents of the post's email field without any
+// mailto: prefix and with HTML entities decoded.
+func (self *Post) EmailAddress() string {
+	email := html.UnescapeString(strings.TrimSpace(self.Email))
+	if len(email) >= 7 && strings.EqualFold(email[:7], "mailto:") {
+		email = email[7:]
+	}
+	return email
+}
+
+// EmailOptions returns the posting options given in the email field, like
+// sage or noko, in lower case. Anything in the field that isn't an option is
+// left out.
+func (self *Post) EmailOptions() []string {
+	var opts []string
+	for _, word := range strings.Fields(strings.ToLower(self.EmailAddress())) {
+		if emailOptions[word] {
+			opts = append(opts, word)
+		}
+	}
+	return opts
+}
+
+// IsSage reports whether the post was saged, meaning it didn't bump its
+// thread.
+func (self *Post) IsSage() bool {
+	for _, opt := range self.EmailOptions() {
+		if strings.HasSuffix(opt, "sage") {
+			return true
+		}
+	}
+	return false
+}
+
+// LastBump returns the newest post that bumped the thread: the newest reply
+// that wasn't saged, or the OP if there are none. Replies made after the
+// thread hit its bump limit don't bump it either, but the API doesn't say
+// which replies those are, so they are counted.
+func (self *Thread) LastBump() *Post {
+	for i := len(self.Posts) - 1; i >= 0; i-- {
+		post := self.Posts[i]
+		if post != self.OP && !post.IsSage() {
+			return post
+		}
+	}
+	return self.OP
+}
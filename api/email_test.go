@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	cases := []struct {
+		email   string
+		address string
+		sage    bool
+	}{
+		{"", "", false},
+		{"sage", "sage", true},
+		{"mailto:SAGE", "SAGE", true},
+		{"nokosage", "nokosage", true},
+		{"noko", "noko", false},
+		{"mailto:moot@4chan.org", "moot@4chan.org", false},
+		{"sage&amp;more", "sage&more", false},
+	}
+	for _, c := range cases {
+		post := &Post{Email: c.email}
+		if got := post.EmailAddress(); got != c.address {
+			t.Errorf("EmailAddress(%q) = %q, expected %q", c.email, got, c.address)
+		}
+		if got := post.IsSage(); got != c.sage {
+			t.Errorf("IsSage(%q) = %v", c.email, got)
+		}
+	}
+}
+
+func TestLastBump(t *testing.T) {
+	op := &Post{Id: 1}
+	thread := &Thread{OP: op, Posts: []*Post{op, {Id: 2}, {Id: 3, Email: "sage"}}}
+	assert(t, thread.LastBump().Id == 2, "Saged replies should not count as bumps")
+	thread.Posts = thread.Posts[:1]
+	assert(t, thread.LastBump() == op, "A thread without replies was last bumped by its OP")
+}
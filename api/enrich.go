@@ -0,0 +1,80 @@
+package api
+
+import (
+	"sync"
+)
+
+// An Enricher computes an annotation for a post, such as the language of its
+// comment or a toxicity score. It returns nil if it has nothing to say about
+// the post.
+type Enricher func(post *Post) interface{}
+
+var (
+	enrichers      []namedEnricher
+	enrichersMutex sync.RWMutex
+)
+
+type namedEnricher struct {
+	name string
+	fn   Enricher
+}
+
+// RegisterEnricher adds an enricher that is run on every post as it is
+// parsed, whether by ParseThread, GetThread, Thread.Update, GetIndex or
+// GetCatalog. Its result is stored in the post's Annotations under name.
+// Enrichers run in the order they were registered, after the whole thread has
+// been parsed, so they can look at the post's Thread and at the annotations
+// of earlier enrichers. Registering another enricher with the same name
+// replaces it.
+//
+// Posts are parsed again every time a thread is fetched, so enrichers that
+// are expensive should cache their results, e.g. by board and post ID.
+func RegisterEnricher(name string, fn Enricher) {
+	enrichersMutex.Lock()
+	defer enrichersMutex.Unlock()
+	for i := range enrichers {
+		if enrichers[i].name == name {
+			enrichers[i].fn = fn
+			return
+		}
+	}
+	enrichers = append(enrichers, namedEnricher{name, fn})
+}
+
+// UnregisterEnricher removes the enricher with the given name, if there is
+// one.
+func UnregisterEnricher(name string) {
+	enrichersMutex.Lock()
+	defer enrichersMutex.Unlock()
+	for i := range enrichers {
+		if enrichers[i].name == name {
+			enrichers = append(enrichers[:i], enrichers[i+1:]...)
+			return
+		}
+	}
+}
+
+// enrich runs the registered enrichers on every post in the thread.
+func enrich(thread *Thread) {
+	enrichersMutex.RLock()
+	defer enrichersMutex.RUnlock()
+	if len(enrichers) == 0 {
+		return
+	}
+	for _, post := range thread.Posts {
+		for _, e := range enrichers {
+			if v := e.fn(post); v != nil {
+				post.Annotate(e.name, v)
+			}
+		}
+	}
+}
+
+// Annotate sets an annotation on the post. Annotations are normally set by
+// enrichers, but can also be set directly.
+func (self *Post) Annotate(name string, value interface{}) {
+	if self.Annotations == nil {
+		self.Annotations = make(map[string]interface{})
+	}
+	self.Annotations[name] = value
+}
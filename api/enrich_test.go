@@ -0,0 +1,36 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnrich(t *testing.T) {
+	RegisterEnricher("length", func(post *Post) interface{} {
+		return len(post.Comment)
+	})
+	RegisterEnricher("long", func(post *Post) interface{} {
+		if post.Annotations["length"].(int) > 3 {
+			return true
+		}
+		return nil
+	})
+	defer UnregisterEnricher("length")
+	defer UnregisterEnricher("long")
+
+	thread, err := ParseThread(strings.NewReader(`{"posts":[
+		{"no":1,"resto":0,"com":"hello"},
+		{"no":2,"resto":1,"com":"hi"}
+	]}`), "g")
+	try(t, err)
+	assert(t, thread.Posts[0].Annotations["length"] == 5, "The enricher's result should be stored")
+	assert(t, thread.Posts[0].Annotations["long"] == true, "Enrichers should see earlier annotations")
+	_, ok := thread.Posts[1].Annotations["long"]
+	assert(t, !ok, "A nil result should not be stored")
+
+	UnregisterEnricher("long")
+	UnregisterEnricher("length")
+	thread, err = ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0}]}`), "g")
+	try(t, err)
+	assert(t, thread.Posts[0].Annotations == nil, "Unregistered enrichers should not run")
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+)
+
+// Fields selects groups of post fields to parse.
+type Fields uint
+
+const (
+	// FieldComment is the comment.
+	FieldComment Fields = 1 << iota
+	// FieldSubject is the subject.
+	FieldSubject
+	// FieldPoster is who made the post: the name, tripcode, email, poster
+	// ID, capcode and country.
+	FieldPoster
+	// FieldFile is the attached file.
+	FieldFile
+
+	AllFields = FieldComment | FieldSubject | FieldPoster | FieldFile
+)
+
+// ParseFields selects the fields that are kept when posts are parsed; the rest
+// are left empty. Scrapers that only care about, say, post numbers and files
+// can leave out FieldComment, which makes parsing faster and uses less memory
+// since comments are by far the biggest part of a post. The post number, time
+// and thread information are always parsed. It should not be changed while
+// requests are being made.
+var ParseFields = AllFields
+
+// jsonComment is a comment in the API's JSON. Comments aren't decoded at all
+// unless ParseFields asks for them.
+type jsonComment string
+
+func (self *jsonComment) UnmarshalJSON(data []byte) error {
+	if ParseFields&FieldComment == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(self))
+}
+
+// selectFields clears the fields of a freshly parsed post that ParseFields
+// doesn't ask for.
+func (self *Post) selectFields() {
+	if ParseFields&FieldSubject == 0 {
+		self.Subject = ""
+	}
+	if ParseFields&FieldPoster == 0 {
+		self.Name, self.Trip, self.Email, self.Special, self.Capcode = "", "", "", "", ""
+		self.Country, self.CountryName = "", ""
+		self.BoardFlag, self.FlagName = "", ""
+	}
+	if ParseFields&FieldFile == 0 {
+		self.File = nil
+	}
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	defer func() { ParseFields = AllFields }()
+	ParseFields = FieldFile
+
+	file, err := os.Open("example.json")
+	try(t, err)
+	defer file.Close()
+	thread, err := ParseThread(file, "ck")
+	try(t, err)
+	op := thread.OP
+	assert(t, op.Id == 3856791 && !op.Time.IsZero(), "The post number and time should always be parsed")
+	assert(t, op.Comment == "" && op.Name == "", "Fields that weren't asked for should be empty")
+	assert(t, op.File != nil && op.File.Name == "White-Bread", "The file should be parsed")
+}
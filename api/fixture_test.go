@@ -0,0 +1,194 @@
+package api
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures are captured responses for each endpoint: boards_example.json,
+// threads_example.json, catalog_example.json, index_example.json, and
+// example.json along with deleted_example.json, a later copy of the same
+// thread after some posts and a file were deleted. The tests serve them in
+// place of the API and compare what is parsed out of them against the .golden
+// files, so everything can be checked offline. After a deliberate change to
+// the parsed output, rewrite the golden files with
+//
+//	go test -run Golden -update
+var update = flag.Bool("update", false, "rewrite the .golden files")
+
+var fixtures = map[string]string{
+	"/boards.json":            "boards_example.json",
+	"/ck/threads.json":        "threads_example.json",
+	"/a/catalog.json":         "catalog_example.json",
+	"/ck/1.json":              "index_example.json",
+	"/ck/thread/3856791.json": "example.json",
+}
+
+// serveFixtures makes requests go to the fixtures instead of the network
+// until the returned function is called.
+func serveFixtures(t *testing.T) func() {
+	client, boards, loc := HTTPClient, cachedBoards(), Location
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status, body := http.StatusNotFound, []byte{}
+		if name, ok := fixtures[req.URL.Path]; ok {
+			data, err := ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			status, body = http.StatusOK, data
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+			Request:    req,
+		}, nil
+	})}
+	// times are rendered in the golden files, which shouldn't depend on the
+	// local time zone
+	Location = time.UTC
+	return func() {
+		HTTPClient, Location = client, loc
+		boardsMutex.Lock()
+		Boards = boards
+		boardsMutex.Unlock()
+	}
+}
+
+// fetch calls get without waiting for the rate limit, which only slows the
+// tests down since nothing is sent to the network.
+func fetch(get func() error) error {
+	cooldown = nil
+	return get()
+}
+
+func checkGolden(t *testing.T, name, got string) {
+	path := name + ".golden"
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("Output differs from %s (rerun with -update if the change is intended):\n%s", path, got)
+	}
+}
+
+func renderThread(b *strings.Builder, thread *Thread) {
+	fmt.Fprintf(b, "thread /%s/%d replies=%d images=%d omitted=%d/%d sticky=%v closed=%v\n",
+		thread.Board, thread.Id(), thread.Replies(), thread.Images(), thread.OmittedPosts(), thread.OmittedImages(),
+		thread.Sticky(), thread.Closed())
+	for _, post := range thread.Posts {
+		fmt.Fprintf(b, "  No.%d %s name=%q trip=%q sub=%q email=%q capcode=%q country=%q\n",
+			post.Id, post.Time.Format(time.RFC3339), post.Name, post.Trip, post.Subject, post.Email, post.Capcode, post.Country)
+		if f := post.File; f != nil {
+			fmt.Fprintf(b, "    file %d %q%s %dB %dx%d thumb %dx%d md5=%x deleted=%v spoiler=%v\n",
+				f.Id, f.Name, f.Ext, f.Size, f.Width, f.Height, f.ThumbWidth, f.ThumbHeight, f.MD5, f.Deleted, f.Spoiler)
+		}
+		fmt.Fprintf(b, "    %q\n", post.CommentHTML())
+	}
+}
+
+func TestGoldenBoards(t *testing.T) {
+	defer serveFixtures(t)()
+	var boards []Board
+	try(t, fetch(func() (err error) { boards, err = GetBoards(); return }))
+	var b strings.Builder
+	for _, board := range boards {
+		fmt.Fprintf(&b, "%s %q pages=%d per_page=%d\n", board.Board, board.Title, board.Pages, board.PerPage)
+	}
+	checkGolden(t, "boards_example", b.String())
+}
+
+func TestGoldenThreads(t *testing.T) {
+	defer serveFixtures(t)()
+	var pages [][]int64
+	try(t, fetch(func() (err error) { pages, err = GetThreads("ck"); return }))
+	checkGolden(t, "threads_example", fmt.Sprintln(pages))
+}
+
+func TestGoldenCatalog(t *testing.T) {
+	defer serveFixtures(t)()
+	var cat Catalog
+	try(t, fetch(func() (err error) { cat, err = GetCatalog("a"); return }))
+	var b strings.Builder
+	for _, page := range cat {
+		fmt.Fprintf(&b, "page %d\n", page.Page)
+		for _, thread := range page.Threads {
+			renderThread(&b, thread)
+		}
+	}
+	checkGolden(t, "catalog_example", b.String())
+}
+
+func TestGoldenIndex(t *testing.T) {
+	defer serveFixtures(t)()
+	var threads []*Thread
+	try(t, fetch(func() (err error) { threads, err = GetIndex("ck", 0); return }))
+	var b strings.Builder
+	for _, thread := range threads {
+		renderThread(&b, thread)
+	}
+	checkGolden(t, "index_example", b.String())
+}
+
+func TestGoldenThread(t *testing.T) {
+	defer serveFixtures(t)()
+	var thread *Thread
+	try(t, fetch(func() (err error) { thread, err = GetThread("ck", 3856791); return }))
+	var b strings.Builder
+	renderThread(&b, thread)
+	checkGolden(t, "example", b.String())
+
+	fixtures["/ck/thread/3856791.json"] = "deleted_example.json"
+	defer func() { fixtures["/ck/thread/3856791.json"] = "example.json" }()
+	before := *thread
+	var added, deleted int
+	try(t, fetch(func() (err error) { added, deleted, err = thread.Update(); return }))
+	assert(t, added == 0 && deleted == 3, "Update should find the 3 deleted posts")
+
+	b.Reset()
+	renderThread(&b, thread)
+	for _, event := range Changes(&before, thread, time.Unix(1346973120, 0).UTC()) {
+		fmt.Fprintf(&b, "%s %s No.%d %s\n", event.Time.Format(time.RFC3339), event.Type, event.Id, event.State)
+	}
+	checkGolden(t, "deleted_example", b.String())
+}
+
+func TestParseFixtures(t *testing.T) {
+	open := func(name string) *os.File {
+		f, err := os.Open(name)
+		try(t, err)
+		return f
+	}
+	f := open("boards_example.json")
+	defer f.Close()
+	boards, err := ParseBoards(f)
+	try(t, err)
+	assert(t, len(boards) == 3 && boards[1].Board == "ck", "ParseBoards should parse every board")
+
+	f = open("threads_example.json")
+	defer f.Close()
+	pages, err := ParseThreads(f)
+	try(t, err)
+	assert(t, len(pages) == 2 && len(pages[1]) == 2, "ParseThreads should parse every page")
+	_, err = ParseThreads(strings.NewReader(`[{"page":3,"threads":[]}]`))
+	assert(t, err != nil, "A page out of range should be an error")
+
+	f = open("catalog_example.json")
+	defer f.Close()
+	cat, err := ParseCatalog(f, "a")
+	try(t, err)
+	assert(t, len(cat) > 0 && cat[0].Threads[0].Board == "a", "ParseCatalog should parse the catalog")
+}
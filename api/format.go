@@ -0,0 +1,51 @@
+package api
+
+import (
+	"io"
+	"text/template"
+)
+
+// A Formatter renders posts and threads with a user supplied text/template,
+// for tools that want to print posts their own way. The template is executed
+// with a *Post as its data, so it can use any of Post's fields and methods:
+//
+//	f, err := api.NewFormatter(`{{.DisplayName}} No.{{.Id}}{{"\n"}}{{.PlainText}}{{"\n"}}`)
+//
+// PostTemplate is the template equivalent to Post.String.
+type Formatter struct {
+	tmpl *template.Template
+}
+
+// PostTemplate renders posts the same way as Post.String.
+const PostTemplate = `#{{.Id}} {{.Name}}{{.Trip}} on {{.Time.Format "02 Jan 06 15:04 MST"}}:
+{{with .File}}{{.String}}{{end}}{{.CommentHTML}}`
+
+// NewFormatter parses a template for rendering posts.
+func NewFormatter(text string) (*Formatter, error) {
+	tmpl, err := template.New("post").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Formatter{tmpl}, nil
+}
+
+// FormatPost writes the post to w using the template.
+func (self *Formatter) FormatPost(w io.Writer, post *Post) error {
+	return self.tmpl.Execute(w, post)
+}
+
+// FormatThread writes every post of the thread to w using the template, with
+// sep written between posts.
+func (self *Formatter) FormatThread(w io.Writer, thread *Thread, sep string) error {
+	for i, post := range thread.Posts {
+		if i > 0 {
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+		}
+		if err := self.FormatPost(w, post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
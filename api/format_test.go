@@ -0,0 +1,30 @@
+package api
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormatter(t *testing.T) {
+	file, err := os.Open("example.json")
+	try(t, err)
+	defer file.Close()
+	thread, err := ParseThread(file, "ck")
+	try(t, err)
+
+	f, err := NewFormatter(PostTemplate)
+	try(t, err)
+	var b strings.Builder
+	try(t, f.FormatThread(&b, thread, "\n\n"))
+	assert(t, b.String()+"\n\n" == thread.String(), "PostTemplate should render like String")
+
+	f, err = NewFormatter(`{{.DisplayName}} No.{{.Id}}`)
+	try(t, err)
+	b.Reset()
+	try(t, f.FormatPost(&b, thread.OP))
+	assert(t, b.String() == "Anonymous No.3856791", "Templates should be able to call methods: "+b.String())
+
+	_, err = NewFormatter(`{{.Nope`)
+	assert(t, err != nil, "Bad templates should not parse")
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The seeds are kept small; large inputs make the fuzzer spend most of its
+// time minimizing.
+func addSeeds(f *testing.F) {
+	f.Add([]byte(`{"posts":[{"no":1,"resto":0,"name":"Anonymous","com":"<span class=\"quote\">&gt;hi</span><br>http://a.b/c<wbr>d","filename":"f","ext":".jpg","tim":1346968817055,"md5":"BUHVGqnNwVyy8TMVevQuMw=="},{"no":2,"resto":1,"com":"<a href=\"1#p1\" class=\"quotelink\">&gt;&gt;1</a>"}]}`))
+	f.Add([]byte(`{"threads":[{"posts":[{"no":1,"resto":0,"replies":1,"omitted_posts":3}]}]}`))
+	f.Add([]byte(`{"posts":[]}`))
+	f.Add([]byte(`{"posts":[null]}`))
+	f.Add([]byte(`{"threads":[{"posts":[]}]}`))
+	f.Add([]byte(`{"posts":[{"no":1,"fsize":-1,"filename":"x","w":-5}]}`))
+}
+
+// checkThread exercises the accessors that a caller would use on a thread
+// the parser accepted.
+func checkThread(t *testing.T, thread *Thread) {
+	if thread == nil || thread.OP == nil || len(thread.Posts) == 0 {
+		t.Fatal("Parser accepted a thread without posts")
+	}
+	_ = thread.String()
+	_ = thread.Id()
+	for _, post := range thread.Posts {
+		_ = post.ImageURL()
+		_ = post.ThumbURL()
+		_ = post.Links()
+		_ = post.SanitizedHTML()
+	}
+}
+
+func FuzzParseThread(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		thread, err := ParseThread(bytes.NewReader(data), "a")
+		if err == nil {
+			checkThread(t, thread)
+		}
+	})
+}
+
+func FuzzParseIndex(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		threads, err := ParseIndex(bytes.NewReader(data), "a")
+		if err == nil {
+			for _, thread := range threads {
+				checkThread(t, thread)
+			}
+		}
+	})
+}
+
+func FuzzSalvageThread(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		thread, _ := SalvageThread(bytes.NewReader(data), "a")
+		if thread != nil {
+			checkThread(t, thread)
+		}
+	})
+}
+
+func FuzzParseThreads(f *testing.F) {
+	f.Add([]byte(`[{"page":1,"threads":[{"no":1,"last_modified":1,"replies":0}]}]`))
+	f.Add([]byte(`[{"page":0,"threads":[]}]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ParseThreads(bytes.NewReader(data))
+	})
+}
+
+func FuzzParseCatalog(f *testing.F) {
+	f.Add([]byte(`[{"page":1,"threads":[{"no":1,"resto":0,"replies":2,"last_replies":[{"no":2,"resto":1}]}]}]`))
+	f.Add([]byte(`[{"page":1,"threads":[null]}]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cat, err := ParseCatalog(bytes.NewReader(data), "a")
+		if err == nil {
+			for _, page := range cat {
+				for _, thread := range page.Threads {
+					checkThread(t, thread)
+				}
+			}
+		}
+	})
+}
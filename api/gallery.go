@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// GalleryVersion is the version of the gallery manifest schema. It only
+// changes if fields are removed or change meaning; new fields may be added
+// without changing it.
+const GalleryVersion = 1
+
+// A Gallery is a manifest of the files posted in one or more threads, meant
+// for generating image viewers from. It marshals to a stable JSON schema.
+type Gallery struct {
+	Version   int           `json:"version"`
+	Generated time.Time     `json:"generated"`
+	Items     []GalleryItem `json:"items"`
+}
+
+// A GalleryItem is a single file in a Gallery.
+type GalleryItem struct {
+	Board       string    `json:"board"`
+	Thread      int64     `json:"thread"`
+	Post        int64     `json:"post"`
+	Time        time.Time `json:"time"`
+	Id          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Ext         string    `json:"ext"`
+	Size        int       `json:"size"`
+	MD5         string    `json:"md5"` // hex encoded
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	ThumbWidth  int       `json:"thumb_width"`
+	ThumbHeight int       `json:"thumb_height"`
+	Spoiler     bool      `json:"spoiler,omitempty"`
+	URL         string    `json:"url"`
+	ThumbURL    string    `json:"thumb_url"`
+}
+
+// NewGallery builds the gallery of the files in the given threads, in the
+// order they were posted in each thread. Deleted files are left out.
+func NewGallery(threads ...*Thread) *Gallery {
+	g := &Gallery{
+		Version:   GalleryVersion,
+		Generated: DefaultClock.Now().UTC(),
+		Items:     []GalleryItem{},
+	}
+	for _, thread := range threads {
+		for _, post := range thread.FilePosts() {
+			f := post.File
+			if f.Deleted {
+				continue
+			}
+			g.Items = append(g.Items, GalleryItem{
+				Board:       thread.Board,
+				Thread:      thread.Id(),
+				Post:        post.Id,
+				Time:        post.Time.UTC(),
+				Id:          f.Id,
+				Name:        f.Name,
+				Ext:         f.Ext,
+				Size:        f.Size,
+				MD5:         hex.EncodeToString(f.MD5),
+				Width:       f.Width,
+				Height:      f.Height,
+				ThumbWidth:  f.ThumbWidth,
+				ThumbHeight: f.ThumbHeight,
+				Spoiler:     f.Spoiler,
+				URL:         post.ImageURL(),
+				ThumbURL:    post.ThumbURL(),
+			})
+		}
+	}
+	return g
+}
+
+// WriteGallery writes the gallery of the given threads to w as indented JSON.
+func WriteGallery(w io.Writer, threads ...*Thread) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(NewGallery(threads...))
+}
@@ -0,0 +1,59 @@
+//go:build go1.23
+
+package api
+
+import (
+	"errors"
+	"iter"
+)
+
+// All returns an iterator over the posts in the thread, in order.
+func (self *Thread) All() iter.Seq[*Post] {
+	return func(yield func(*Post) bool) {
+		if self == nil {
+			return
+		}
+		for _, post := range self.Posts {
+			if !yield(post) {
+				return
+			}
+		}
+	}
+}
+
+// Threads returns an iterator over the threads on every page of the catalog,
+// in order.
+func (self Catalog) Threads() iter.Seq[*Thread] {
+	return func(yield func(*Thread) bool) {
+		for _, page := range self {
+			for _, thread := range page.Threads {
+				if !yield(thread) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// IndexPages returns an iterator over the index pages of a board, starting
+// from the first. Each page is only fetched when the loop gets to it, so
+// breaking out early saves requests. The number of pages is looked up in the
+// board list, and iteration also stops without an error when a page turns out
+// not to exist. Any other error is yielded and ends the iteration.
+func IndexPages(board string) iter.Seq2[[]*Thread, error] {
+	return func(yield func([]*Thread, error) bool) {
+		pages := -1
+		if b, err := LookupBoard(board); err == nil && b.Pages > 0 {
+			pages = b.Pages
+		}
+		for page := 0; pages < 0 || page < pages; page++ {
+			threads, err := GetIndex(board, page)
+			if errors.Is(err, ErrNotFound) {
+				return
+			}
+			if !yield(threads, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build go1.23
+
+package api
+
+import (
+	"os"
+	"testing"
+)
+
+func TestThreadAll(t *testing.T) {
+	file, err := os.Open("example.json")
+	try(t, err)
+	defer file.Close()
+	thread, err := ParseThread(file, "ck")
+	try(t, err)
+
+	n := 0
+	for post := range thread.All() {
+		assert(t, post == thread.Posts[n], "Posts should be iterated in order")
+		n++
+		if n == 5 {
+			break
+		}
+	}
+	assert(t, n == 5, "Iteration should stop when the loop breaks")
+}
+
+func TestCatalogThreads(t *testing.T) {
+	a, b, c := &Thread{}, &Thread{}, &Thread{}
+	cat := Catalog{{1, []*Thread{a, b}}, {2, []*Thread{c}}}
+
+	var got []*Thread
+	for thread := range cat.Threads() {
+		got = append(got, thread)
+	}
+	assert(t, len(got) == 3 && got[0] == a && got[1] == b && got[2] == c, "Catalog threads should be iterated in page order")
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Coordinator hands out turns to send requests when several processes share
+// the API's rate limit. Within a process, requests already take turns through
+// the priority scheduler; a Coordinator makes processes take turns as well.
+type Coordinator interface {
+	// Wait blocks until the process may send a request, or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// If RequestCoordinator is set, every request also waits for its turn from it,
+// after its turn from the local rate limiter. A request fails if it can't get
+// one, rather than being sent uncoordinated. It should not be changed while
+// requests are being made.
+var RequestCoordinator Coordinator
+
+// A Leader coordinates the requests of a fleet of processes on one machine,
+// so that between them they send at most one request a second, or fewer if
+// Budget needs it. One process runs the Leader on a local socket, and every
+// process, that one included, sets RequestCoordinator to a LeaderClient
+// dialling it.
+//
+// Each turn is asked for with a new connection, and granted by writing a
+// single byte to it. Turns are granted in the order they are asked for.
+type Leader struct {
+	// Budget is the request budget that the fleet shares.
+	Budget *Budget
+
+	listener net.Listener
+	queue    chan net.Conn
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// ListenLeader starts a Leader listening on the given address, usually a unix
+// socket. It runs until Close is called.
+func ListenLeader(network, address string, budget *Budget) (*Leader, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("api: leader: %w", err)
+	}
+	self := &Leader{
+		Budget:   budget,
+		listener: l,
+		queue:    make(chan net.Conn, 64),
+		done:     make(chan struct{}),
+	}
+	self.wg.Add(2)
+	go self.accept()
+	go self.grant()
+	return self, nil
+}
+
+// Addr returns the address the Leader is listening on.
+func (self *Leader) Addr() net.Addr {
+	return self.listener.Addr()
+}
+
+// Close stops the Leader. Processes waiting for a turn get an error.
+func (self *Leader) Close() error {
+	close(self.done)
+	err := self.listener.Close()
+	self.wg.Wait()
+	for {
+		select {
+		case conn := <-self.queue:
+			conn.Close()
+		default:
+			return err
+		}
+	}
+}
+
+func (self *Leader) interval() time.Duration {
+	if d := self.Budget.Interval(); d > time.Second {
+		return d
+	}
+	return time.Second
+}
+
+func (self *Leader) accept() {
+	defer self.wg.Done()
+	for {
+		conn, err := self.listener.Accept()
+		if err != nil {
+			return
+		}
+		select {
+		case self.queue <- conn:
+		case <-self.done:
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (self *Leader) grant() {
+	defer self.wg.Done()
+	var next <-chan time.Time
+	for {
+		if next != nil {
+			select {
+			case <-next:
+			case <-self.done:
+				return
+			}
+		}
+		select {
+		case conn := <-self.queue:
+			// a turn granted to a process that has given up on it is
+			// wasted rather than handed on, to stay on the safe side
+			conn.Write([]byte{1})
+			conn.Close()
+			next = DefaultClock.After(self.interval())
+		case <-self.done:
+			return
+		}
+	}
+}
+
+// A LeaderClient is a Coordinator that gets its turns from a Leader.
+type LeaderClient struct {
+	Network string
+	Address string
+}
+
+func (self *LeaderClient) Wait(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, self.Network, self.Address)
+	if err != nil {
+		return fmt.Errorf("api: waiting for the leader: %w", err)
+	}
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	var b [1]byte
+	if _, err := conn.Read(b[:]); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("api: waiting for the leader: %w", err)
+	}
+	return nil
+}
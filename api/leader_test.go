@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leader")
+	try(t, err)
+	defer os.RemoveAll(dir)
+	defer func(c Clock) { DefaultClock = c }(DefaultClock)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+
+	socket := filepath.Join(dir, "leader.sock")
+	leader, err := ListenLeader("unix", socket, &Budget{PerHour: 1800})
+	try(t, err)
+	client := &LeaderClient{"unix", socket}
+
+	const n = 3
+	granted := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() { granted <- client.Wait(context.Background()) }()
+	}
+	got := func() int {
+		count := 0
+		for {
+			select {
+			case err := <-granted:
+				try(t, err)
+				count++
+			case <-time.After(50 * time.Millisecond):
+				return count
+			}
+		}
+	}
+	waitTimer := func() {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	assert(t, got() == 1, "The first turn should be granted straight away")
+	for i := 1; i < n; i++ {
+		waitTimer()
+		clock.Advance(time.Second)
+		assert(t, got() == 0, "Turns should be spaced out by the budget")
+		clock.Advance(time.Second)
+		assert(t, got() == 1, "One turn should be granted per interval")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error, 1)
+	go func() { waited <- client.Wait(ctx) }()
+	cancel()
+	assert(t, errors.Is(<-waited, context.Canceled), "Waiting should stop when the context is cancelled")
+
+	try(t, leader.Close())
+	assert(t, client.Wait(context.Background()) != nil, "Waiting for a closed leader should fail")
+}
+
+type failingCoordinator struct{ err error }
+
+func (self failingCoordinator) Wait(ctx context.Context) error {
+	return self.err
+}
+
+func TestRequestCoordinator(t *testing.T) {
+	_, restore := serveStatuses(map[string]string{"/g/thread/1.json": `{"posts":[{"no":1,"resto":0}]}`}, nil)
+	defer restore()
+	sent := false
+	transport := HTTPClient.Transport
+	HTTPClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = true
+		return transport.RoundTrip(req)
+	})
+	defer func() { RequestCoordinator = nil }()
+
+	failure := errors.New("no leader")
+	RequestCoordinator = failingCoordinator{failure}
+	_, err := GetThread("g", 1)
+	assert(t, errors.Is(err, failure) && !sent, "A request without a turn shouldn't be sent")
+
+	RequestCoordinator = failingCoordinator{nil}
+	_, err = GetThread("g", 1)
+	try(t, err)
+	assert(t, sent, "A request with a turn should be sent")
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// A LimiterStatus describes how busy the request rate limiter is, as returned
+// by Limiter.
+type LimiterStatus struct {
+	// Interval is the time left between requests: one second, or longer if
+	// RequestBudget needs it.
+	Interval time.Duration `json:"interval"`
+	// Waiting is the number of requests queued for their turn to be sent.
+	Waiting int `json:"waiting"`
+	// LastSent is when the last request was sent, or zero if none has been.
+	LastSent time.Time `json:"last_sent"`
+	// Saturation is the fraction of the limiter's capacity that was used
+	// recently, from 0 to 1. It nears 1 when requests are being made as fast
+	// as the limiter lets them through, at which point they start to queue
+	// up behind each other.
+	Saturation float64 `json:"saturation"`
+}
+
+// Saturation is measured over the time it takes to send this many requests.
+const saturationWindow = 60
+
+var (
+	recentSends      [saturationWindow]time.Time
+	recentSendsNext  int
+	recentSendsMutex sync.Mutex
+)
+
+// recordSend records that a request was sent at t.
+func recordSend(t time.Time) {
+	recentSendsMutex.Lock()
+	recentSends[recentSendsNext] = t
+	recentSendsNext = (recentSendsNext + 1) % saturationWindow
+	recentSendsMutex.Unlock()
+}
+
+// Limiter returns the current state of the request rate limiter, for status
+// pages and health checks.
+func Limiter() LimiterStatus {
+	status := LimiterStatus{
+		Interval: requestInterval(),
+		Waiting:  requestScheduler.queued(),
+	}
+	since := DefaultClock.Now().Add(-saturationWindow * status.Interval)
+	sent := 0
+	recentSendsMutex.Lock()
+	for _, t := range recentSends {
+		if t.After(status.LastSent) {
+			status.LastSent = t
+		}
+		if !t.IsZero() && t.After(since) {
+			sent++
+		}
+	}
+	recentSendsMutex.Unlock()
+	status.Saturation = float64(sent) / saturationWindow
+	return status
+}
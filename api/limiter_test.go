@@ -0,0 +1,44 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	defer func(c *http.Client) { HTTPClient = c }(HTTPClient)
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"posts":[{"no":1,"resto":0}]}`)),
+			Request:    req,
+		}, nil
+	})}
+	defer func(c Clock) { DefaultClock = c }(DefaultClock)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+	defer func() { recentSends, recentSendsNext, cooldown = [saturationWindow]time.Time{}, 0, nil }()
+	recentSends, recentSendsNext = [saturationWindow]time.Time{}, 0
+
+	status := Limiter()
+	assert(t, status.Interval == time.Second, "The interval should be the rate limit")
+	assert(t, status.Saturation == 0 && status.LastSent.IsZero(), "Nothing should have been sent yet")
+
+	for i := 0; i < 30; i++ {
+		cooldown = nil
+		_, err := GetThread("g", 1)
+		try(t, err)
+		clock.Advance(time.Second)
+	}
+	status = Limiter()
+	assert(t, status.Saturation == 0.5, "Sending every second for half the window should use half the capacity")
+	assert(t, status.LastSent.Equal(clock.Now().Add(-time.Second)), "The last send should be recorded")
+	assert(t, status.Waiting == 0, "Nothing should be waiting")
+
+	clock.Advance(time.Hour)
+	assert(t, Limiter().Saturation == 0, "Old sends should no longer count")
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// A Link points at a thread on the site, or at a single post in it.
+type Link struct {
+	Board  string
+	Thread int64
+	Post   int64  // the post to jump to, or 0 for the top of the thread
+	Slug   string // the thread's semantic URL slug, if known
+}
+
+// String returns the URL of the link, like
+// https://boards.4chan.org/g/thread/123/thread-subject#p456.
+func (self Link) String() string {
+	url := fmt.Sprintf("%s%s/%s/thread/%d", prefix(), SiteURL, self.Board, self.Thread)
+	if self.Slug != "" {
+		url += "/" + self.Slug
+	}
+	if self.Post != 0 {
+		url += fmt.Sprintf("#p%d", self.Post)
+	}
+	return url
+}
+
+var linkURLPattern = regexp.MustCompile(`^(?:(?:https?:)?//boards\.4chan(?:nel)?\.org)?/(\w+)/(?:thread|res)/(\d+)(?:\.html)?(?:/([\w-]+))?/?(?:#[pq](\d+))?$`)
+
+// ParseLink is the reverse of Link.String. It accepts thread URLs with or
+// without the slug and post anchor, including the old /res/123.html form,
+// #q (quote) anchors, and links relative to the site.
+func ParseLink(url string) (Link, error) {
+	m := linkURLPattern.FindStringSubmatch(url)
+	if m == nil {
+		return Link{}, fmt.Errorf("api: %q is not a link to a thread", url)
+	}
+	link := Link{Board: m[1], Slug: m[3]}
+	link.Thread, _ = strconv.ParseInt(m[2], 10, 64)
+	link.Post, _ = strconv.ParseInt(m[4], 10, 64)
+	return link, nil
+}
+
+// Link returns a link to the thread.
+func (self *Thread) Link() Link {
+	return Link{Board: self.Board, Thread: self.Id(), Slug: self.SemanticURL()}
+}
+
+// URL returns the URL of the thread on the site.
+func (self *Thread) URL() string {
+	return self.Link().String()
+}
+
+// SemanticURL returns the slug that the site puts at the end of the thread's
+// URL, which is derived from its subject or comment, or "" if the API didn't
+// give one.
+func (self *Thread) SemanticURL() string {
+	return self.op().semantic_url
+}
+
+// Link returns a link to the post within its thread.
+func (self *Post) Link() Link {
+	if self.Thread == nil {
+		return Link{Post: self.Id}
+	}
+	link := self.Thread.Link()
+	link.Post = self.Id
+	return link
+}
+
+// URL returns the URL of the post on the site, which jumps to the post when
+// opened.
+func (self *Post) URL() string {
+	return self.Link().String()
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkURLs(t *testing.T) {
+	defer func(ssl bool) { SSL = ssl }(SSL)
+	SSL = true
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":123,"resto":0,"semantic_url":"white-bread"},{"no":456,"resto":123}]}`), "ck")
+	try(t, err)
+	assert(t, thread.URL() == "https://boards.4chan.org/ck/thread/123/white-bread", "Thread URL should include the slug")
+	assert(t, thread.Posts[1].URL() == "https://boards.4chan.org/ck/thread/123/white-bread#p456", "Post URL should have an anchor")
+
+	for url, want := range map[string]Link{
+		"https://boards.4chan.org/ck/thread/123/white-bread#p456": {"ck", 123, 456, "white-bread"},
+		"//boards.4channel.org/g/thread/123#q456":                 {"g", 123, 456, ""},
+		"/g/res/123.html":                       {"g", 123, 0, ""},
+		"http://boards.4chan.org/g/thread/123/": {"g", 123, 0, ""},
+	} {
+		link, err := ParseLink(url)
+		try(t, err)
+		assert(t, link == want, "ParseLink("+url+") should give "+want.String())
+	}
+	_, err = ParseLink("https://example.com/g/thread/123")
+	assert(t, err != nil, "Links to other sites should be rejected")
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"sort"
+)
+
+// MergeFrom reconciles the thread with another copy of the same thread, for
+// example a stub from an index page with the full thread fetched later. Posts
+// are matched up by ID; posts only present in other are added to the thread
+// in order, and for posts present in both, fields that are empty in the
+// thread's copy are filled in from other's. Counters such as the number of
+// replies take the larger of the two values, and flags such as Sticky are
+// set if either copy has them set.
+func (self *Thread) MergeFrom(other *Thread) {
+	if other == nil || other == self {
+		return
+	}
+	if self.Board == "" {
+		self.Board = other.Board
+	}
+	newer := other.date_recieved.After(self.date_recieved)
+	if newer {
+		self.date_recieved = other.date_recieved
+	}
+	// keep the most recent known position in the bump order
+	if other.page != 0 && (newer || self.page == 0) {
+		self.page, self.page_index, self.bump_position = other.page, other.page_index, other.bump_position
+	}
+
+	byId := make(map[int64]*Post, len(self.Posts))
+	for _, post := range self.Posts {
+		byId[post.Id] = post
+	}
+	added := false
+	for _, post := range other.Posts {
+		if mine, ok := byId[post.Id]; ok {
+			mine.mergeFrom(post)
+			continue
+		}
+		post.Thread = self
+		if post.File != nil {
+			post.File.Post = post
+		}
+		self.Posts = append(self.Posts, post)
+		byId[post.Id] = post
+		added = true
+	}
+	if added {
+		sort.SliceStable(self.Posts, func(i, j int) bool {
+			return self.Posts[i].Id < self.Posts[j].Id
+		})
+	}
+
+	if self.OP == nil && other.OP != nil {
+		self.OP = byId[other.OP.Id]
+	}
+}
+
+func (self *Post) mergeFrom(other *Post) {
+	mergeString(&self.Now, other.Now)
+	mergeString(&self.Subject, other.Subject)
+	mergeString(&self.Name, other.Name)
+	mergeString(&self.Trip, other.Trip)
+	mergeString(&self.Email, other.Email)
+	mergeString(&self.Special, other.Special)
+	mergeString(&self.Capcode, other.Capcode)
+	mergeString(&self.Country, other.Country)
+	mergeString(&self.CountryName, other.CountryName)
+	mergeString(&self.BoardFlag, other.BoardFlag)
+	mergeString(&self.FlagName, other.FlagName)
+	mergeString(&self.semantic_url, other.semantic_url)
+	if self.CommentHTML() == "" {
+		self.Comment, self.comment_z = other.Comment, other.comment_z
+	}
+	if self.Time.IsZero() {
+		self.Time = other.Time
+	}
+	if other.LastModified > self.LastModified {
+		self.LastModified = other.LastModified
+	}
+
+	mergeMax(&self.replies, other.replies)
+	mergeMax(&self.images, other.images)
+	mergeMax(&self.omitted_posts, other.omitted_posts)
+	mergeMax(&self.omitted_images, other.omitted_images)
+	mergeMax(&self.custom_spoiler, other.custom_spoiler)
+	self.bump_limit = self.bump_limit || other.bump_limit
+	self.image_limit = self.image_limit || other.image_limit
+	self.sticky = self.sticky || other.sticky
+	self.closed = self.closed || other.closed
+	self.archived = self.archived || other.archived
+	mergeMax64(&self.archived_on, other.archived_on)
+
+	if self.File == nil && other.File != nil {
+		self.File = other.File
+		self.File.Post = self
+	}
+	if self.CapcodeReplies.Len() < other.CapcodeReplies.Len() {
+		self.CapcodeReplies = other.CapcodeReplies
+	}
+	for name, value := range other.Annotations {
+		if _, ok := self.Annotations[name]; !ok {
+			self.Annotate(name, value)
+		}
+	}
+}
+
+func mergeString(dst *string, src string) {
+	if *dst == "" {
+		*dst = src
+	}
+}
+
+func mergeMax(dst *int, src int) {
+	if src > *dst {
+		*dst = src
+	}
+}
+
+func mergeMax64(dst *int64, src int64) {
+	if src > *dst {
+		*dst = src
+	}
+}
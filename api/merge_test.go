@@ -0,0 +1,26 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeFrom(t *testing.T) {
+	stub, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"sub":"hi","replies":3,"omitted_posts":1},{"no":4,"resto":1,"com":"four"}]}`), "g")
+	try(t, err)
+	full, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"com":"op","replies":4,"sticky":1},{"no":2,"resto":1},{"no":3,"resto":1,"filename":"x","ext":".png"},{"no":4,"resto":1,"com":"edited"}]}`), "g")
+	try(t, err)
+
+	stub.MergeFrom(full)
+
+	assert(t, len(stub.Posts) == 4, "Merged thread should have 4 posts")
+	for i, post := range stub.Posts {
+		assert(t, post.Id == int64(i+1), "Merged posts should be in order")
+		assert(t, post.Thread == stub, "Merged posts should belong to the merged thread")
+	}
+	assert(t, stub.OP.Subject == "hi" && stub.OP.Comment == "op", "OP should have fields from both copies")
+	assert(t, stub.Replies() == 4 && stub.OmittedPosts() == 1, "Counters should take the larger value")
+	assert(t, stub.Sticky(), "Flags should be set from either copy")
+	assert(t, stub.Posts[2].File != nil && stub.Posts[2].File.Post == stub.Posts[2], "Added post should keep its file")
+	assert(t, stub.Posts[3].Comment == "four", "Existing non-empty fields should be kept")
+}
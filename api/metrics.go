@@ -0,0 +1,61 @@
+package api
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CommentMetrics are simple measurements of a post's comment.
+type CommentMetrics struct {
+	Lines          int     // non-blank lines
+	GreentextLines int     // lines starting with >, not counting quotelinks
+	Quotelinks     int     // links to other posts, including cross-board links
+	Words          int     // whitespace separated words
+	UppercaseRatio float64 // the fraction of letters that are uppercase, from 0 to 1
+}
+
+// Metrics measures the post's comment.
+func (self *Post) Metrics() CommentMetrics {
+	var m CommentMetrics
+	m.Quotelinks = strings.Count(self.CommentHTML(), `class="quotelink"`)
+
+	text := self.PlainText()
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m.Lines++
+		if strings.HasPrefix(line, ">") && !isQuoteLine(line) {
+			m.GreentextLines++
+		}
+	}
+	m.Words = len(strings.Fields(text))
+
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters > 0 {
+		m.UppercaseRatio = float64(upper) / float64(letters)
+	}
+	return m
+}
+
+// isQuoteLine reports whether a line starts with a quotelink like >>123 or
+// >>>/g/123 rather than being greentext.
+func isQuoteLine(line string) bool {
+	rest := strings.TrimLeft(line, ">")
+	switch len(line) - len(rest) {
+	case 2:
+		return rest != "" && rest[0] >= '0' && rest[0] <= '9'
+	case 3:
+		return strings.HasPrefix(rest, "/")
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	post := &Post{Comment: `<a href="#p1" class="quotelink">&gt;&gt;1</a><br>` +
+		`<span class="quote">&gt;be me</span><br>` +
+		`<span class="quote">&gt;&gt;&gt;implying</span><br><br>` +
+		`<a href="/g/thread/5#p5" class="quotelink">&gt;&gt;&gt;/g/5</a> WHAT is this`}
+	m := post.Metrics()
+	assert(t, m.Lines == 4, fmt.Sprint("Blank lines should not be counted: ", m.Lines))
+	assert(t, m.GreentextLines == 2, fmt.Sprint("Quotelinks should not count as greentext: ", m.GreentextLines))
+	assert(t, m.Quotelinks == 2, "Both quotelinks should be counted")
+	assert(t, m.Words == 8, fmt.Sprint("Words should be counted: ", m.Words))
+	assert(t, m.UppercaseRatio == 4.0/23, fmt.Sprint("The uppercase ratio should be of letters only: ", m.UppercaseRatio))
+
+	assert(t, (&Post{}).Metrics() == CommentMetrics{}, "An empty comment should have no metrics")
+}
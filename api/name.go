@@ -0,0 +1,47 @@
+package api
+
+import (
+	"html"
+	"strings"
+)
+
+// Tripcodes splits the post's Trip into its normal tripcode (!abc) and secure
+// tripcode (!!def). Either may be empty; a post with both has a Trip of
+// !abc!!def.
+func (self *Post) Tripcodes() (trip, secure string) {
+	if i := strings.Index(self.Trip, "!!"); i >= 0 {
+		return self.Trip[:i], self.Trip[i:]
+	}
+	return self.Trip, ""
+}
+
+// capcodeNames are how capcodes are written after the name.
+var capcodeNames = map[string]string{
+	"admin":           "Admin",
+	"admin_highlight": "Admin",
+	"mod":             "Mod",
+	"manager":         "Manager",
+	"developer":       "Developer",
+	"founder":         "Founder",
+	"verified":        "Verified",
+}
+
+// DisplayName returns the poster's name the way the site shows it: the name,
+// then the tripcode, then the capcode, e.g. "moot !Ep8pui8Vw2 ## Admin".
+func (self *Post) DisplayName() string {
+	parts := make([]string, 0, 3)
+	if self.Name != "" {
+		parts = append(parts, html.UnescapeString(self.Name))
+	}
+	if self.Trip != "" {
+		parts = append(parts, self.Trip)
+	}
+	if self.Capcode != "" && self.Capcode != "none" {
+		name, ok := capcodeNames[self.Capcode]
+		if !ok {
+			name = strings.ToUpper(self.Capcode[:1]) + self.Capcode[1:]
+		}
+		parts = append(parts, "## "+name)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestTripcodes(t *testing.T) {
+	cases := []struct{ trip, normal, secure string }{
+		{"", "", ""},
+		{"!Ep8pui8Vw2", "!Ep8pui8Vw2", ""},
+		{"!!Abcdef1234", "", "!!Abcdef1234"},
+		{"!Ep8pui8Vw2!!Abcdef1234", "!Ep8pui8Vw2", "!!Abcdef1234"},
+	}
+	for _, c := range cases {
+		normal, secure := (&Post{Trip: c.trip}).Tripcodes()
+		if normal != c.normal || secure != c.secure {
+			t.Errorf("%q split into %q, %q", c.trip, normal, secure)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	cases := []struct {
+		post Post
+		name string
+	}{
+		{Post{Name: "Anonymous"}, "Anonymous"},
+		{Post{Name: "moot", Trip: "!Ep8pui8Vw2", Capcode: "admin"}, "moot !Ep8pui8Vw2 ## Admin"},
+		{Post{Name: "Tom &amp; Jerry", Capcode: "mod"}, "Tom & Jerry ## Mod"},
+		{Post{Trip: "!!Abcdef1234", Capcode: "janitor"}, "!!Abcdef1234 ## Janitor"},
+	}
+	for _, c := range cases {
+		if got := c.post.DisplayName(); got != c.name {
+			t.Errorf("DisplayName() = %q, expected %q", got, c.name)
+		}
+	}
+}
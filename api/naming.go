@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/hex"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A NamingStrategy decides the name a post's file is saved under by
+// Downloader.DownloadTo. The result is sanitized before use, so strategies
+// don't need to worry about unsafe characters.
+type NamingStrategy func(file *File) string
+
+var (
+	// NameByTim names files after the server's renamed filename, e.g.
+	// 1346968817055.jpg. Names are unique within a board.
+	NameByTim NamingStrategy = func(file *File) string {
+		return fmt.Sprintf("%d%s", file.Id, file.Ext)
+	}
+	// NameByOriginal names files after the name they were uploaded with. If
+	// a different file with the same name already exists, the renamed
+	// filename is added as a suffix, e.g. image_1346968817055.jpg.
+	NameByOriginal NamingStrategy = func(file *File) string {
+		return html.UnescapeString(file.Name) + file.Ext
+	}
+	// NameByMD5 names files after the hex encoding of their MD5, so the same
+	// file posted several times is only stored once.
+	NameByMD5 NamingStrategy = func(file *File) string {
+		if len(file.MD5) == 0 {
+			return NameByTim(file)
+		}
+		return hex.EncodeToString(file.MD5) + file.Ext
+	}
+)
+
+// the longest name, in bytes, that SanitizeFilename returns
+const maxFilenameLength = 200
+
+// SanitizeFilename makes name safe to use as a single file name on common
+// file systems: path separators, control characters and characters that
+// Windows doesn't allow are replaced, leading dots and trailing dots and
+// spaces are removed, and the name is shortened to at most 200 bytes while
+// keeping its extension. The result is empty if nothing usable is left.
+func SanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ". ")
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) > maxFilenameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := name[:maxFilenameLength-len(ext)]
+		// don't cut a character in half
+		for len(base) > 0 && !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = base + ext
+	}
+	return name
+}
+
+// DownloadTo saves the file attached to post into dir, naming it with the
+// Downloader's Naming strategy (NameByTim if it is nil), and returns the path
+// it was saved at. If a file by that name already exists and has the right
+// MD5, nothing is downloaded. If it exists but is a different file, the
+// renamed filename is added to the name to tell them apart.
+func (self *Downloader) DownloadTo(post *Post, dir string) (string, error) {
+	if err := self.check(post); err != nil {
+		return "", err
+	}
+	file := post.File
+	naming := self.Naming
+	if naming == nil {
+		naming = NameByTim
+	}
+	name := SanitizeFilename(naming(file))
+	if name == "" {
+		name = NameByTim(file)
+	}
+
+	path := filepath.Join(dir, name)
+	if f, err := os.Open(path); err == nil {
+		err = verifyMD5(f, file.MD5)
+		f.Close()
+		if err == nil {
+			return path, nil
+		}
+		ext := filepath.Ext(name)
+		path = filepath.Join(dir, fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), file.Id, ext))
+	}
+	return path, self.DownloadFile(post, path)
+}
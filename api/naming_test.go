@@ -0,0 +1,32 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for _, c := range []struct{ in, out string }{
+		{"image.jpg", "image.jpg"},
+		{"../../etc/passwd", "_.._etc_passwd"},
+		{"a\x00b\nc.png", "abc.png"},
+		{`what?<is>"this".gif`, "what__is__this_.gif"},
+		{"...hidden", "hidden"},
+		{"trailing. ", "trailing"},
+		{"..", ""},
+	} {
+		got := SanitizeFilename(c.in)
+		assert(t, got == c.out, "SanitizeFilename("+c.in+") should be '"+c.out+"' (got '"+got+"')")
+	}
+
+	long := SanitizeFilename(strings.Repeat("日本", 100) + ".webm")
+	assert(t, len(long) <= maxFilenameLength, "Long names should be shortened")
+	assert(t, strings.HasSuffix(long, ".webm"), "Shortened names should keep their extension")
+}
+
+func TestNamingStrategies(t *testing.T) {
+	file := &File{Id: 1346968817055, Name: "bread &amp; butter", Ext: ".jpg", MD5: []byte{0xde, 0xad, 0xbe, 0xef}}
+	assert(t, NameByTim(file) == "1346968817055.jpg", "NameByTim should use the renamed filename")
+	assert(t, NameByOriginal(file) == "bread & butter.jpg", "NameByOriginal should use the original filename")
+	assert(t, NameByMD5(file) == "deadbeef.jpg", "NameByMD5 should use the MD5")
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+// A Priority determines the order in which queued requests are sent. Whenever
+// the rate limiter lets a request through, the oldest waiting request with the
+// highest priority goes first.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx that causes requests made with it to be
+// queued at the given priority. Requests made without a priority are queued
+// at PriorityNormal.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityOf(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		if p < PriorityLow {
+			return PriorityLow
+		}
+		if p > PriorityHigh {
+			return PriorityHigh
+		}
+		return p
+	}
+	return PriorityNormal
+}
+
+// scheduler hands out exclusive turns to send a request, preferring waiters
+// with a higher priority.
+type scheduler struct {
+	mu      sync.Mutex
+	busy    bool
+	waiting [PriorityHigh + 1][]chan struct{}
+}
+
+var requestScheduler scheduler
+
+// acquire blocks until it is the caller's turn to send a request, or ctx is
+// done. Every successful acquire must be paired with a release.
+func (self *scheduler) acquire(ctx context.Context) error {
+	self.mu.Lock()
+	if !self.busy {
+		self.busy = true
+		self.mu.Unlock()
+		return nil
+	}
+	p := priorityOf(ctx)
+	turn := make(chan struct{})
+	self.waiting[p] = append(self.waiting[p], turn)
+	self.mu.Unlock()
+
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+		self.mu.Lock()
+		defer self.mu.Unlock()
+		for i, c := range self.waiting[p] {
+			if c == turn {
+				self.waiting[p] = append(self.waiting[p][:i], self.waiting[p][i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// the turn was handed over while we were giving up, so pass it on
+		self.handoff()
+		return ctx.Err()
+	}
+}
+
+// queued returns the number of callers waiting for their turn.
+func (self *scheduler) queued() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	n := 0
+	for _, waiting := range self.waiting {
+		n += len(waiting)
+	}
+	return n
+}
+
+// release gives the turn to the next waiter, if any.
+func (self *scheduler) release() {
+	self.mu.Lock()
+	self.handoff()
+	self.mu.Unlock()
+}
+
+func (self *scheduler) handoff() {
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		if len(self.waiting[p]) > 0 {
+			turn := self.waiting[p][0]
+			self.waiting[p] = self.waiting[p][1:]
+			close(turn)
+			return
+		}
+	}
+	self.busy = false
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedulerPriority(t *testing.T) {
+	var s scheduler
+	try(t, s.acquire(context.Background()))
+
+	order := make(chan Priority, 3)
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		p := p
+		go func() {
+			if err := s.acquire(WithPriority(context.Background(), p)); err != nil {
+				t.Error(err)
+			}
+			order <- p
+			s.release()
+		}()
+		// make sure each one is queued before the next
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.release()
+
+	for _, want := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		got := <-order
+		assert(t, got == want, "Requests should be let through in priority order")
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	var s scheduler
+	try(t, s.acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert(t, s.acquire(ctx) == context.Canceled, "Cancelled acquire should return the context's error")
+
+	s.release()
+	assert(t, !s.busy, "Scheduler should be idle after the last release")
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+)
+
+// A DimensionError reports a downloaded image whose size doesn't match the
+// size the API gave for it.
+type DimensionError struct {
+	Post          *Post
+	Width, Height int // the size of the downloaded image
+}
+
+func (self *DimensionError) Error() string {
+	return fmt.Sprintf("api: post #%d: image is %dx%d, expected %dx%d",
+		self.Post.Id, self.Width, self.Height, self.Post.File.Width, self.Post.File.Height)
+}
+
+// CheckDimensions decodes the header of the image at path and compares its
+// size with the one the API gave for the post's file, returning a
+// *DimensionError if they differ. Files that aren't JPEG, PNG or GIF images,
+// like WebM videos, are not checked. It is meant to be used as a Downloader's
+// Process function.
+func CheckDimensions(post *Post, path string) error {
+	if post.File == nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err == image.ErrFormat {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("api: post #%d: %v", post.Id, err)
+	}
+	if config.Width != post.File.Width || config.Height != post.File.Height {
+		return &DimensionError{post, config.Width, config.Height}
+	}
+	return nil
+}
@@ -0,0 +1,463 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A Query is a compiled search over posts, written in a small query language:
+//
+//	name:Anonymous country:DE comment:/[Gg]entoo/
+//
+// A term is field:value, or a bare value which is searched for in the
+// comment. Values are matched case-insensitively as substrings of the field;
+// a value written as /pattern/ is a regular expression instead, and values
+// containing spaces can be quoted with double quotes. A word before a colon
+// that isn't a field name, as in https://example.com, is part of a bare
+// value. Terms next to each other
+// must all match; OR between terms means either may match, and binds less
+// tightly. A term can be negated with a leading - or NOT, and parentheses
+// group terms:
+//
+//	(trip:!Ep8pui8Vw2 OR capcode:mod) -comment:"bump"
+//
+// The string fields are name, trip, email, subject, comment (matched against
+// the comment's plain text), country (the code or name), capcode, special,
+// filename (the original file name), ext (without the dot) and board. no and
+// thread match post and thread numbers exactly, and has:file, has:trip,
+// has:subject, has:country and has:email match posts that have those.
+//
+// The comment metrics (see Post.Metrics) can be compared against numbers:
+// lines, greentext, quotelinks and words are counts, and caps is the
+// percentage of letters that are uppercase. A number on its own must match
+// exactly, and one prefixed with < or > is a bound:
+//
+//	words:>100 greentext:0 caps:<50
+//
+// A Query's Match method can be used wherever a func(*Post) bool is wanted.
+type Query struct {
+	src  string
+	root queryNode
+}
+
+// ParseQuery compiles a query.
+func ParseQuery(s string) (*Query, error) {
+	p := &queryParser{src: s}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return &Query{s, queryAll{}}, nil
+	}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("api: query: unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Query{s, root}, nil
+}
+
+// MustParseQuery is like ParseQuery but panics if the query doesn't compile.
+func MustParseQuery(s string) *Query {
+	q, err := ParseQuery(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Match reports whether the post matches the query. An empty query matches
+// every post.
+func (self *Query) Match(post *Post) bool {
+	return self.root.match(post)
+}
+
+// Filter returns the posts that match the query.
+func (self *Query) Filter(posts []*Post) []*Post {
+	var matched []*Post
+	for _, post := range posts {
+		if self.Match(post) {
+			matched = append(matched, post)
+		}
+	}
+	return matched
+}
+
+func (self *Query) String() string {
+	return self.src
+}
+
+type queryNode interface {
+	match(post *Post) bool
+}
+
+type queryAll struct{}
+
+func (queryAll) match(*Post) bool { return true }
+
+type queryAnd []queryNode
+
+func (self queryAnd) match(post *Post) bool {
+	for _, n := range self {
+		if !n.match(post) {
+			return false
+		}
+	}
+	return true
+}
+
+type queryOr []queryNode
+
+func (self queryOr) match(post *Post) bool {
+	for _, n := range self {
+		if n.match(post) {
+			return true
+		}
+	}
+	return false
+}
+
+type queryNot struct{ n queryNode }
+
+func (self queryNot) match(post *Post) bool { return !self.n.match(post) }
+
+// queryString matches a string field of a post, either as a substring or
+// with a regexp.
+type queryString struct {
+	field func(post *Post) []string
+	sub   string
+	re    *regexp.Regexp
+}
+
+func (self queryString) match(post *Post) bool {
+	for _, s := range self.field(post) {
+		if self.re != nil {
+			if self.re.MatchString(s) {
+				return true
+			}
+		} else if strings.Contains(strings.ToLower(s), self.sub) {
+			return true
+		}
+	}
+	return false
+}
+
+type queryFunc func(post *Post) bool
+
+func (self queryFunc) match(post *Post) bool { return self(post) }
+
+var queryFields = map[string]func(post *Post) []string{
+	"name":     func(p *Post) []string { return []string{p.Name} },
+	"trip":     func(p *Post) []string { return []string{p.Trip} },
+	"email":    func(p *Post) []string { return []string{p.Email} },
+	"subject":  func(p *Post) []string { return []string{p.Subject} },
+	"comment":  func(p *Post) []string { return []string{p.PlainText()} },
+	"country":  func(p *Post) []string { return []string{p.Country, p.CountryName} },
+	"capcode":  func(p *Post) []string { return []string{p.Capcode} },
+	"special":  func(p *Post) []string { return []string{p.Special} },
+	"filename": func(p *Post) []string { return fileField(p, func(f *File) string { return f.Name }) },
+	"ext": func(p *Post) []string {
+		return fileField(p, func(f *File) string { return strings.TrimPrefix(f.Ext, ".") })
+	},
+	"board": func(p *Post) []string {
+		if p.Thread == nil {
+			return nil
+		}
+		return []string{p.Thread.Board}
+	},
+}
+
+func fileField(p *Post, get func(f *File) string) []string {
+	if p.File == nil {
+		return nil
+	}
+	return []string{get(p.File)}
+}
+
+var queryHas = map[string]func(post *Post) bool{
+	"file":    func(p *Post) bool { return p.File != nil },
+	"trip":    func(p *Post) bool { return p.Trip != "" },
+	"subject": func(p *Post) bool { return p.Subject != "" },
+	"country": func(p *Post) bool { return p.Country != "" },
+	"email":   func(p *Post) bool { return p.Email != "" },
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenOpen
+	tokenClose
+	tokenNot
+	tokenOr
+)
+
+type queryToken struct {
+	kind  tokenKind
+	text  string // the token as written
+	field string // for terms, the field or "" for a bare value
+	value string
+	regex bool
+}
+
+type queryParser struct {
+	src    string
+	tokens []queryToken
+	pos    int
+}
+
+func (self *queryParser) tokenize() error {
+	s := self.src
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return nil
+		}
+		switch s[0] {
+		case '(':
+			self.tokens = append(self.tokens, queryToken{kind: tokenOpen, text: "("})
+			s = s[1:]
+			continue
+		case ')':
+			self.tokens = append(self.tokens, queryToken{kind: tokenClose, text: ")"})
+			s = s[1:]
+			continue
+		case '-':
+			self.tokens = append(self.tokens, queryToken{kind: tokenNot, text: "-"})
+			s = s[1:]
+			continue
+		}
+
+		tok := queryToken{kind: tokenTerm}
+		start := s
+		// a field name is a run of letters followed by a colon; anything
+		// else before a colon, like the scheme of a URL, is part of a bare
+		// value
+		i := 0
+		for i < len(s) && s[i] >= 'a' && s[i] <= 'z' {
+			i++
+		}
+		if i > 0 && i < len(s) && s[i] == ':' && isQueryField(s[:i]) {
+			tok.field = s[:i]
+			s = s[i+1:]
+		}
+
+		var err error
+		tok.value, tok.regex, s, err = queryValue(s)
+		if err != nil {
+			return err
+		}
+		tok.text = start[:len(start)-len(s)]
+		if tok.field == "" && !tok.regex {
+			switch tok.text {
+			case "OR":
+				tok.kind = tokenOr
+			case "AND":
+				// terms are ANDed anyway
+				continue
+			case "NOT":
+				tok.kind = tokenNot
+			}
+		}
+		self.tokens = append(self.tokens, tok)
+	}
+}
+
+// queryValue reads a single value from the start of s: a quoted string, a
+// /regexp/, or a run of anything up to a space or closing parenthesis.
+func queryValue(s string) (value string, regex bool, rest string, err error) {
+	if s == "" {
+		return "", false, s, fmt.Errorf("api: query: missing value")
+	}
+	switch s[0] {
+	case '"', '/':
+		delim := s[0]
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch {
+			case s[i] == '\\' && i+1 < len(s) && s[i+1] == delim:
+				b.WriteByte(delim)
+				i++
+			case s[i] == delim:
+				return b.String(), delim == '/', s[i+1:], nil
+			default:
+				b.WriteByte(s[i])
+			}
+		}
+		return "", false, s, fmt.Errorf("api: query: unterminated %c", delim)
+	}
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == ')' })
+	if i < 0 {
+		i = len(s)
+	}
+	if i == 0 {
+		return "", false, s, fmt.Errorf("api: query: missing value")
+	}
+	return s[:i], false, s[i:], nil
+}
+
+func (self *queryParser) peek() *queryToken {
+	if self.pos < len(self.tokens) {
+		return &self.tokens[self.pos]
+	}
+	return nil
+}
+
+func (self *queryParser) or() (queryNode, error) {
+	var nodes queryOr
+	for {
+		n, err := self.and()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		if tok := self.peek(); tok == nil || tok.kind != tokenOr {
+			break
+		}
+		self.pos++
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (self *queryParser) and() (queryNode, error) {
+	var nodes queryAnd
+	for {
+		tok := self.peek()
+		if tok == nil || tok.kind == tokenOr || tok.kind == tokenClose {
+			break
+		}
+		n, err := self.unary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	switch len(nodes) {
+	case 0:
+		if tok := self.peek(); tok != nil {
+			return nil, fmt.Errorf("api: query: unexpected %q", tok.text)
+		}
+		return nil, fmt.Errorf("api: query: missing term")
+	case 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (self *queryParser) unary() (queryNode, error) {
+	tok := self.peek()
+	self.pos++
+	switch tok.kind {
+	case tokenNot:
+		if next := self.peek(); next == nil || next.kind == tokenOr || next.kind == tokenClose {
+			return nil, fmt.Errorf("api: query: nothing to negate")
+		}
+		n, err := self.unary()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{n}, nil
+	case tokenOpen:
+		n, err := self.or()
+		if err != nil {
+			return nil, err
+		}
+		if next := self.peek(); next == nil || next.kind != tokenClose {
+			return nil, fmt.Errorf("api: query: missing )")
+		}
+		self.pos++
+		return n, nil
+	case tokenTerm:
+		return compileTerm(tok)
+	}
+	return nil, fmt.Errorf("api: query: unexpected %q", tok.text)
+}
+
+// isQueryField reports whether name is one of the fields a term can name.
+func isQueryField(name string) bool {
+	switch name {
+	case "no", "thread", "has":
+		return true
+	}
+	_, metric := queryMetrics[name]
+	_, field := queryFields[name]
+	return metric || field
+}
+
+func compileTerm(tok *queryToken) (queryNode, error) {
+	field := tok.field
+	if field == "" {
+		field = "comment"
+	}
+	switch field {
+	case "no", "thread":
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil || tok.regex {
+			return nil, fmt.Errorf("api: query: %s: %q is not a number", field, tok.value)
+		}
+		if field == "no" {
+			return queryFunc(func(p *Post) bool { return p.Id == n }), nil
+		}
+		return queryFunc(func(p *Post) bool { return p.Thread != nil && p.Thread.Id() == n }), nil
+	case "lines", "greentext", "quotelinks", "words", "caps":
+		return compileMetric(field, tok)
+	case "has":
+		has, ok := queryHas[tok.value]
+		if !ok || tok.regex {
+			return nil, fmt.Errorf("api: query: unknown has:%s", tok.value)
+		}
+		return queryFunc(has), nil
+	}
+
+	get, ok := queryFields[field]
+	if !ok {
+		return nil, fmt.Errorf("api: query: unknown field %q", field)
+	}
+	if tok.regex {
+		re, err := regexp.Compile(tok.value)
+		if err != nil {
+			return nil, fmt.Errorf("api: query: %v", err)
+		}
+		return queryString{field: get, re: re}, nil
+	}
+	return queryString{field: get, sub: strings.ToLower(tok.value)}, nil
+}
+
+var queryMetrics = map[string]func(m CommentMetrics) float64{
+	"lines":      func(m CommentMetrics) float64 { return float64(m.Lines) },
+	"greentext":  func(m CommentMetrics) float64 { return float64(m.GreentextLines) },
+	"quotelinks": func(m CommentMetrics) float64 { return float64(m.Quotelinks) },
+	"words":      func(m CommentMetrics) float64 { return float64(m.Words) },
+	"caps":       func(m CommentMetrics) float64 { return 100 * m.UppercaseRatio },
+}
+
+func compileMetric(field string, tok *queryToken) (queryNode, error) {
+	get := queryMetrics[field]
+	value := tok.value
+	op := byte('=')
+	if value != "" && (value[0] == '<' || value[0] == '>') {
+		op, value = value[0], value[1:]
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || tok.regex {
+		return nil, fmt.Errorf("api: query: %s: %q is not a number", field, tok.value)
+	}
+	return queryFunc(func(p *Post) bool {
+		v := get(p.Metrics())
+		switch op {
+		case '<':
+			return v < n
+		case '>':
+			return v > n
+		}
+		return v == n
+	}), nil
+}
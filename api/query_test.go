@@ -0,0 +1,80 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	thread := &Thread{Board: "g"}
+	op := &Post{Id: 1, Thread: thread, Name: "Anonymous", Country: "DE", CountryName: "Germany",
+		Comment: "Install <b>Gentoo</b>", File: &File{Name: "tux", Ext: ".png"}}
+	reply := &Post{Id: 2, Thread: thread, resto: 1, Name: "moot", Trip: "!Ep8pui8Vw2", Comment: "bump"}
+	thread.OP = op
+	thread.Posts = []*Post{op, reply}
+
+	cases := []struct {
+		query   string
+		matches []int64
+	}{
+		{"", []int64{1, 2}},
+		{"name:Anonymous country:DE comment:/[Gg]entoo/", []int64{1}},
+		{"country:germany", []int64{1}},
+		{"gentoo", []int64{1}},
+		{`comment:"install gentoo"`, []int64{1}},
+		{"name:anonymous OR trip:!Ep8", []int64{1, 2}},
+		{"-has:file", []int64{2}},
+		{"NOT ext:png", []int64{2}},
+		{"(name:moot OR ext:png) AND -bump", []int64{1}},
+		{"board:g thread:1 no:2", []int64{2}},
+		{"filename:/^tux$/", []int64{1}},
+		{"words:>1", []int64{1}},
+		{"words:1 caps:<1", []int64{2}},
+	}
+	for _, c := range cases {
+		q, err := ParseQuery(c.query)
+		if err != nil {
+			t.Errorf("%q: %v", c.query, err)
+			continue
+		}
+		matched := q.Filter(thread.Posts)
+		ok := len(matched) == len(c.matches)
+		for i := 0; ok && i < len(matched); i++ {
+			ok = matched[i].Id == c.matches[i]
+		}
+		if !ok {
+			t.Errorf("%q matched %v, expected %v", c.query, matched, c.matches)
+		}
+	}
+}
+
+func TestQueryBareColons(t *testing.T) {
+	post := &Post{Id: 1, Comment: "see https://example.com/a and note:this"}
+	for _, query := range []string{"https://example.com", "note:this", "name:Anonymous OR https://example.com/a", "nope:x OR note:"} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Errorf("%q: %v", query, err)
+			continue
+		}
+		if !q.Match(post) {
+			t.Errorf("%q should match the comment", query)
+		}
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		"comment:/[/",
+		`subject:"open`,
+		"(name:a",
+		"name:a)",
+		"no:x",
+		"has:nothing",
+		"words:>x",
+		"a OR",
+		"-",
+	} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("%q should not compile", query)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GetJSON fetches any path on the API server and decodes the JSON response
+// into dest, for endpoints this package doesn't have functions for yet. path
+// is relative to the server, like "/g/archive.json". The request goes through
+// the same machinery as every other request: the rate limit and priorities,
+// the circuit breaker, ResponseCache, the audit log, and the same errors, such
+// as ErrNotFound for a 404.
+func GetJSON(ctx context.Context, path string, dest interface{}) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("api: GetJSON: path %q should start with /", path)
+	}
+	return getDecode(ctx, APIURL, path, dest, nil)
+}
+
+// GetRaw fetches any path on the API server like GetJSON, but returns the
+// body as it is along with its Last-Modified time, for passing responses on
+// unchanged. If since isn't zero, the request is made conditional on the
+// response having changed since then, and ErrNotModified is returned if it
+// hasn't.
+func GetRaw(ctx context.Context, path string, since time.Time) (body []byte, modified time.Time, err error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, time.Time{}, fmt.Errorf("api: GetRaw: path %q should start with /", path)
+	}
+	resp, err := get(ctx, APIURL, path, func(req *http.Request) error {
+		if !since.IsZero() {
+			req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return nil, time.Time{}, requestError(path, err)
+	}
+	if modified, err = http.ParseTime(resp.Header.Get("Last-Modified")); err != nil {
+		modified = DefaultClock.Now()
+	}
+	return body, modified, nil
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A StateStore keeps small values between runs of a program, by key. Keys are
+// made of slash-separated parts, like "read/g/123".
+type StateStore interface {
+	// Get returns the value stored under key, with ok false if there is
+	// none.
+	Get(key string) (value []byte, ok bool, err error)
+	// Put stores a value under key, replacing any earlier one.
+	Put(key string, value []byte) error
+	// Delete removes the value under key, if there is one.
+	Delete(key string) error
+}
+
+// DirState is a StateStore that keeps each value in its own file under a
+// directory, at the path given by the key.
+type DirState string
+
+func (self DirState) path(key string) (string, error) {
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("api: invalid state key %q", key)
+		}
+	}
+	return filepath.Join(string(self), filepath.FromSlash(key)), nil
+}
+
+func (self DirState) Get(key string) ([]byte, bool, error) {
+	path, err := self.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+	value, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (self DirState) Put(key string, value []byte) error {
+	path, err := self.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", value, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (self DirState) Delete(key string) error {
+	path, err := self.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// A ReadState keeps track of the last post the user has seen in each thread,
+// so that an application can show how many posts are new since they last
+// looked. Positions are kept in a StateStore, under "read/<board>/<thread>",
+// so that they survive restarts.
+type ReadState struct {
+	store StateStore
+	mu    sync.Mutex
+	seen  map[ThreadKey]int64
+}
+
+// NewReadState returns a ReadState that keeps its positions in store. If store
+// is nil, they are only kept in memory.
+func NewReadState(store StateStore) *ReadState {
+	return &ReadState{store: store, seen: make(map[ThreadKey]int64)}
+}
+
+func readStateKey(key ThreadKey) string {
+	return fmt.Sprintf("read/%s/%d", key.Board, key.Id)
+}
+
+// lastSeen looks up the position for a thread. self.mu must be held.
+func (self *ReadState) lastSeen(key ThreadKey) (int64, error) {
+	if id, ok := self.seen[key]; ok || self.store == nil {
+		return id, nil
+	}
+	value, ok, err := self.store.Get(readStateKey(key))
+	if err != nil || !ok {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("api: read state for /%s/%d: %v", key.Board, key.Id, err)
+	}
+	self.seen[key] = id
+	return id, nil
+}
+
+// LastSeen returns the ID of the last post seen in a thread, or 0 if none has
+// been.
+func (self *ReadState) LastSeen(key ThreadKey) (int64, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.lastSeen(key)
+}
+
+// Unread returns the number of posts in the thread after the last one seen.
+// If nothing in the thread has been seen yet, every post is unread.
+func (self *ReadState) Unread(thread *Thread) (int, error) {
+	last, err := self.LastSeen(thread.Key())
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, post := range thread.Posts {
+		if post.Id > last {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// MarkSeen records that the posts of a thread up to and including id have been
+// seen. The position never moves backwards, so marking an older post does
+// nothing.
+func (self *ReadState) MarkSeen(key ThreadKey, id int64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	last, err := self.lastSeen(key)
+	if err != nil {
+		return err
+	}
+	if id <= last {
+		return nil
+	}
+	if self.store != nil {
+		if err := self.store.Put(readStateKey(key), []byte(strconv.FormatInt(id, 10))); err != nil {
+			return err
+		}
+	}
+	self.seen[key] = id
+	return nil
+}
+
+// MarkRead records that every post currently in the thread has been seen.
+func (self *ReadState) MarkRead(thread *Thread) error {
+	if len(thread.Posts) == 0 {
+		return nil
+	}
+	return self.MarkSeen(thread.Key(), thread.Posts[len(thread.Posts)-1].Id)
+}
+
+// Forget removes the position for a thread, e.g. once it has 404'd.
+func (self *ReadState) Forget(key ThreadKey) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.seen, key)
+	if self.store != nil {
+		return self.store.Delete(readStateKey(key))
+	}
+	return nil
+}
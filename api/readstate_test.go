@@ -0,0 +1,45 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readstate")
+	try(t, err)
+	defer os.RemoveAll(dir)
+
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0},{"no":2,"resto":1},{"no":3,"resto":1}]}`), "g")
+	try(t, err)
+	state := NewReadState(DirState(dir))
+	n, err := state.Unread(thread)
+	try(t, err)
+	assert(t, n == 3, "Every post should be unread at first")
+
+	try(t, state.MarkSeen(thread.Key(), 2))
+	try(t, state.MarkSeen(thread.Key(), 1))
+	n, err = state.Unread(thread)
+	try(t, err)
+	assert(t, n == 1, "Only the post after the last one seen should be unread")
+
+	// a new tracker on the same store picks up where the last one was
+	state = NewReadState(DirState(dir))
+	last, err := state.LastSeen(thread.Key())
+	try(t, err)
+	assert(t, last == 2, "The position should be kept in the store")
+	try(t, state.MarkRead(thread))
+	n, err = state.Unread(thread)
+	try(t, err)
+	assert(t, n == 0, "Nothing should be unread after MarkRead")
+
+	try(t, state.Forget(thread.Key()))
+	last, err = NewReadState(DirState(dir)).LastSeen(thread.Key())
+	try(t, err)
+	assert(t, last == 0, "Forgotten threads should start over")
+
+	_, _, err = DirState(dir).Get("read/../x")
+	assert(t, err != nil, "Keys shouldn't escape the directory")
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"sort"
+	"time"
+)
+
+// ReplayOptions controls the schedule a Replay plays its threads back on.
+type ReplayOptions struct {
+	// Speed is how many times faster than real time posts are replayed.
+	// Defaults to 1.
+	Speed float64
+	// If MaxGap is set, longer gaps between consecutive posts are shortened
+	// to MaxGap (before Speed is applied), so that quiet stretches of a
+	// recording don't hold up the replay.
+	MaxGap time.Duration
+}
+
+// A Replay is a Source that plays recorded threads back as though they were
+// live, so that code that follows threads can be tried out without the real
+// site. Each post appears at a time derived from its timestamp: the replay
+// starts with the earliest post in the recording when NewReplay is called,
+// and every later post appears after the same delay as it originally did,
+// adjusted by the ReplayOptions.
+//
+// Threads are only visible once their OP has been posted. The catalog and
+// index list them in bump order on a single page.
+type Replay struct {
+	start   time.Time
+	threads []*Thread
+	at      map[*Post]time.Duration
+}
+
+// NewReplay starts replaying the given threads. opts may be nil to replay in
+// real time. The threads must not be changed afterwards.
+func NewReplay(opts *ReplayOptions, threads ...*Thread) *Replay {
+	speed, maxGap := 1.0, time.Duration(0)
+	if opts != nil {
+		if opts.Speed > 0 {
+			speed = opts.Speed
+		}
+		maxGap = opts.MaxGap
+	}
+
+	var posts []*Post
+	for _, thread := range threads {
+		posts = append(posts, thread.Posts...)
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Time.Before(posts[j].Time)
+	})
+	at := make(map[*Post]time.Duration, len(posts))
+	var offset time.Duration
+	for i, post := range posts {
+		if i > 0 {
+			gap := post.Time.Sub(posts[i-1].Time)
+			if maxGap > 0 && gap > maxGap {
+				gap = maxGap
+			}
+			offset += gap
+		}
+		at[post] = time.Duration(float64(offset) / speed)
+	}
+	return &Replay{start: DefaultClock.Now(), threads: threads, at: at}
+}
+
+// Elapsed returns how far into the replay it is.
+func (self *Replay) Elapsed() time.Duration {
+	return DefaultClock.Now().Sub(self.start)
+}
+
+// Done returns true once every post has been replayed.
+func (self *Replay) Done() bool {
+	elapsed := self.Elapsed()
+	for _, at := range self.at {
+		if at > elapsed {
+			return false
+		}
+	}
+	return true
+}
+
+// visible returns a copy of the thread with only the posts that have been
+// replayed so far, or nil if the OP hasn't been yet.
+func (self *Replay) visible(thread *Thread, elapsed time.Duration) *Thread {
+	if thread.OP == nil || self.at[thread.OP] > elapsed {
+		return nil
+	}
+	t := &Thread{Board: thread.Board, date_recieved: DefaultClock.Now()}
+	for _, post := range thread.Posts {
+		if self.at[post] > elapsed {
+			continue
+		}
+		p := *post
+		p.Thread = t
+		if post.File != nil {
+			file := *post.File
+			file.Post = &p
+			p.File = &file
+		}
+		t.Posts = append(t.Posts, &p)
+		if post == thread.OP {
+			t.OP = &p
+		}
+	}
+	return t
+}
+
+func (self *Replay) board(board string) []*Thread {
+	elapsed := self.Elapsed()
+	var threads []*Thread
+	for _, thread := range self.threads {
+		if thread.Board != board {
+			continue
+		}
+		if t := self.visible(thread, elapsed); t != nil {
+			threads = append(threads, t)
+		}
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		a, b := threads[i].Posts[len(threads[i].Posts)-1], threads[j].Posts[len(threads[j].Posts)-1]
+		return a.Time.After(b.Time)
+	})
+	for i, t := range threads {
+		t.page, t.page_index, t.bump_position = 1, i, i+1
+	}
+	return threads
+}
+
+func (self *Replay) GetThread(board string, id int64) (*Thread, error) {
+	for _, thread := range self.threads {
+		if thread.Board == board && thread.Id() == id {
+			if t := self.visible(thread, self.Elapsed()); t != nil {
+				return t, nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (self *Replay) GetIndex(board string, page int) ([]*Thread, error) {
+	if page != 0 {
+		return nil, ErrNotFound
+	}
+	return self.board(board), nil
+}
+
+func (self *Replay) GetCatalog(board string) (Catalog, error) {
+	cat := make(Catalog, 1)
+	cat[0].Page = 1
+	cat[0].Threads = self.board(board)
+	return cat, nil
+}
+
+func (self *Replay) GetBoards() ([]Board, error) {
+	var boards []Board
+	seen := make(map[string]bool)
+	for _, thread := range self.threads {
+		if !seen[thread.Board] {
+			seen[thread.Board] = true
+			boards = append(boards, Board{Board: thread.Board, Title: thread.Board, Pages: 1})
+		}
+	}
+	return boards, nil
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	a, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"time":1000},{"no":2,"resto":1,"time":1010},{"no":4,"resto":1,"time":5000}]}`), "g")
+	try(t, err)
+	b, err := ParseThread(strings.NewReader(`{"posts":[{"no":3,"resto":0,"time":1020}]}`), "g")
+	try(t, err)
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	defer func(c Clock) { DefaultClock = c }(DefaultClock)
+	DefaultClock = clock
+
+	// 10 seconds between posts become 50ms, and the long gap is cut to 20s
+	replay := NewReplay(&ReplayOptions{Speed: 200, MaxGap: 20 * time.Second}, a, b)
+	thread, err := replay.GetThread("g", 1)
+	try(t, err)
+	assert(t, len(thread.Posts) == 1 && thread.OP.Id == 1, "Only the OP should be visible at first")
+	_, err = replay.GetThread("g", 3)
+	assert(t, err == ErrNotFound, "Threads should 404 until their OP is posted")
+
+	clock.Advance(125 * time.Millisecond)
+	thread, err = replay.GetThread("g", 1)
+	try(t, err)
+	assert(t, len(thread.Posts) == 2 && thread.Posts[1].Thread == thread, "The reply should have been posted")
+	cat, err := replay.GetCatalog("g")
+	try(t, err)
+	assert(t, len(cat[0].Threads) == 2 && cat[0].Threads[0].Id() == 3, "The newest thread should be first in bump order")
+	assert(t, !replay.Done(), "The last post shouldn't have been replayed yet")
+
+	clock.Advance(100 * time.Millisecond)
+	thread, err = replay.GetThread("g", 1)
+	try(t, err)
+	assert(t, len(thread.Posts) == 3 && replay.Done(), "Every post should have been replayed")
+	assert(t, len(a.Posts) == 3 && a.Posts[0].Thread == a, "The recording should be left alone")
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// quotePattern matches a run of >s followed by a number. Only those with
+// exactly two >s are quotes of a post; cross-board links look like >>>/g/123.
+var quotePattern = regexp.MustCompile(`(>+)(\d+)`)
+
+// Quotes returns the numbers of the posts the post quotes (links to with
+// >>123), in the order they first appear. The quoted posts might not exist or
+// might be in another thread.
+func (self *Post) Quotes() []int64 {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, m := range quotePattern.FindAllStringSubmatch(self.PlainText(), -1) {
+		if len(m[1]) != 2 {
+			continue
+		}
+		id, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// A ReplyGraph records which posts in a thread quote which. Quotes of posts
+// that aren't in the thread, and posts quoting themselves, are left out.
+type ReplyGraph struct {
+	// Quotes maps each post to the posts it quotes.
+	Quotes map[int64][]int64
+	// Replies maps each post to the posts that quote it, in thread order.
+	Replies map[int64][]int64
+}
+
+// ReplyGraph builds the graph of quotes between the posts of the thread.
+func (self *Thread) ReplyGraph() *ReplyGraph {
+	g := &ReplyGraph{
+		Quotes:  make(map[int64][]int64),
+		Replies: make(map[int64][]int64),
+	}
+	in := make(map[int64]bool, len(self.Posts))
+	for _, post := range self.Posts {
+		in[post.Id] = true
+	}
+	for _, post := range self.Posts {
+		for _, id := range post.Quotes() {
+			if !in[id] || id == post.Id {
+				continue
+			}
+			g.Quotes[post.Id] = append(g.Quotes[post.Id], id)
+			g.Replies[id] = append(g.Replies[id], post.Id)
+		}
+	}
+	return g
+}
+
+// TopRepliedPosts returns up to n of the thread's posts that were quoted by
+// other posts in the thread, ordered by the number of posts quoting them,
+// most first, and then by thread order.
+func (self *Thread) TopRepliedPosts(n int) []*Post {
+	g := self.ReplyGraph()
+	var posts []*Post
+	for _, post := range self.Posts {
+		if len(g.Replies[post.Id]) > 0 {
+			posts = append(posts, post)
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return len(g.Replies[posts[i].Id]) > len(g.Replies[posts[j].Id])
+	})
+	if n < 0 {
+		n = 0
+	}
+	if len(posts) > n {
+		posts = posts[:n]
+	}
+	return posts
+}
+
+// ReplyChain follows the quotes to and from a post through the thread.
+// ancestors are the posts it quotes, the posts those quote, and so on;
+// descendants are the posts that quote it, the posts quoting those, and so
+// on. Both are in thread order and never include the post itself, even if
+// the quotes go round in a cycle. Quotes of posts that aren't in the thread
+// are ignored. If the post isn't in the thread, both are nil.
+func (self *Thread) ReplyChain(id int64) (ancestors, descendants []*Post) {
+	byId := make(map[int64]*Post, len(self.Posts))
+	for _, post := range self.Posts {
+		byId[post.Id] = post
+	}
+	if byId[id] == nil {
+		return nil, nil
+	}
+	g := self.ReplyGraph()
+	return self.reach(id, g.Quotes), self.reach(id, g.Replies)
+}
+
+// reach returns the posts that can be reached from a post by following
+// edges, in thread order.
+func (self *Thread) reach(id int64, edges map[int64][]int64) []*Post {
+	seen := map[int64]bool{id: true}
+	queue := []int64{id}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, to := range edges[next] {
+			if !seen[to] {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	var posts []*Post
+	for _, post := range self.Posts {
+		if post.Id != id && seen[post.Id] {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// replyThread builds a thread from a list of comments, numbering posts from 1.
+func replyThread(t *testing.T, comments ...string) *Thread {
+	var b strings.Builder
+	b.WriteString(`{"posts":[`)
+	for i, com := range comments {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		resto := 1
+		if i == 0 {
+			resto = 0
+		}
+		fmt.Fprintf(&b, `{"no":%d,"resto":%d,"com":%q}`, i+1, resto, com)
+	}
+	b.WriteString(`]}`)
+	thread, err := ParseThread(strings.NewReader(b.String()), "g")
+	try(t, err)
+	return thread
+}
+
+func quote(id int) string {
+	return fmt.Sprintf(`<a href="#p%d" class="quotelink">&gt;&gt;%d</a>`, id, id)
+}
+
+func TestQuotes(t *testing.T) {
+	post := &Post{Comment: quote(1) + "<br>" + quote(2) + " " + quote(1) +
+		`<br><a href="/g/thread/5#p5" class="quotelink">&gt;&gt;&gt;/g/5</a>`}
+	quotes := post.Quotes()
+	assert(t, len(quotes) == 2 && quotes[0] == 1 && quotes[1] == 2, fmt.Sprint("Quotes should be deduplicated and skip cross-board links: ", quotes))
+}
+
+func TestReplyGraph(t *testing.T) {
+	thread := replyThread(t, "op", quote(1), quote(1)+quote(2)+quote(99), quote(4))
+	g := thread.ReplyGraph()
+	assert(t, len(g.Replies[1]) == 2, "The OP should have two replies")
+	assert(t, len(g.Quotes[3]) == 2, "Quotes of posts outside the thread should be dropped")
+	assert(t, len(g.Quotes[4]) == 0, "Self-quotes should be dropped")
+}
+
+func TestDigest(t *testing.T) {
+	thread := replyThread(t, "op", "a", quote(2), quote(2), quote(3), "b", "c")
+	d := thread.Digest(2, 2)
+	assert(t, d.OP.Id == 1, "The digest should have the OP")
+	assert(t, len(d.Latest) == 2 && d.Latest[0].Id == 6 && d.Latest[1].Id == 7, "The latest posts should be the newest, in order")
+	assert(t, len(d.Top) == 2 && d.Top[0].Id == 2 && d.Top[1].Id == 3, "The top posts should be ordered by replies")
+	assert(t, d.Omitted == 2, fmt.Sprint("Two posts should be left out, got ", d.Omitted))
+	assert(t, len(d.Posts()) == 5 && d.Posts()[1].Id == 2, "Posts should be in thread order")
+}
+
+func TestTopRepliedPosts(t *testing.T) {
+	thread := replyThread(t, "op", quote(1), quote(2), quote(2), quote(1)+quote(3))
+	top := thread.TopRepliedPosts(2)
+	assert(t, len(top) == 2 && top[0].Id == 1 && top[1].Id == 2, "Ties should be broken by thread order")
+	assert(t, len(thread.TopRepliedPosts(10)) == 3, "Only posts with replies should be returned")
+	assert(t, len(thread.TopRepliedPosts(-1)) == 0, "A negative count should return nothing")
+}
+
+func TestReplyChain(t *testing.T) {
+	// 2 and 3 quote each other; 4 quotes a post that doesn't exist
+	thread := replyThread(t, "op", quote(1)+quote(3), quote(2), quote(3)+quote(99), "unrelated")
+	ancestors, descendants := thread.ReplyChain(3)
+	assert(t, len(ancestors) == 2 && ancestors[0].Id == 1 && ancestors[1].Id == 2, fmt.Sprint("Ancestors should follow the cycle: ", ancestors))
+	assert(t, len(descendants) == 2 && descendants[0].Id == 2 && descendants[1].Id == 4, fmt.Sprint("Descendants should follow the cycle: ", descendants))
+
+	ancestors, descendants = thread.ReplyChain(5)
+	assert(t, ancestors == nil && descendants == nil, "A post without quotes should have no chain")
+	ancestors, _ = thread.ReplyChain(99)
+	assert(t, ancestors == nil, "A post that isn't in the thread should have no chain")
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// A Response describes the HTTP response a request got, for callers that need
+// more than the parsed result. It is returned by the WithResponse variants of
+// the Get functions.
+type Response struct {
+	URL        string
+	StatusCode int
+	// Header is the full set of response headers.
+	Header http.Header
+	// LastModified is the time the resource was last changed, or the zero
+	// time if the server didn't say.
+	LastModified time.Time
+	// ContentLength is the length of the body, or -1 if it isn't known.
+	ContentLength int64
+	// RetryAfter is how long the server asked for requests to be held off
+	// for, from the Retry-After header, or 0 if it didn't.
+	RetryAfter time.Duration
+	// Cached is true if the body was served from ResponseCache because the
+	// server said it hadn't changed.
+	Cached bool
+}
+
+type responseKey struct{}
+
+// withResponse returns a context that makes do record the response of the
+// request into the returned Response.
+func withResponse(ctx context.Context) (context.Context, *Response) {
+	r := new(Response)
+	return context.WithValue(ctx, responseKey{}, r), r
+}
+
+// recordResponse fills in the Response attached to ctx, if there is one.
+func recordResponse(ctx context.Context, req *http.Request, resp *http.Response) {
+	r, ok := ctx.Value(responseKey{}).(*Response)
+	if !ok {
+		return
+	}
+	r.URL = req.URL.String()
+	r.StatusCode = resp.StatusCode
+	r.Header = resp.Header
+	r.ContentLength = resp.ContentLength
+	r.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	r.RetryAfter = 0
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			r.RetryAfter = time.Duration(secs) * time.Second
+		} else if t, err := http.ParseTime(s); err == nil {
+			r.RetryAfter = t.Sub(DefaultClock.Now())
+		}
+	}
+}
+
+// markCached notes on the Response attached to ctx that the body came from
+// the response cache.
+func markCached(ctx context.Context) {
+	if r, ok := ctx.Value(responseKey{}).(*Response); ok {
+		r.Cached = true
+	}
+}
+
+// GetThreadWithResponse is like GetThread, but also returns the response the
+// request got. It always makes a request, even if CacheThreads is set. The
+// Response is returned even if there is an error, as long as a response was
+// received; otherwise its StatusCode is 0.
+func GetThreadWithResponse(board string, thread_id int64) (*Thread, *Response, error) {
+	ctx, r := withResponse(context.Background())
+	thread, err := getThread(ctx, board, thread_id, time.Unix(0, 0))
+	return thread, r, err
+}
+
+// GetIndexWithResponse is like GetIndex, but also returns the response the
+// request got.
+func GetIndexWithResponse(board string, page int) ([]*Thread, *Response, error) {
+	ctx, r := withResponse(context.Background())
+	threads, err := getIndex(ctx, board, page)
+	return threads, r, err
+}
+
+// GetCatalogWithResponse is like GetCatalog, but also returns the response
+// the request got.
+func GetCatalogWithResponse(board string) (Catalog, *Response, error) {
+	ctx, r := withResponse(context.Background())
+	cat, err := getCatalog(ctx, board)
+	return cat, r, err
+}
+
+// GetBoardsWithResponse is like GetBoards, but also returns the response the
+// request got.
+func GetBoardsWithResponse() ([]Board, *Response, error) {
+	ctx, r := withResponse(context.Background())
+	boards, err := getBoards(ctx)
+	return boards, r, err
+}
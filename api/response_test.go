@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (self roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return self(req)
+}
+
+func TestGetThreadWithResponse(t *testing.T) {
+	defer func(rt http.RoundTripper) { http.DefaultClient.Transport = rt }(http.DefaultClient.Transport)
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"posts":[{"no":1,"resto":0}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Last-Modified": {"Thu, 06 Sep 2012 22:38:41 GMT"},
+				"Retry-After":   {"5"},
+			},
+			ContentLength: int64(len(body)),
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			Request:       req,
+		}, nil
+	})
+
+	thread, resp, err := GetThreadWithResponse("g", 1)
+	try(t, err)
+	assert(t, thread.Id() == 1, "The thread should be parsed")
+	assert(t, resp.StatusCode == http.StatusOK, "The status should be recorded")
+	assert(t, resp.LastModified.Equal(time.Date(2012, 9, 6, 22, 38, 41, 0, time.UTC)), "Last-Modified should be parsed")
+	assert(t, resp.RetryAfter == 5*time.Second, "Retry-After should be parsed")
+	assert(t, resp.ContentLength == 30 && !resp.Cached, "The length should be recorded")
+	assert(t, strings.HasSuffix(resp.URL, "/g/thread/1.json"), "The URL should be recorded")
+}
+
+func TestGetJSON(t *testing.T) {
+	defer func(rt http.RoundTripper) { http.DefaultClient.Transport = rt }(http.DefaultClient.Transport)
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status, body := http.StatusOK, `[1,2,3]`
+		if req.URL.Path != "/g/archive.json" {
+			status, body = http.StatusNotFound, ""
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+
+	var ids []int64
+	try(t, GetJSON(context.Background(), "/g/archive.json", &ids))
+	assert(t, len(ids) == 3, "The response should be decoded")
+	assert(t, errors.Is(GetJSON(context.Background(), "/v/archive.json", &ids), ErrNotFound), "A 404 should be ErrNotFound")
+	assert(t, GetJSON(context.Background(), "g/archive.json", &ids) != nil, "Relative paths should be rejected")
+}
+
+func TestRequestError(t *testing.T) {
+	defer func(c *http.Client) { HTTPClient = c }(HTTPClient)
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status := http.StatusNotFound
+		if req.URL.Path == "/g/thread/2.json" {
+			status = http.StatusTeapot
+		}
+		return &http.Response{
+			StatusCode: status,
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	})}
+
+	_, err := GetThread("g", 1)
+	var reqErr *RequestError
+	assert(t, errors.As(err, &reqErr), "Errors should be RequestErrors")
+	assert(t, reqErr.Path == "/g/thread/1.json" && reqErr.Board == "g" && reqErr.Thread == 1, "The request should be recorded")
+	assert(t, errors.Is(err, ErrNotFound), "The cause should be ErrNotFound")
+	assert(t, err.Error() == "api: fetch /g/thread/1.json: not found", "The message should name the request")
+
+	_, err = GetThread("g", 2)
+	var statusErr *StatusError
+	assert(t, errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTeapot, "Unexpected statuses should be StatusErrors")
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	clock, restore := serveStatuses(nil, nil)
+	defer restore()
+	HTTPClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header: http.Header{
+				"Retry-After": {clock.Now().Add(30 * time.Second).Format(http.TimeFormat)},
+			},
+			Body:    ioutil.NopCloser(strings.NewReader("")),
+			Request: req,
+		}, nil
+	})
+
+	_, resp, err := GetThreadWithResponse("g", 1)
+	assert(t, err != nil, "A 503 should be an error")
+	assert(t, resp.RetryAfter == 30*time.Second, "Retry-After dates should be measured from DefaultClock")
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Cache stores raw API responses by URL, along with their Last-Modified
+// time.
+type Cache interface {
+	// Get returns the cached body for a URL and when it was last modified.
+	Get(url string) (body []byte, modified time.Time, ok bool)
+	// Put stores the body for a URL.
+	Put(url string, body []byte, modified time.Time) error
+}
+
+// If ResponseCache is set, every successful API response is stored in it,
+// and requests for URLs that are already cached are made conditional on the
+// cached copy being out of date. When it isn't, the cached copy is used.
+var ResponseCache Cache = nil
+
+// DirCache is a Cache that keeps responses as files in a directory. The
+// modification time of each file is the Last-Modified time of the response.
+// Because the files are plain JSON, cached responses can also be parsed
+// directly, e.g. with ParseThread, without going through the network.
+type DirCache string
+
+func (self DirCache) path(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(string(self), hex.EncodeToString(sum[:])+".json")
+}
+
+func (self DirCache) Get(url string) ([]byte, time.Time, bool) {
+	path := self.path(url)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return body, fi.ModTime(), true
+}
+
+func (self DirCache) Put(url string, body []byte, modified time.Time) error {
+	if err := os.MkdirAll(string(self), 0755); err != nil {
+		return err
+	}
+	path := self.path(url)
+	if err := ioutil.WriteFile(path+".tmp", body, 0644); err != nil {
+		return err
+	}
+	if err := os.Chtimes(path+".tmp", modified, modified); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// cacheResponse reads the whole response into the cache and hands back a
+// response with the same body.
+func cacheResponse(url string, resp *http.Response) (*http.Response, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		modified = DefaultClock.Now()
+	}
+	// a cache that can't be written to shouldn't fail the request
+	ResponseCache.Put(url, body, modified)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// cachedResponse makes a response for a request out of a cached body.
+func cachedResponse(req *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDirCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api")
+	try(t, err)
+	defer os.RemoveAll(dir)
+
+	cache := DirCache(dir)
+	_, _, ok := cache.Get("http://a.4cdn.org/a/thread/1.json")
+	assert(t, !ok, "Empty cache shouldn't have anything")
+
+	modified := time.Date(2018, 7, 5, 12, 0, 0, 0, time.UTC)
+	try(t, cache.Put("http://a.4cdn.org/a/thread/1.json", []byte(`{"posts":[]}`), modified))
+	body, mod, ok := cache.Get("http://a.4cdn.org/a/thread/1.json")
+	assert(t, ok, "Cached response should be found")
+	assert(t, string(body) == `{"posts":[]}`, "Cached body should be unchanged")
+	assert(t, mod.Equal(modified), "Cached modification time should be the Last-Modified time")
+}
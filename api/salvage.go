@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A PartialError is returned by SalvageThread when a thread could only be
+// partly decoded.
+type PartialError struct {
+	Parsed int   // the number of posts that were decoded successfully
+	Offset int64 // the byte offset in the input where decoding failed
+	Err    error // the decoding error
+}
+
+func (self *PartialError) Error() string {
+	return fmt.Sprintf("api: thread decoding failed at byte %d after %d posts: %v", self.Offset, self.Parsed, self.Err)
+}
+
+// SalvageThread is like ParseThread, but decodes the posts one at a time so
+// that a truncated or corrupt response doesn't lose the whole thread. If
+// decoding fails partway through, the posts before the failure are returned
+// along with a *PartialError. If not even one post could be decoded, the
+// returned thread is nil.
+func SalvageThread(r io.Reader, board string) (*Thread, error) {
+	dec := newDecoder(r)
+	posts := make([]*jsonPost, 0, 64)
+
+	fail := func(err error) (*Thread, error) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		perr := &PartialError{len(posts), dec.InputOffset(), err}
+		thread, _ := native_thread(posts, board)
+		return thread, perr
+	}
+
+	// find the start of the posts array
+	if err := expectDelim(dec, '{'); err != nil {
+		return fail(err)
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fail(err)
+		}
+		if key, ok := tok.(string); ok && key == "posts" {
+			break
+		}
+		// skip over the value of some other key
+		var skip interface{}
+		if err := dec.Decode(&skip); err != nil {
+			return fail(err)
+		}
+	}
+	if err := expectDelim(dec, '['); err != nil {
+		return fail(err)
+	}
+
+	for dec.More() {
+		var post *jsonPost
+		if err := dec.Decode(&post); err != nil {
+			return fail(err)
+		}
+		if post != nil {
+			posts = append(posts, post)
+		}
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return fail(err)
+	}
+	return native_thread(posts, board)
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSalvageThread(t *testing.T) {
+	data, err := ioutil.ReadFile("example.json")
+	try(t, err)
+
+	thread, err := SalvageThread(strings.NewReader(string(data)), "ck")
+	try(t, err)
+	assert(t, len(thread.Posts) == 38, "Complete thread should have 38 posts")
+
+	// cut the response off in the middle of the 11th post
+	truncated := string(data)
+	for i := 0; i < 11; i++ {
+		truncated = truncated[strings.Index(truncated, `{"no"`)+1:]
+	}
+	truncated = string(data)[:len(data)-len(truncated)+20]
+
+	thread, err = SalvageThread(strings.NewReader(truncated), "ck")
+	perr, ok := err.(*PartialError)
+	assert(t, ok, "Truncated thread should give a *PartialError")
+	assert(t, perr.Parsed == 10, "10 posts should have been salvaged")
+	assert(t, thread != nil && len(thread.Posts) == 10, "Salvaged thread should have 10 posts")
+	assert(t, thread.Id() == 3856791, "Salvaged thread id should be 3856791")
+
+	_, err = SalvageThread(strings.NewReader(`{"posts":[{"no":`), "ck")
+	assert(t, err != nil, "Thread with no complete posts should fail")
+}
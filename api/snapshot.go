@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// A BoardSnapshot is the state of a whole board at one point in time: its
+// catalog, and every thread listed in the catalog with all of its replies.
+type BoardSnapshot struct {
+	Board   string
+	Time    time.Time // when the catalog was fetched
+	Catalog Catalog
+	Threads map[int64]*Thread
+	// Missing lists the threads in the catalog that 404'd before they
+	// could be fetched, in order of ID. On a busy board, threads are pruned
+	// all the time.
+	Missing []int64
+}
+
+// the number of threads SnapshotBoard fetches at once
+const snapshotWorkers = 4
+
+// SnapshotBoard fetches the catalog of a board and then every live thread in
+// it in full, with GetThreadsFull. Threads that have 404'd by the time they
+// are fetched are listed in the snapshot's Missing; if any other request
+// fails, the outstanding requests are cancelled and the first error is
+// returned. Unless ctx says otherwise, requests are queued at PriorityLow.
+func SnapshotBoard(ctx context.Context, board string) (*BoardSnapshot, error) {
+	priority := PriorityLow
+	if _, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		priority = priorityOf(ctx)
+	}
+	ctx, cancel := context.WithCancel(WithPriority(ctx, priority))
+	defer cancel()
+	cat, err := getCatalog(ctx, board)
+	if err != nil {
+		return nil, err
+	}
+	snap := &BoardSnapshot{
+		Board:   board,
+		Time:    DefaultClock.Now(),
+		Catalog: cat,
+		Threads: make(map[int64]*Thread),
+	}
+
+	var ids []int64
+	for _, page := range cat {
+		for _, thread := range page.Threads {
+			ids = append(ids, thread.Id())
+		}
+	}
+	opts := &BulkOptions{Workers: snapshotWorkers, Priority: priority}
+	var first error
+	// the results are read to the end even after an error, so that the
+	// workers are all done by the time this returns
+	for result := range GetThreadsFull(ctx, board, ids, opts) {
+		switch {
+		case first != nil:
+		case result.Err == nil:
+			snap.Threads[result.Id] = result.Thread
+		case errors.Is(result.Err, ErrNotFound):
+			snap.Missing = append(snap.Missing, result.Id)
+		default:
+			first = result.Err
+			cancel()
+		}
+	}
+	if first != nil {
+		return nil, first
+	}
+	// the results stop early if ctx is done
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(snap.Missing, func(i, j int) bool { return snap.Missing[i] < snap.Missing[j] })
+	return snap, nil
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveStatuses makes requests for the paths in docs get those documents, and
+// requests for the paths in statuses fail with those statuses, until the
+// returned function is called. Everything else 404s. The rate limit is
+// driven by a FakeClock, which is advanced by waitFor.
+func serveStatuses(docs map[string]string, statuses map[string]int) (*FakeClock, func()) {
+	client, clock := HTTPClient, DefaultClock
+	HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status, body := http.StatusNotFound, ""
+		if doc, ok := docs[req.URL.Path]; ok {
+			status, body = http.StatusOK, doc
+		} else if s, ok := statuses[req.URL.Path]; ok {
+			status = s
+		}
+		return &http.Response{
+			StatusCode: status,
+			Status:     http.StatusText(status),
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})}
+	fake := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = fake
+	cooldown = nil
+	return fake, func() { HTTPClient, DefaultClock, cooldown = client, clock, nil }
+}
+
+// waitFor runs f, advancing the clock until it returns so that requests
+// don't wait on the rate limit in real time.
+func waitFor(clock *FakeClock, f func()) {
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond):
+			clock.Advance(time.Second)
+		}
+	}
+}
+
+const snapshotCatalog = `[{"page":1,"threads":[{"no":1,"resto":0},{"no":2,"resto":0},{"no":3,"resto":0}]}]`
+
+func TestSnapshotBoard(t *testing.T) {
+	clock, done := serveStatuses(map[string]string{
+		"/g/catalog.json":  snapshotCatalog,
+		"/g/thread/1.json": `{"posts":[{"no":1,"resto":0},{"no":4,"resto":1}]}`,
+		"/g/thread/3.json": `{"posts":[{"no":3,"resto":0}]}`,
+	}, nil)
+	defer done()
+
+	var snap *BoardSnapshot
+	var err error
+	waitFor(clock, func() { snap, err = SnapshotBoard(context.Background(), "g") })
+	try(t, err)
+	assert(t, len(snap.Threads) == 2 && len(snap.Threads[1].Posts) == 2 && snap.Threads[3] != nil, "The live threads should be in the snapshot")
+	assert(t, len(snap.Missing) == 1 && snap.Missing[0] == 2, "The pruned thread should be listed as missing")
+}
+
+func TestSnapshotBoardError(t *testing.T) {
+	clock, done := serveStatuses(map[string]string{
+		"/g/catalog.json":  snapshotCatalog,
+		"/g/thread/1.json": `{"posts":[{"no":1,"resto":0}]}`,
+		"/g/thread/3.json": `{"posts":[{"no":3,"resto":0}]}`,
+	}, map[string]int{"/g/thread/2.json": http.StatusInternalServerError})
+	defer done()
+
+	var err error
+	waitFor(clock, func() { _, err = SnapshotBoard(context.Background(), "g") })
+	var statusErr *StatusError
+	assert(t, errors.As(err, &statusErr), "Other errors should fail the snapshot")
+}
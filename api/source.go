@@ -0,0 +1,22 @@
+package api
+
+// A Source provides boards and threads. The package-level functions fetch
+// everything from the live API, which is available as the Source Live;
+// applications can write code against Source instead so that they can swap in
+// fakes in their tests, or a Source backed by an archive.
+type Source interface {
+	GetThread(board string, id int64) (*Thread, error)
+	GetIndex(board string, page int) ([]*Thread, error)
+	GetCatalog(board string) (Catalog, error)
+	GetBoards() ([]Board, error)
+}
+
+// Live is the Source that fetches data from the 4chan API.
+var Live Source = live{}
+
+type live struct{}
+
+func (live) GetThread(board string, id int64) (*Thread, error)  { return GetThread(board, id) }
+func (live) GetIndex(board string, page int) ([]*Thread, error) { return GetIndex(board, page) }
+func (live) GetCatalog(board string) (Catalog, error)           { return GetCatalog(board) }
+func (live) GetBoards() ([]Board, error)                        { return GetBoards() }
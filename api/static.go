@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// staticURL returns the URL of a file on the static asset server.
+func staticURL(path string) string {
+	return prefix() + StaticURL + path
+}
+
+// The paths of flag icons on the static server. In each, %[1]s is replaced
+// with the board's name and %[2]s with the flag's code in lower case. They
+// can be changed if 4chan moves its flags again.
+var (
+	// CountryFlagPath is the path of the flags of the country each post
+	// was made from, on boards that show them.
+	CountryFlagPath = "/image/country/%[2]s.gif"
+	// BoardFlagPath is the path of the flags that posters pick
+	// themselves, on boards with their own flags, such as /pol/'s.
+	BoardFlagPath = "/image/flags/%[1]s/%[2]s.gif"
+)
+
+func flagURL(path, board, code string) string {
+	return staticURL(fmt.Sprintf(path, board, strings.ToLower(code)))
+}
+
+// SpoilerImageURL returns the URL of the image shown in place of the
+// thumbnail of a spoilered file, on boards without custom spoilers.
+func SpoilerImageURL() string {
+	return staticURL("/image/spoiler.png")
+}
+
+// FileDeletedURL returns the URL of the placeholder shown in place of a
+// deleted file. OPs and replies have different placeholders.
+func FileDeletedURL(op bool) string {
+	if op {
+		return staticURL("/image/filedeleted.gif")
+	}
+	return staticURL("/image/filedeleted-res.gif")
+}
+
+// The icons shown next to the names of staff posting with a capcode.
+var capcodeIcons = map[string]string{
+	"admin":           "/image/adminicon.gif",
+	"admin_highlight": "/image/adminicon.gif",
+	"mod":             "/image/modicon.gif",
+	"developer":       "/image/developericon.gif",
+	"manager":         "/image/managericon.gif",
+}
+
+// CapcodeIconURL returns the URL of the icon for a capcode, as found in
+// Post.Capcode, or the empty string if it doesn't have one.
+func CapcodeIconURL(capcode string) string {
+	if path, ok := capcodeIcons[capcode]; ok {
+		return staticURL(path)
+	}
+	return ""
+}
+
+// StickyIconURL returns the URL of the icon marking a stickied thread.
+func StickyIconURL() string {
+	return staticURL("/image/sticky.gif")
+}
+
+// ClosedIconURL returns the URL of the icon marking a closed thread.
+func ClosedIconURL() string {
+	return staticURL("/image/closed.gif")
+}
+
+// ArchivedIconURL returns the URL of the icon marking an archived thread.
+func ArchivedIconURL() string {
+	return staticURL("/image/archived.gif")
+}
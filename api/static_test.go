@@ -0,0 +1,35 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStaticURLs(t *testing.T) {
+	defer func(ssl bool) { SSL = ssl }(SSL)
+	SSL = true
+	assert(t, SpoilerImageURL() == "https://s.4cdn.org/image/spoiler.png", "The default spoiler should be on the static server")
+	assert(t, FileDeletedURL(true) != FileDeletedURL(false), "OPs and replies should have different placeholders")
+	assert(t, CapcodeIconURL("admin_highlight") == CapcodeIconURL("admin"), "Highlighted admins should share the admin icon")
+	assert(t, CapcodeIconURL("mod") == "https://s.4cdn.org/image/modicon.gif", "Mods should have the mod icon")
+	assert(t, CapcodeIconURL("") == "", "Posts without a capcode should have no icon")
+	SSL = false
+	assert(t, StickyIconURL() == "http://s.4cdn.org/image/sticky.gif", "The scheme should follow SSL")
+}
+
+func TestFlagURLs(t *testing.T) {
+	defer func(ssl bool) { SSL = ssl }(SSL)
+	SSL = true
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0,"country":"US","country_name":"United States"},{"no":2,"resto":1,"board_flag":"CM","flag_name":"Commie"},{"no":3,"resto":1}]}`), "pol")
+	try(t, err)
+	us, commie, none := thread.Posts[0], thread.Posts[1], thread.Posts[2]
+	assert(t, us.CountryFlagURL() == "https://s.4cdn.org/image/country/us.gif", "Country flags should be in the country directory")
+	assert(t, commie.BoardFlagURL() == "https://s.4cdn.org/image/flags/pol/cm.gif", "Board flags should be in the board's flag directory")
+	assert(t, commie.FlagName == "Commie" && commie.FlagURL() == commie.BoardFlagURL(), "A board flag should be the post's flag")
+	assert(t, us.FlagURL() == us.CountryFlagURL(), "A country flag should be used when there's no board flag")
+	assert(t, none.FlagURL() == "", "Posts without a flag should have no flag URL")
+
+	defer func(path string) { CountryFlagPath = path }(CountryFlagPath)
+	CountryFlagPath = "/flags/%[2]s.png"
+	assert(t, us.CountryFlagURL() == "https://s.4cdn.org/flags/us.png", "The flag path should be configurable")
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"sort"
+)
+
+// FlagCounts returns the number of posts in the thread made from each
+// country, keyed by country code. Posts without a country flag are not
+// counted; board flags are counted separately by BoardFlagCounts, since their
+// codes can clash with country codes.
+func (self *Thread) FlagCounts() map[string]int {
+	return self.count(func(post *Post) string { return post.Country })
+}
+
+// BoardFlagCounts returns the number of posts in the thread made with each of
+// the board's own flags, keyed by flag code. Posts without a board flag are
+// not counted.
+func (self *Thread) BoardFlagCounts() map[string]int {
+	return self.count(func(post *Post) string { return post.BoardFlag })
+}
+
+// IDCounts returns the number of posts in the thread made by each poster ID,
+// on boards that show them. Posts without an ID are not counted.
+func (self *Thread) IDCounts() map[string]int {
+	return self.count(func(post *Post) string { return post.Special })
+}
+
+func (self *Thread) count(key func(post *Post) string) map[string]int {
+	counts := make(map[string]int)
+	for _, post := range self.Posts {
+		if k := key(post); k != "" {
+			counts[k]++
+		}
+	}
+	return counts
+}
+
+// A Share is one entry of a breakdown of posts: how many posts had some key
+// and what percentage of the counted posts that is.
+type Share struct {
+	Key     string
+	Count   int
+	Percent float64
+}
+
+// Breakdown turns counts such as those returned by FlagCounts into a list of
+// shares, most common first and then in order of key.
+func Breakdown(counts map[string]int) []Share {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	shares := make([]Share, 0, len(counts))
+	for k, n := range counts {
+		shares = append(shares, Share{k, n, 100 * float64(n) / float64(total)})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Count != shares[j].Count {
+			return shares[i].Count > shares[j].Count
+		}
+		return shares[i].Key < shares[j].Key
+	})
+	return shares
+}
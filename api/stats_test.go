@@ -0,0 +1,27 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestFlagCounts(t *testing.T) {
+	thread := &Thread{Posts: []*Post{
+		{Country: "DE", Special: "abc"},
+		{Country: "US", Special: "def"},
+		{Country: "DE", Special: "abc"},
+		{Special: "abc"},
+		{BoardFlag: "NZ", FlagName: "National Zombie"},
+		{BoardFlag: "NZ", FlagName: "National Zombie"},
+	}}
+	flags := thread.FlagCounts()
+	assert(t, len(flags) == 2 && flags["DE"] == 2 && flags["US"] == 1, "Flags should be counted")
+	boardFlags := thread.BoardFlagCounts()
+	assert(t, len(boardFlags) == 1 && boardFlags["NZ"] == 2, "Board flags should be counted apart from countries")
+	ids := thread.IDCounts()
+	assert(t, ids["abc"] == 3 && ids["def"] == 1, "IDs should be counted")
+
+	shares := Breakdown(flags)
+	assert(t, len(shares) == 2 && shares[0].Key == "DE", "The most common flag should come first")
+	assert(t, shares[1].Percent > 33.3 && shares[1].Percent < 33.4, "Percentages should be of the counted posts")
+	assert(t, len(Breakdown(nil)) == 0, "An empty breakdown should be empty")
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"sort"
+	"time"
+)
+
+// An EventType is the kind of change an Event records.
+type EventType string
+
+const (
+	PostAdded    EventType = "post-added"
+	PostDeleted  EventType = "post-deleted"
+	FileDeleted  EventType = "file-deleted"
+	StateChanged EventType = "state-changed"
+	CapcodeReply EventType = "capcode-reply"
+)
+
+// An Event is a single change to a thread, as found by comparing two
+// snapshots of it.
+type Event struct {
+	Time time.Time `json:"time"`
+	Type EventType `json:"type"`
+	// Id is the post that was added or deleted, or whose file was deleted.
+	Id   int64 `json:"post,omitempty"`
+	Post *Post `json:"-"`
+	// For StateChanged events, State is which of the thread's flags changed
+	// ("sticky", "closed", "archived", "bumplimit" or "imagelimit") and
+	// Value is its new value.
+	State string `json:"state,omitempty"`
+	Value bool   `json:"value"`
+	// For CapcodeReply events, which report a staff reply newly listed in
+	// the OP's CapcodeReplies, Id is the reply and Capcode is the capcode
+	// it was made under.
+	Capcode string `json:"capcode,omitempty"`
+}
+
+// A Snapshot is a copy of a thread as it was at some time, for example one
+// parsed from a cached response with ParseThread.
+type Snapshot struct {
+	Time   time.Time
+	Thread *Thread
+}
+
+// Timeline reconstructs the history of a thread from a series of snapshots of
+// it, returning the changes between them in chronological order. Posts in the
+// first snapshot, and posts that appear between snapshots, are given the time
+// they were made; deletions and state changes are given the time of the first
+// snapshot they were noticed in. The more often the snapshots were taken, the
+// more accurate those times are.
+func Timeline(snapshots []Snapshot) []Event {
+	snapshots = append([]Snapshot(nil), snapshots...)
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	var (
+		events []Event
+		prev   *Thread
+	)
+	for _, snap := range snapshots {
+		events = append(events, Changes(prev, snap.Thread, snap.Time)...)
+		prev = snap.Thread
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events
+}
+
+// Changes returns the events that took a thread from before to after, where
+// after was fetched at the given time. If before is nil, every post in after
+// is reported as added.
+func Changes(before, after *Thread, at time.Time) []Event {
+	var events []Event
+	old := make(map[int64]*Post)
+	if before != nil {
+		for _, post := range before.Posts {
+			old[post.Id] = post
+		}
+	}
+	for _, post := range after.Posts {
+		prev, ok := old[post.Id]
+		if !ok {
+			when := post.Time
+			if when.IsZero() || when.After(at) {
+				when = at
+			}
+			events = append(events, Event{Time: when, Type: PostAdded, Id: post.Id, Post: post})
+			continue
+		}
+		delete(old, post.Id)
+		if prev.File != nil && !prev.File.Deleted && post.File != nil && post.File.Deleted {
+			events = append(events, Event{Time: at, Type: FileDeleted, Id: post.Id, Post: post})
+		}
+	}
+	var deleted []Event
+	for id, post := range old {
+		deleted = append(deleted, Event{Time: at, Type: PostDeleted, Id: id, Post: post})
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		return deleted[i].Id < deleted[j].Id
+	})
+	events = append(events, deleted...)
+	events = append(events, capcodeChanges(before, after, at)...)
+
+	if before == nil || before.OP == nil || after.OP == nil {
+		return events
+	}
+	for _, state := range threadStates {
+		if v := state.get(after); v != state.get(before) {
+			events = append(events, Event{Time: at, Type: StateChanged, State: state.name, Value: v})
+		}
+	}
+	return events
+}
+
+// capcodeChanges returns a CapcodeReply event for each staff reply listed by
+// after's OP that before's OP didn't list.
+func capcodeChanges(before, after *Thread, at time.Time) []Event {
+	if after.OP == nil || after.OP.CapcodeReplies.Len() == 0 {
+		return nil
+	}
+	var old *CapcodeReplies
+	if before != nil && before.OP != nil {
+		old = before.OP.CapcodeReplies
+	}
+	posts := make(map[int64]*Post, len(after.Posts))
+	for _, post := range after.Posts {
+		posts[post.Id] = post
+	}
+	var events []Event
+	for _, capcode := range []string{"admin", "mod", "developer", "manager"} {
+		for _, id := range after.OP.CapcodeReplies.Get(capcode) {
+			if old.Capcode(id) != "" {
+				continue
+			}
+			when, post := at, posts[id]
+			if post != nil && !post.Time.IsZero() && post.Time.Before(at) {
+				when = post.Time
+			}
+			events = append(events, Event{Time: when, Type: CapcodeReply, Id: id, Post: post, Capcode: capcode})
+		}
+	}
+	return events
+}
+
+var threadStates = []struct {
+	name string
+	get  func(*Thread) bool
+}{
+	{"sticky", (*Thread).Sticky},
+	{"closed", (*Thread).Closed},
+	{"archived", (*Thread).Archived},
+	{"bumplimit", (*Thread).BumpLimit},
+	{"imagelimit", (*Thread).ImageLimit},
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	snap := func(at int64, data string) Snapshot {
+		thread, err := ParseThread(strings.NewReader(data), "g")
+		try(t, err)
+		return Snapshot{time.Unix(at, 0), thread}
+	}
+	snapshots := []Snapshot{
+		snap(300, `{"posts":[{"no":1,"resto":0,"time":100,"sticky":1},{"no":3,"resto":1,"time":250,"filename":"a","ext":".png"}]}`),
+		snap(200, `{"posts":[{"no":1,"resto":0,"time":100},{"no":2,"resto":1,"time":150}]}`),
+		snap(400, `{"posts":[{"no":1,"resto":0,"time":100,"sticky":1},{"no":3,"resto":1,"time":250,"filename":"a","ext":".png","filedeleted":1}]}`),
+	}
+
+	events := Timeline(snapshots)
+	want := []Event{
+		{Time: time.Unix(100, 0), Type: PostAdded, Id: 1},
+		{Time: time.Unix(150, 0), Type: PostAdded, Id: 2},
+		{Time: time.Unix(250, 0), Type: PostAdded, Id: 3},
+		{Time: time.Unix(300, 0), Type: PostDeleted, Id: 2},
+		{Time: time.Unix(300, 0), Type: StateChanged, State: "sticky", Value: true},
+		{Time: time.Unix(400, 0), Type: FileDeleted, Id: 3},
+	}
+	assert(t, len(events) == len(want), "Timeline should have 6 events")
+	for i, e := range events {
+		e.Post = nil
+		assert(t, e.Time.Equal(want[i].Time) && e.Type == want[i].Type && e.Id == want[i].Id &&
+			e.State == want[i].State && e.Value == want[i].Value, "Events should be in chronological order")
+	}
+}
+
+func TestCapcodeReplyEvents(t *testing.T) {
+	parse := func(data string) *Thread {
+		thread, err := ParseThread(strings.NewReader(data), "q")
+		try(t, err)
+		return thread
+	}
+	before := parse(`{"posts":[{"no":1,"resto":0,"capcode_replies":{"mod":[2]}},{"no":2,"resto":1,"time":150,"capcode":"mod"}]}`)
+	after := parse(`{"posts":[{"no":1,"resto":0,"capcode_replies":{"mod":[2],"admin":[3]}},{"no":2,"resto":1,"time":150,"capcode":"mod"},{"no":3,"resto":1,"time":250,"capcode":"admin"}]}`)
+
+	var capcode []Event
+	for _, e := range Changes(before, after, time.Unix(300, 0)) {
+		if e.Type == CapcodeReply {
+			capcode = append(capcode, e)
+		}
+	}
+	assert(t, len(capcode) == 1, "Only the new staff reply should be reported")
+	e := capcode[0]
+	assert(t, e.Id == 3 && e.Capcode == "admin" && e.Post != nil && e.Time.Equal(time.Unix(250, 0)), "The event should describe the admin's reply")
+
+	n := 0
+	for _, e := range Changes(nil, after, time.Unix(300, 0)) {
+		if e.Type == CapcodeReply {
+			n++
+		}
+	}
+	assert(t, n == 2, "Every staff reply should be reported for a new thread")
+}
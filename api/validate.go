@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+)
+
+// VerifyBoards makes every request check that its board is in the board list
+// first, fetching the list if it hasn't been yet, and fail with
+// ErrBoardNotFound if it isn't. This makes a typo in a board name fail
+// straight away rather than look like a thread that 404'd.
+var VerifyBoards = false
+
+// maxBoardLength is longer than any board name has been, to leave room for
+// new boards while still catching obvious garbage.
+const maxBoardLength = 10
+
+// validBoard checks that a board name could be real before it's put in a URL,
+// so that a typo doesn't cost a request.
+func validBoard(board string) error {
+	if board == "" {
+		return fmt.Errorf("api: no board name given")
+	}
+	if len(board) > maxBoardLength {
+		return fmt.Errorf("api: invalid board name %q: too long", board)
+	}
+	for _, c := range board {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return fmt.Errorf("api: invalid board name %q: board names are lowercase letters and digits, without slashes", board)
+		}
+	}
+	if VerifyBoards {
+		if _, err := LookupBoard(board); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validThread(board string, id int64) error {
+	if err := validBoard(board); err != nil {
+		return err
+	}
+	if id <= 0 {
+		return fmt.Errorf("api: invalid thread ID %d", id)
+	}
+	return nil
+}
+
+// validPage checks an index page number, counting from 0. If the board list
+// has been fetched already, the page is checked against the board's number of
+// pages too.
+func validPage(board string, page int) error {
+	if err := validBoard(board); err != nil {
+		return err
+	}
+	if page < 0 {
+		return fmt.Errorf("api: invalid page %d", page)
+	}
+	for _, b := range cachedBoards() {
+		if b.Board == board && b.Pages > 0 && page >= b.Pages {
+			return fmt.Errorf("api: invalid page %d: /%s/ has %d pages, counting from 0", page, board, b.Pages)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	defer func(boards []Board) { Boards = boards }(Boards)
+	Boards = []Board{{Board: "g", Pages: 10}}
+
+	for _, board := range []string{"g", "vg", "3", "trash"} {
+		try(t, validBoard(board))
+	}
+	for _, board := range []string{"", "/g/", "G", "g b", "boardnametoolong"} {
+		assert(t, validBoard(board) != nil, "Board "+board+" should be invalid")
+	}
+	assert(t, validThread("g", 0) != nil, "Thread IDs should be positive")
+	try(t, validThread("g", 1))
+	try(t, validPage("g", 9))
+	assert(t, validPage("g", 10) != nil, "Pages past the end of the board should be invalid")
+	assert(t, validPage("g", -1) != nil, "Negative pages should be invalid")
+	try(t, validPage("v", 20))
+
+	_, err := GetThread("/g/", 1)
+	assert(t, err != nil, "GetThread should fail without making a request")
+}
+
+func TestVerifyBoards(t *testing.T) {
+	defer func(boards []Board) { Boards = boards; VerifyBoards = false }(Boards)
+	Boards = []Board{{Board: "g"}}
+	VerifyBoards = true
+
+	try(t, validBoard("g"))
+	assert(t, validBoard("gg") == ErrBoardNotFound, "Boards not in the list should not be found")
+	_, err := GetCatalog("gg")
+	assert(t, err == ErrBoardNotFound, "Requests should fail before being made")
+}
+
+func TestLookupBoardError(t *testing.T) {
+	defer func(boards []Board) { Boards = boards }(Boards)
+	Boards = nil
+	clock, done := serveStatuses(nil, map[string]int{"/boards.json": http.StatusServiceUnavailable})
+	defer done()
+
+	var err error
+	waitFor(clock, func() { _, err = LookupBoard("g") })
+	var statusErr *StatusError
+	assert(t, errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusServiceUnavailable, "The cause of a failed lookup should be kept")
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"sort"
+)
+
+// Window returns up to limit posts of the thread that come after the post
+// numbered fromID, or all of them if limit is not positive, for showing a
+// thread a page at a time. Passing the ID of the last post of one window
+// gives the next. Posts are found by ID rather than position, so a window
+// still lines up if posts before it, or fromID itself, were deleted in an
+// Update. The returned slice is a copy, which later Updates don't change.
+func (self *Thread) Window(fromID int64, limit int) []*Post {
+	start := sort.Search(len(self.Posts), func(i int) bool {
+		return self.Posts[i].Id > fromID
+	})
+	end := len(self.Posts)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return append([]*Post(nil), self.Posts[start:end]...)
+}
+
+// LastN returns the last n posts of the thread, or all of them if there are
+// fewer. Like Window, it returns a copy.
+func (self *Thread) LastN(n int) []*Post {
+	if n <= 0 {
+		return nil
+	}
+	start := len(self.Posts) - n
+	if start < 0 {
+		start = 0
+	}
+	return append([]*Post(nil), self.Posts[start:]...)
+}
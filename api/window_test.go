@@ -0,0 +1,40 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWindow(t *testing.T) {
+	thread, err := ParseThread(strings.NewReader(`{"posts":[{"no":1,"resto":0},{"no":2,"resto":1},{"no":4,"resto":1},{"no":5,"resto":1},{"no":7,"resto":1}]}`), "g")
+	try(t, err)
+	ids := func(posts []*Post) (ids []int64) {
+		for _, post := range posts {
+			ids = append(ids, post.Id)
+		}
+		return
+	}
+	equal := func(a []int64, b ...int64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	assert(t, equal(ids(thread.Window(0, 2)), 1, 2), "The first window should start at the OP")
+	assert(t, equal(ids(thread.Window(2, 2)), 4, 5), "The next window should start after the given post")
+	assert(t, equal(ids(thread.Window(3, 0)), 4, 5, 7), "A window after a deleted post should start at the next one")
+	assert(t, len(thread.Window(7, 2)) == 0, "There should be nothing after the last post")
+	assert(t, equal(ids(thread.LastN(2)), 5, 7), "LastN should give the last posts")
+	assert(t, equal(ids(thread.LastN(10)), 1, 2, 4, 5, 7), "LastN should give every post if there are fewer")
+	assert(t, len(thread.LastN(0)) == 0, "LastN(0) should be empty")
+
+	window := thread.Window(0, 2)
+	thread.Posts[0] = thread.Posts[4]
+	assert(t, window[0].Id == 1, "Windows should not share the thread's slice")
+}
@@ -0,0 +1,582 @@
+// Package archiver continuously archives 4chan threads and their files.
+//
+// An Archiver polls the catalogs of its boards for threads to archive, keeps
+// every archived thread up to date until it 404s, downloads the files posted
+// in them, and records posts that get deleted along the way. Everything is
+// kept in a Store, so an Archiver that is restarted picks up the threads it
+// was following before.
+//
+//	a := &archiver.Archiver{
+//		Store:  archiver.DirStore("/srv/archive"),
+//		Boards: []string{"g"},
+//		Media:  true,
+//	}
+//	log.Fatal(a.Run(context.Background()))
+package archiver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A ThreadRef identifies a thread.
+type ThreadRef struct {
+	Board string
+	Id    int64
+}
+
+// An Archiver archives threads into a Store. Its fields should not be changed
+// after Run has been called.
+type Archiver struct {
+	// Store is where threads and files are saved.
+	Store Store
+	// Boards lists boards whose threads are archived.
+	Boards []string
+	// Threads lists individual threads to archive.
+	Threads []ThreadRef
+	// Sweeps lists boards that are captured in full on a schedule, such as
+	// hourly snapshots for research. Threads captured by a sweep are only
+	// updated again by later sweeps, unless they are also followed through
+	// Boards or Threads. Sweeps are started by Poll, so with Run they can
+	// start up to Interval late.
+	Sweeps []Sweep
+	// Filter, if set, decides which threads on Boards are archived, based on
+	// the thread's OP as it appears in the catalog.
+	Filter func(op *api.Post) bool
+	// If Media is true, files posted in archived threads are saved too, using
+	// Downloader (or a zero Downloader if it is nil).
+	Media      bool
+	Downloader *api.Downloader
+	// MediaFilter, if set, decides which files are saved when Media is
+	// true, e.g. only images, or only files under a certain size. Files it
+	// rejects, or that Downloader's Filter rejects, are marked as skipped in
+	// the record and never downloaded.
+	MediaFilter func(file *api.File) bool
+	// Interval is how often the catalogs and threads are checked for
+	// changes. It defaults to one minute.
+	Interval time.Duration
+	// Jitter, if set, delays the first Poll and lengthens each Interval by a
+	// random amount of up to Jitter, so that archivers started together
+	// don't poll in lockstep.
+	Jitter time.Duration
+	// Source is where threads are fetched from. If it is nil, they are
+	// fetched from the API using conditional requests.
+	Source api.Source
+	// Archive, if set, is asked for a thread once it has 404'd, and whatever
+	// it still has of the thread is merged into the record as a final
+	// snapshot. It is meant for sources backed by a third-party archive.
+	Archive api.Source
+	// OnComplete, if set, is called with the final record of each thread once
+	// it is complete: when it has been archived by the site, or 404'd.
+	OnComplete func(thread *Thread)
+	// Retention, if set, limits how much is kept in the Store. It is applied
+	// after every Poll.
+	Retention *Retention
+	// Logf is used to report errors that don't stop the archiver. It
+	// defaults to log.Printf.
+	Logf func(format string, args ...interface{})
+
+	// OnPruneRisk, if set, is called when a followed thread on one of Boards
+	// is found to be at risk of being pruned: when it has reached page
+	// PrunePage of the catalog, or when it is predicted to be pruned within
+	// PruneWithin based on how fast new threads are being made on the board.
+	// It is given the thread's page and the predicted time until it is
+	// pruned, which is negative if the board's speed isn't known yet. It is
+	// called again only if the thread has stopped being at risk in between.
+	// Threads at risk are updated first in each Poll.
+	OnPruneRisk func(ref ThreadRef, page int, eta time.Duration)
+	PrunePage   int
+	PruneWithin time.Duration
+
+	// OnSticky, if set, is called when a thread is newly stickied on one of
+	// Boards, which is usually an announcement. The thread is as it appears
+	// in the catalog. Stickies that are there when the archiver starts
+	// aren't reported.
+	OnSticky func(board string, thread *api.Thread)
+
+	// If Events is true and the Store is an EventStore, the changes found in
+	// each update of a thread (see api.Changes) are appended to the thread's
+	// event log. Posts that were already in the record when the archiver
+	// started aren't logged again.
+	Events bool
+	// OnEvents, if set, is also called with each batch of changes when
+	// Events is true, whatever the Store. It is given the labels of the
+	// thread; see WithLabel for routing only the threads with a given label.
+	OnEvents func(ref ThreadRef, labels []string, events []api.Event)
+	// Labels, if set, tags followed threads with labels, such as "generals"
+	// or "news", that are passed to OnEvents.
+	Labels func(ref ThreadRef) []string
+
+	threads   map[ThreadRef]*tracked
+	swept     map[ThreadRef]*Thread
+	nextSweep []time.Time
+	samples   map[string]*boardSample
+	atRisk    map[ThreadRef]bool
+	done      map[ThreadRef]bool
+	status    status
+}
+
+// A Sweep archives every thread on Board at each time in Schedule.
+type Sweep struct {
+	Board    string
+	Schedule *Schedule
+}
+
+// tracked is a thread that is being followed.
+type tracked struct {
+	record *Thread
+	live   *api.Thread
+	// errors counts the updates in a row that have failed
+	errors    int
+	lastError string
+}
+
+// Run archives threads until ctx is done. It only returns early if the
+// Store's existing threads can't be loaded.
+func (self *Archiver) Run(ctx context.Context) error {
+	if err := self.load(); err != nil {
+		return err
+	}
+	interval := self.interval()
+	wait := api.Jitter(self.Jitter)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-api.DefaultClock.After(wait):
+		}
+		self.Poll()
+		wait = interval + api.Jitter(self.Jitter)
+	}
+}
+
+func (self *Archiver) interval() time.Duration {
+	if self.Interval <= 0 {
+		return time.Minute
+	}
+	return self.Interval
+}
+
+// load resumes following the threads in the Store that aren't complete yet,
+// plus the threads that were asked for explicitly.
+func (self *Archiver) load() error {
+	self.threads = make(map[ThreadRef]*tracked)
+	self.swept = make(map[ThreadRef]*Thread)
+	self.done = make(map[ThreadRef]bool)
+	records, err := self.Store.Threads()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		ref := ThreadRef{record.Board, record.Id}
+		switch {
+		case record.Complete:
+			self.done[ref] = true
+		case record.Swept:
+			self.swept[ref] = record
+		default:
+			self.threads[ref] = &tracked{record: record}
+		}
+	}
+	for _, ref := range self.Threads {
+		self.track(ref)
+	}
+	return nil
+}
+
+// track starts following a thread, unless it is already being followed or
+// its archive is complete. Catalogs can be cached for a while, so threads
+// that have just 404'd can still turn up in them.
+func (self *Archiver) track(ref ThreadRef) {
+	if _, ok := self.threads[ref]; ok || self.done[ref] {
+		return
+	}
+	if record, ok := self.swept[ref]; ok {
+		delete(self.swept, ref)
+		record.Swept = false
+		self.threads[ref] = &tracked{record: record}
+		return
+	}
+	now := api.DefaultClock.Now()
+	self.threads[ref] = &tracked{record: &Thread{
+		Board:     ref.Board,
+		Id:        ref.Id,
+		FirstSeen: now,
+	}}
+}
+
+// Poll does a single round of archiving: it looks for new threads on the
+// boards and then updates every thread that is being followed. Run calls
+// Poll every Interval; it is exported for callers that want to schedule
+// archiving themselves.
+func (self *Archiver) Poll() {
+	if self.threads == nil {
+		if err := self.load(); err != nil {
+			self.logf("archiver: %v", err)
+			return
+		}
+	}
+	start := api.DefaultClock.Now()
+	self.startPoll(start)
+	self.sweep(start)
+	for _, board := range self.Boards {
+		self.discover(board)
+	}
+	// threads about to be pruned go first, in case updating the rest takes
+	// long enough for them to disappear
+	var risky, rest []ThreadRef
+	for ref := range self.threads {
+		if self.atRisk[ref] {
+			risky = append(risky, ref)
+		} else {
+			rest = append(rest, ref)
+		}
+	}
+	for _, ref := range append(risky, rest...) {
+		if self.update(self.threads[ref]) {
+			delete(self.threads, ref)
+			delete(self.atRisk, ref)
+			self.done[ref] = true
+		}
+	}
+	if self.Retention != nil {
+		if err := self.Retention.Apply(self.Store, api.DefaultClock.Now()); err != nil {
+			self.logf("archiver: retention: %v", err)
+		}
+		// pick up the files the policy pruned from threads still being
+		// followed, so they don't get saved as present again
+		if err := self.reload(); err != nil {
+			self.logf("archiver: %v", err)
+		}
+	}
+	self.publish(start)
+}
+
+// reload replaces the records of the followed and swept threads with the
+// Store's.
+func (self *Archiver) reload() error {
+	records, err := self.Store.Threads()
+	if err != nil {
+		return err
+	}
+	self.swept = make(map[ThreadRef]*Thread)
+	for _, record := range records {
+		ref := ThreadRef{record.Board, record.Id}
+		if t, ok := self.threads[ref]; ok {
+			t.record = record
+		} else if record.Swept && !record.Complete {
+			self.swept[ref] = record
+		}
+	}
+	return nil
+}
+
+// sweep runs the sweeps that are due. The first run of each is at the first
+// time in its schedule after the archiver started.
+func (self *Archiver) sweep(now time.Time) {
+	if self.nextSweep == nil {
+		self.nextSweep = make([]time.Time, len(self.Sweeps))
+		for i, s := range self.Sweeps {
+			self.nextSweep[i] = s.Schedule.Next(now)
+		}
+	}
+	for i, s := range self.Sweeps {
+		if next := self.nextSweep[i]; next.IsZero() || now.Before(next) {
+			continue
+		}
+		self.nextSweep[i] = s.Schedule.Next(now)
+		self.sweepBoard(s.Board, now)
+	}
+}
+
+// sweepBoard archives the current state of every thread in the board's
+// catalog that isn't already being followed.
+func (self *Archiver) sweepBoard(board string, now time.Time) {
+	cat, err := self.source().GetCatalog(board)
+	if err != nil {
+		self.logf("archiver: /%s/ sweep: %v", board, err)
+		return
+	}
+	for _, page := range cat {
+		for _, thread := range page.Threads {
+			ref := ThreadRef{board, thread.Id()}
+			if _, ok := self.threads[ref]; ok || self.done[ref] {
+				continue
+			}
+			record, ok := self.swept[ref]
+			if !ok {
+				record = &Thread{Board: board, Id: ref.Id, FirstSeen: now, Swept: true}
+				self.swept[ref] = record
+			}
+			if self.update(&tracked{record: record}) {
+				delete(self.swept, ref)
+				self.done[ref] = true
+			}
+		}
+	}
+}
+
+// discover starts following any threads in the board's catalog that pass the
+// filter.
+func (self *Archiver) discover(board string) {
+	cat, err := self.source().GetCatalog(board)
+	if err != nil {
+		self.logf("archiver: /%s/ catalog: %v", board, err)
+		return
+	}
+	for _, page := range cat {
+		for _, thread := range page.Threads {
+			if self.Filter != nil && !self.Filter(thread.OP) {
+				continue
+			}
+			self.track(ThreadRef{board, thread.Id()})
+		}
+	}
+	self.sample(board, cat, api.DefaultClock.Now())
+}
+
+// update fetches the latest version of a thread and saves the changes,
+// returning true once the thread is complete.
+func (self *Archiver) update(t *tracked) bool {
+	record := t.record
+	var prev *api.Thread
+	if t.live != nil {
+		// Update replaces the posts rather than changing them, so a shallow
+		// copy is enough to keep the previous version around
+		snapshot := *t.live
+		prev = &snapshot
+	}
+	var err error
+	if t.live != nil && self.Source == nil {
+		_, _, err = t.live.Update()
+	} else {
+		var live *api.Thread
+		live, err = self.source().GetThread(record.Board, record.Id)
+		if err == nil {
+			t.live = live
+		}
+	}
+
+	now := api.DefaultClock.Now()
+	latest := t.live
+	switch {
+	case err == nil:
+		if self.Events {
+			self.logEvents(record, prev, t.live, now)
+		}
+		merge(record, t.live, now)
+		// archived threads can't change any more, so this is the last look
+		record.Complete = t.live.Archived()
+	case errors.Is(err, api.ErrNotFound):
+		latest = self.final(record, now)
+		record.Complete = true
+		if self.superseded(record) {
+			return true
+		}
+	default:
+		self.logf("archiver: /%s/%d: %v", record.Board, record.Id, err)
+		t.errors, t.lastError = t.errors+1, err.Error()
+		return false
+	}
+	record.LastUpdate = now
+	if err := self.Store.SaveThread(record); err != nil {
+		self.logf("archiver: /%s/%d: %v", record.Board, record.Id, err)
+		t.errors, t.lastError = t.errors+1, err.Error()
+		return false
+	}
+	t.errors, t.lastError = 0, ""
+	if self.Media && latest != nil {
+		self.saveMedia(record, latest)
+	}
+	if record.Complete && self.OnComplete != nil {
+		self.OnComplete(record)
+	}
+	return record.Complete
+}
+
+// final takes a last snapshot of a thread that has 404'd from Archive, if
+// there is one, returning the archived copy of the thread.
+func (self *Archiver) final(record *Thread, now time.Time) *api.Thread {
+	if self.Archive == nil {
+		return nil
+	}
+	thread, err := self.Archive.GetThread(record.Board, record.Id)
+	if err != nil {
+		if !errors.Is(err, api.ErrNotFound) {
+			self.logf("archiver: /%s/%d: final snapshot: %v", record.Board, record.Id, err)
+		}
+		return nil
+	}
+	// the archive might have missed posts, so nothing is marked as deleted
+	// just because it's missing from the archived copy
+	addPosts(record, thread)
+	return thread
+}
+
+// superseded reports whether the Store already has a complete copy of a
+// thread that 404'd before this archiver ever got to see it, in which case
+// saving the new record would only lose posts. Complete threads are known
+// from load and from the threads completed since, so the Store itself isn't
+// read.
+func (self *Archiver) superseded(record *Thread) bool {
+	return record.LastUpdate.IsZero() && self.done[ThreadRef{record.Board, record.Id}]
+}
+
+// merge brings the record of a thread up to date with its live version: new
+// posts are added and posts that have disappeared are marked as deleted.
+func merge(record *Thread, live *api.Thread, now time.Time) {
+	seen := make(map[int64]bool, len(live.Posts))
+	for _, post := range live.Posts {
+		seen[post.Id] = true
+	}
+	for _, post := range record.Posts {
+		if !seen[post.Id] && post.Deleted == nil {
+			deleted := now
+			post.Deleted = &deleted
+		}
+	}
+	addPosts(record, live)
+}
+
+// addPosts adds the posts of the live thread that aren't in the record yet.
+func addPosts(record *Thread, live *api.Thread) {
+	known := make(map[int64]bool, len(record.Posts))
+	for _, post := range record.Posts {
+		known[post.Id] = true
+	}
+	added := false
+	for _, post := range live.Posts {
+		if !known[post.Id] {
+			record.Posts = append(record.Posts, newPost(post))
+			added = true
+		}
+	}
+	if added {
+		sort.SliceStable(record.Posts, func(i, j int) bool {
+			return record.Posts[i].Id < record.Posts[j].Id
+		})
+	}
+}
+
+// logEvents appends the changes from prev to live to the thread's event log,
+// if the Store keeps one. prev is nil if the thread hasn't been fetched since
+// the archiver started, in which case posts already in the record are left
+// out.
+func (self *Archiver) logEvents(record *Thread, prev, live *api.Thread, now time.Time) {
+	store, ok := self.Store.(EventStore)
+	if !ok && self.OnEvents == nil {
+		return
+	}
+	events := api.Changes(prev, live, now)
+	if prev == nil {
+		known := make(map[int64]bool, len(record.Posts))
+		for _, post := range record.Posts {
+			known[post.Id] = true
+		}
+		fresh := events[:0]
+		for _, event := range events {
+			if !known[event.Id] {
+				fresh = append(fresh, event)
+			}
+		}
+		events = fresh
+	}
+	if len(events) == 0 {
+		return
+	}
+	if ok {
+		if err := store.AppendEvents(record, events); err != nil {
+			self.logf("archiver: /%s/%d: events: %v", record.Board, record.Id, err)
+		}
+	}
+	if self.OnEvents != nil {
+		ref := ThreadRef{record.Board, record.Id}
+		var labels []string
+		if self.Labels != nil {
+			labels = self.Labels(ref)
+		}
+		self.OnEvents(ref, labels, events)
+	}
+}
+
+// WithLabel returns an OnEvents function that passes on the events of only
+// the threads labelled label. Several can be combined to send each label's
+// events somewhere different:
+//
+//	generals := archiver.WithLabel("generals", sendToGenerals)
+//	news := archiver.WithLabel("news", sendToNews)
+//	a.OnEvents = func(ref archiver.ThreadRef, labels []string, events []api.Event) {
+//		generals(ref, labels, events)
+//		news(ref, labels, events)
+//	}
+func WithLabel(label string, fn func(ref ThreadRef, labels []string, events []api.Event)) func(ThreadRef, []string, []api.Event) {
+	return func(ref ThreadRef, labels []string, events []api.Event) {
+		for _, l := range labels {
+			if l == label {
+				fn(ref, labels, events)
+				return
+			}
+		}
+	}
+}
+
+// saveMedia downloads the files of the thread that haven't been saved yet.
+func (self *Archiver) saveMedia(record *Thread, live *api.Thread) {
+	dl := self.Downloader
+	if dl == nil {
+		dl = new(api.Downloader)
+	}
+	posts := make(map[int64]*api.Post, len(live.Posts))
+	for _, post := range live.Posts {
+		posts[post.Id] = post
+	}
+
+	changed := false
+	for _, post := range record.Posts {
+		if post.File == nil || post.File.Saved || post.File.Pruned || post.File.Skipped || post.File.Deleted || posts[post.Id] == nil {
+			continue
+		}
+		if self.MediaFilter != nil && posts[post.Id].File != nil && !self.MediaFilter(posts[post.Id].File) {
+			post.File.Skipped = true
+			changed = true
+			continue
+		}
+		err := dl.DownloadFile(posts[post.Id], self.Store.MediaPath(record, post.File))
+		if errors.Is(err, api.ErrFiltered) {
+			post.File.Skipped = true
+			changed = true
+			continue
+		}
+		if err != nil {
+			self.logf("archiver: /%s/%d: file %d%s: %v", record.Board, record.Id, post.File.Id, post.File.Ext, err)
+			continue
+		}
+		post.File.Saved = true
+		changed = true
+	}
+	if changed {
+		if err := self.Store.SaveThread(record); err != nil {
+			self.logf("archiver: /%s/%d: %v", record.Board, record.Id, err)
+		}
+	}
+}
+
+func (self *Archiver) source() api.Source {
+	if self.Source == nil {
+		return api.Live
+	}
+	return self.Source
+}
+
+func (self *Archiver) logf(format string, args ...interface{}) {
+	if self.Logf != nil {
+		self.Logf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,502 @@
+package archiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// fakeSource serves threads from JSON documents that the test can change
+// between polls.
+type fakeSource struct {
+	threads map[int64]string
+}
+
+func (self *fakeSource) GetThread(board string, id int64) (*api.Thread, error) {
+	doc, ok := self.threads[id]
+	if !ok {
+		return nil, api.ErrNotFound
+	}
+	return api.ParseThread(strings.NewReader(doc), board)
+}
+
+func (self *fakeSource) GetCatalog(board string) (api.Catalog, error) {
+	var page []*api.Thread
+	for id := range self.threads {
+		thread, err := self.GetThread(board, id)
+		if err != nil {
+			return nil, err
+		}
+		thread.Posts = thread.Posts[:1]
+		page = append(page, thread)
+	}
+	return api.Catalog{{Page: 1, Threads: page}}, nil
+}
+
+func (self *fakeSource) GetIndex(board string, page int) ([]*api.Thread, error) {
+	return nil, api.ErrNotFound
+}
+
+func (self *fakeSource) GetBoards() ([]api.Board, error) {
+	return nil, nil
+}
+
+func TestArchiver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0,"sub":"keep"},{"no":2,"resto":1},{"no":3,"resto":1}]}`,
+		5: `{"posts":[{"no":5,"resto":0,"sub":"skip"}]}`,
+	}}
+	a := &Archiver{
+		Store:  DirStore(dir),
+		Boards: []string{"g"},
+		Filter: func(op *api.Post) bool { return op.Subject == "keep" },
+		Source: src,
+		Logf:   t.Logf,
+	}
+	a.Poll()
+
+	src.threads[1] = `{"posts":[{"no":1,"resto":0,"sub":"keep"},{"no":3,"resto":1},{"no":4,"resto":1}]}`
+	a.Poll()
+
+	threads, err := DirStore(dir).Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 1 || threads[0].Id != 1 {
+		t.Fatalf("Only thread 1 should be archived, got %d threads", len(threads))
+	}
+	posts := threads[0].Posts
+	if len(posts) != 4 {
+		t.Fatalf("Archived thread should have 4 posts, got %d", len(posts))
+	}
+	if posts[1].Id != 2 || posts[1].Deleted == nil {
+		t.Fatal("Post 2 should be marked deleted")
+	}
+	if posts[2].Deleted != nil || posts[3].Deleted != nil {
+		t.Fatal("Posts 3 and 4 should not be marked deleted")
+	}
+
+	// a new archiver on the same store resumes following thread 1 and
+	// notices that it's gone
+	delete(src.threads, 1)
+	a = &Archiver{Store: DirStore(dir), Source: src, Logf: t.Logf}
+	a.Poll()
+	threads, err = DirStore(dir).Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !threads[0].Complete {
+		t.Fatal("Thread 1 should be complete after it 404s")
+	}
+	if len(a.threads) != 0 {
+		t.Fatal("Complete threads should no longer be followed")
+	}
+}
+
+func TestFinalSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0},{"no":3,"resto":1}]}`,
+		5: `{"posts":[{"no":5,"resto":0,"archived":1,"archived_on":1346971121}]}`,
+	}}
+	archive := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1},{"no":4,"resto":1}]}`,
+	}}
+	var complete []int64
+	a := &Archiver{
+		Store:      DirStore(dir),
+		Threads:    []ThreadRef{{"g", 1}, {"g", 5}},
+		Source:     src,
+		Archive:    archive,
+		OnComplete: func(thread *Thread) { complete = append(complete, thread.Id) },
+		Logf:       t.Logf,
+	}
+	a.Poll()
+	if len(complete) != 1 || complete[0] != 5 {
+		t.Fatalf("Only the archived thread should be complete, got %v", complete)
+	}
+
+	delete(src.threads, 1)
+	a.Poll()
+	if len(complete) != 2 || complete[1] != 1 {
+		t.Fatalf("Thread 1 should be complete after it 404s, got %v", complete)
+	}
+	threads, err := DirStore(dir).Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, thread := range threads {
+		if thread.Id != 1 {
+			continue
+		}
+		var ids []int64
+		for _, post := range thread.Posts {
+			ids = append(ids, post.Id)
+			if post.Deleted != nil {
+				t.Errorf("Post %d should not be marked deleted", post.Id)
+			}
+		}
+		if len(ids) != 4 || ids[1] != 2 || ids[3] != 4 {
+			t.Errorf("The archived posts should be merged in order, got %v", ids)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1}]}`,
+	}}
+	a := &Archiver{
+		Store:   DirStore(dir),
+		Threads: []ThreadRef{{"g", 1}},
+		Source:  src,
+		Events:  true,
+		Logf:    t.Logf,
+	}
+	a.Poll()
+	src.threads[1] = `{"posts":[{"no":1,"resto":0,"closed":1},{"no":3,"resto":1}]}`
+	a.Poll()
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "g", "1", "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event api.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s %d %s", event.Type, event.Id, event.State))
+	}
+	want := []string{"post-added 1 ", "post-added 2 ", "post-added 3 ", "post-deleted 2 ", "state-changed 0 closed"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected events %q, got %q", want, got)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0}]}`,
+		2: `{"posts":[{"no":2,"resto":0}]}`,
+	}}
+	var news []ThreadRef
+	a := &Archiver{
+		Store:   DirStore(dir),
+		Threads: []ThreadRef{{"g", 1}, {"g", 2}},
+		Source:  src,
+		Events:  true,
+		Labels: func(ref ThreadRef) []string {
+			if ref.Id == 2 {
+				return []string{"generals", "news"}
+			}
+			return []string{"generals"}
+		},
+		OnEvents: WithLabel("news", func(ref ThreadRef, labels []string, events []api.Event) {
+			news = append(news, ref)
+		}),
+		Logf: t.Logf,
+	}
+	a.Poll()
+	if len(news) != 1 || news[0] != (ThreadRef{"g", 2}) {
+		t.Errorf("Expected only the events of /g/2 to be labelled news, got %v", news)
+	}
+}
+
+func TestSweeps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clock := api.NewFakeClock(time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC))
+	defer func(c api.Clock) { api.DefaultClock = c }(api.DefaultClock)
+	api.DefaultClock = clock
+
+	hourly, err := ParseSchedule("@hourly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1}]}`,
+	}}
+	a := &Archiver{
+		Store:  DirStore(dir),
+		Sweeps: []Sweep{{"g", hourly}},
+		Source: src,
+		Logf:   t.Logf,
+	}
+	a.Poll()
+	records, err := a.Store.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("Expected nothing to be swept before the first scheduled time, got %d threads", len(records))
+	}
+
+	clock.Advance(30 * time.Minute)
+	a.Poll()
+	src.threads[1] = `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1},{"no":3,"resto":1}]}`
+	a.Poll()
+	records, err = a.Store.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Posts) != 2 || !records[0].Swept {
+		t.Fatalf("Expected one swept thread captured at 11:00, got %+v", records)
+	}
+
+	// a restarted archiver doesn't start following swept threads
+	a = &Archiver{Store: DirStore(dir), Sweeps: []Sweep{{"g", hourly}}, Source: src, Logf: t.Logf}
+	a.Poll()
+	records, err = a.Store.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records[0].Posts) != 2 {
+		t.Fatalf("Expected the swept thread not to be updated between sweeps")
+	}
+	clock.Advance(time.Hour)
+	a.Poll()
+	records, err = a.Store.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(records[0].Posts) != 3 {
+		t.Fatalf("Expected the next sweep to catch up on the thread, got %+v", records)
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestMediaFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(c *http.Client) { api.HTTPClient = c }(api.HTTPClient)
+	var fetched []string
+	api.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetched = append(fetched, req.URL.Path)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("data")),
+			Request:    req,
+		}, nil
+	})}
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0,"tim":100,"ext":".jpg","filename":"a","fsize":4},{"no":2,"resto":1,"tim":200,"ext":".webm","filename":"b","fsize":4}]}`,
+	}}
+	a := &Archiver{
+		Store:       DirStore(dir),
+		Threads:     []ThreadRef{{"g", 1}},
+		Source:      src,
+		Media:       true,
+		MediaFilter: func(file *api.File) bool { return file.Ext == ".jpg" },
+		Logf:        t.Logf,
+	}
+	a.Poll()
+	a.Poll()
+	if len(fetched) != 1 || !strings.HasSuffix(fetched[0], "/100.jpg") {
+		t.Fatalf("Expected only the image to be downloaded, once, got %q", fetched)
+	}
+	records, err := a.Store.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []*File{records[0].Posts[0].File, records[0].Posts[1].File}
+	if !files[0].Saved || files[1].Saved || !files[1].Skipped {
+		t.Errorf("Expected the image to be saved and the video skipped, got %+v %+v", files[0], files[1])
+	}
+}
+
+func TestStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(c api.Clock) { api.DefaultClock = c }(api.DefaultClock)
+	clock := api.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	api.DefaultClock = clock
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1}]}`,
+		3: `not json`,
+	}}
+	a := &Archiver{
+		Store:   DirStore(dir),
+		Threads: []ThreadRef{{"g", 1}, {"g", 3}},
+		Source:  src,
+		Logf:    t.Logf,
+	}
+	if err := a.Healthy(); err != nil {
+		t.Errorf("An archiver that hasn't started should be healthy, got %v", err)
+	}
+	a.Poll()
+	a.Poll()
+
+	s := a.Status()
+	if !s.LastPoll.Equal(clock.Now()) || s.Following != 2 || len(s.Threads) != 2 {
+		t.Fatalf("Unexpected status %+v", s)
+	}
+	if th := s.Threads[0]; th.Id != 1 || th.Posts != 2 || th.Errors != 0 || !th.LastUpdate.Equal(clock.Now()) {
+		t.Errorf("Thread 1 should be up to date, got %+v", th)
+	}
+	if th := s.Threads[1]; th.Id != 3 || th.Errors != 2 || th.LastError == "" {
+		t.Errorf("Thread 3 should have failed twice, got %+v", th)
+	}
+
+	src.threads[3] = `{"posts":[{"no":3,"resto":0}]}`
+	a.Poll()
+	if th := a.Status().Threads[1]; th.Errors != 0 || th.LastError != "" {
+		t.Errorf("A successful update should reset the errors, got %+v", th)
+	}
+
+	if err := a.Healthy(); err != nil {
+		t.Errorf("An archiver that just polled should be healthy, got %v", err)
+	}
+	clock.Advance(time.Hour)
+	if err := a.Healthy(); err == nil {
+		t.Error("An archiver that hasn't polled in an hour should be unhealthy")
+	}
+}
+
+// staleCatalog keeps listing threads in the catalog after they 404, as a
+// cached catalog can.
+type staleCatalog struct {
+	*fakeSource
+	catalog api.Catalog
+}
+
+func (self *staleCatalog) GetCatalog(board string) (api.Catalog, error) {
+	return self.catalog, nil
+}
+
+func TestRestartKeepsCompleteThreads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1}]}`,
+	}}
+	catalog, err := src.GetCatalog("g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := &staleCatalog{src, catalog}
+	a := &Archiver{Store: DirStore(dir), Boards: []string{"g"}, Source: stale, Logf: t.Logf}
+	a.Poll()
+	delete(src.threads, 1)
+	a.Poll()
+	// the catalog still lists the thread, but it has been archived already
+	a.Poll()
+
+	check := func(when string) {
+		threads, err := DirStore(dir).Threads()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(threads) != 1 {
+			t.Fatalf("%s: expected 1 thread, got %d", when, len(threads))
+		}
+		if !threads[0].Complete || len(threads[0].Posts) != 2 {
+			t.Fatalf("%s: the complete thread should keep its 2 posts, got complete=%v posts=%d", when, threads[0].Complete, len(threads[0].Posts))
+		}
+	}
+	check("before restart")
+
+	// a restarted archiver that still asks for the thread mustn't replace
+	// the archive with an empty record
+	a = &Archiver{
+		Store:   DirStore(dir),
+		Boards:  []string{"g"},
+		Threads: []ThreadRef{{"g", 1}},
+		Source:  stale,
+		Logf:    t.Logf,
+	}
+	a.Poll()
+	check("after restart")
+	if len(a.threads) != 0 {
+		t.Error("Complete threads shouldn't be followed after a restart")
+	}
+
+	// nor should one that somehow starts following it anyway
+	a.threads[ThreadRef{"g", 1}] = &tracked{record: &Thread{Board: "g", Id: 1}}
+	a.Poll()
+	check("after a stray update")
+}
+
+func TestCapcodeReplyEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := &fakeSource{map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1}]}`,
+	}}
+	var got []api.Event
+	a := &Archiver{
+		Store:   DirStore(dir),
+		Threads: []ThreadRef{{"q", 1}},
+		Source:  src,
+		Events:  true,
+		OnEvents: func(ref ThreadRef, labels []string, events []api.Event) {
+			for _, e := range events {
+				if e.Type == api.CapcodeReply {
+					got = append(got, e)
+				}
+			}
+		},
+		Logf: t.Logf,
+	}
+	a.Poll()
+	src.threads[1] = `{"posts":[{"no":1,"resto":0,"capcode_replies":{"mod":[3]}},{"no":2,"resto":1},{"no":3,"resto":1,"capcode":"mod"}]}`
+	a.Poll()
+	a.Poll()
+	if len(got) != 1 || got[0].Id != 3 || got[0].Capcode != "mod" {
+		t.Fatalf("Expected one capcode-reply event for post 3, got %+v", got)
+	}
+}
@@ -0,0 +1,119 @@
+package archiver
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io"
+	"os"
+)
+
+// A ProblemKind is the kind of a Problem found by Audit.
+type ProblemKind string
+
+const (
+	// A file that the record says was saved isn't in the store.
+	ProblemMissing ProblemKind = "missing"
+	// A file's contents don't match the MD5 in the record.
+	ProblemCorrupt ProblemKind = "corrupt"
+	// A file couldn't be read.
+	ProblemUnreadable ProblemKind = "unreadable"
+	// A file was never saved, even though it wasn't pruned or deleted.
+	ProblemUnsaved ProblemKind = "unsaved"
+	// A file in the store isn't referred to by any record; see Orphans.
+	ProblemOrphan ProblemKind = "orphan"
+)
+
+// A Problem is something wrong with an archive found by Audit.
+type Problem struct {
+	Kind   ProblemKind `json:"kind"`
+	Board  string      `json:"board,omitempty"`
+	Thread int64       `json:"thread,omitempty"`
+	Post   int64       `json:"post,omitempty"`
+	Path   string      `json:"path"`
+	Detail string      `json:"detail,omitempty"`
+}
+
+// An AuditReport is the result of Audit. It is meant to be encoded as JSON
+// for other tools to read.
+type AuditReport struct {
+	Threads  int       `json:"threads"`
+	Files    int       `json:"files"`
+	Verified int       `json:"verified"`
+	Problems []Problem `json:"problems"`
+}
+
+// Audit checks every archived file in the store: files recorded as saved
+// must exist and match their MD5, and files that were neither pruned, skipped
+// nor deleted should have been saved. If the store is a MediaStore, orphaned
+// files are reported too. It reads files directly from the paths given by
+// MediaPath, so it works with stores that keep files on disk, as DirStore and
+// CASStore do. Files without a recorded MD5 are only checked for existence.
+func Audit(store Store) (*AuditReport, error) {
+	threads, err := store.Threads()
+	if err != nil {
+		return nil, err
+	}
+	report := &AuditReport{Threads: len(threads), Problems: []Problem{}}
+	for _, thread := range threads {
+		for _, post := range thread.Posts {
+			file := post.File
+			if file == nil || file.Pruned {
+				continue
+			}
+			report.Files++
+			problem := Problem{
+				Board:  thread.Board,
+				Thread: thread.Id,
+				Post:   post.Id,
+				Path:   store.MediaPath(thread, file),
+			}
+			if !file.Saved {
+				if !file.Deleted && !file.Skipped {
+					problem.Kind = ProblemUnsaved
+					report.Problems = append(report.Problems, problem)
+				}
+				continue
+			}
+			problem.Kind, problem.Detail = verify(problem.Path, file.MD5)
+			if problem.Kind != "" {
+				report.Problems = append(report.Problems, problem)
+			} else {
+				report.Verified++
+			}
+		}
+	}
+	if ms, ok := store.(MediaStore); ok {
+		orphans, err := Orphans(ms)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range orphans {
+			report.Problems = append(report.Problems, Problem{Kind: ProblemOrphan, Path: path})
+		}
+	}
+	return report, nil
+}
+
+// verify checks the file at path against sum, returning the kind of problem
+// found, if any.
+func verify(path string, sum []byte) (ProblemKind, string) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ProblemMissing, ""
+	}
+	if err != nil {
+		return ProblemUnreadable, err.Error()
+	}
+	defer f.Close()
+	if len(sum) == 0 {
+		return "", ""
+	}
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ProblemUnreadable, err.Error()
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		return ProblemCorrupt, "md5 mismatch"
+	}
+	return "", ""
+}
@@ -0,0 +1,57 @@
+package archiver
+
+import (
+	"crypto/md5"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirStore(dir)
+
+	good := md5.Sum([]byte("good"))
+	bad := md5.Sum([]byte("bad"))
+	thread := &Thread{Board: "g", Id: 1, Posts: []*Post{
+		{Id: 1, File: &File{Id: 10, Ext: ".jpg", MD5: good[:], Saved: true}},
+		{Id: 2, File: &File{Id: 20, Ext: ".jpg", MD5: bad[:], Saved: true}},
+		{Id: 3, File: &File{Id: 30, Ext: ".jpg", Saved: true}},
+		{Id: 4, File: &File{Id: 40, Ext: ".jpg"}},
+		{Id: 5, File: &File{Id: 50, Ext: ".jpg", Deleted: true}},
+		{Id: 6, File: &File{Id: 60, Ext: ".jpg", Pruned: true}},
+	}}
+	if err := store.SaveThread(thread); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"10.jpg": "good", "20.jpg": "changed", "99.jpg": "stray"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "g", "1", name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Audit(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Threads != 1 || report.Files != 5 || report.Verified != 1 {
+		t.Errorf("Unexpected counts in %+v", report)
+	}
+	want := []struct {
+		kind ProblemKind
+		post int64
+	}{{ProblemCorrupt, 2}, {ProblemMissing, 3}, {ProblemUnsaved, 4}, {ProblemOrphan, 0}}
+	if len(report.Problems) != len(want) {
+		t.Fatalf("Expected %d problems, got %+v", len(want), report.Problems)
+	}
+	for i, w := range want {
+		if p := report.Problems[i]; p.Kind != w.kind || p.Post != w.post {
+			t.Errorf("Expected problem %d to be %s on post %d, got %+v", i, w.kind, w.post, p)
+		}
+	}
+}
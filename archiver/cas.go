@@ -0,0 +1,217 @@
+package archiver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// A CASStore keeps records like a DirStore, but stores files by content,
+// under blobs/<ab>/<cd>/<md5><ext> where ab and cd are the first bytes of the
+// file's MD5 in hex. A file posted in several threads, or on several boards,
+// is only stored once. Alongside, manifest.json maps each blob to the posts
+// that refer to it, as "<board>/<thread>/<post>", which makes it easy to
+// check the blobs against the records without reading them all.
+//
+// A blob is only removed once no record refers to it any more. A CASStore
+// should be created with NewCASStore.
+type CASStore struct {
+	DirStore
+	mu sync.Mutex
+	// refs maps blob paths to the posts referring to them, or is nil
+	// until it has been built from the records
+	refs map[string]map[string]bool
+}
+
+// NewCASStore returns a CASStore rooted at the given directory.
+func NewCASStore(root string) *CASStore {
+	return &CASStore{DirStore: DirStore(root)}
+}
+
+// MediaPath returns the path of the file's blob. Files whose MD5 isn't known
+// are stored with their thread, as in a DirStore.
+func (self *CASStore) MediaPath(thread *Thread, file *File) string {
+	if len(file.MD5) < 2 {
+		return self.DirStore.MediaPath(thread, file)
+	}
+	sum := hex.EncodeToString(file.MD5)
+	return filepath.Join(string(self.DirStore), "blobs", sum[:2], sum[2:4], sum+file.Ext)
+}
+
+func postRef(thread *Thread, post *Post) string {
+	return fmt.Sprintf("%s/%d/%d", thread.Board, thread.Id, post.Id)
+}
+
+// load builds the manifest from the records if it hasn't been yet. self.mu
+// must be held.
+func (self *CASStore) load() error {
+	if self.refs != nil {
+		return nil
+	}
+	threads, err := self.DirStore.Threads()
+	if err != nil {
+		return err
+	}
+	self.refs = make(map[string]map[string]bool)
+	for _, thread := range threads {
+		self.addRefs(thread)
+	}
+	return nil
+}
+
+// addRefs adds the files of the thread that are in the store to the
+// manifest.
+func (self *CASStore) addRefs(thread *Thread) {
+	for _, post := range thread.Posts {
+		if post.File == nil || !post.File.Saved || post.File.Pruned {
+			continue
+		}
+		path := self.MediaPath(thread, post.File)
+		if self.refs[path] == nil {
+			self.refs[path] = make(map[string]bool)
+		}
+		self.refs[path][postRef(thread, post)] = true
+	}
+}
+
+// dropRefs removes the thread's posts from the manifest, returning the blobs
+// that nothing refers to any more.
+func (self *CASStore) dropRefs(thread *Thread) (unused []string) {
+	for _, post := range thread.Posts {
+		if post.File == nil {
+			continue
+		}
+		path := self.MediaPath(thread, post.File)
+		if refs, ok := self.refs[path]; ok {
+			delete(refs, postRef(thread, post))
+			if len(refs) == 0 {
+				delete(self.refs, path)
+				unused = append(unused, path)
+			}
+		}
+	}
+	return unused
+}
+
+// saveManifest writes the manifest out. self.mu must be held.
+func (self *CASStore) saveManifest() error {
+	root := string(self.DirStore)
+	manifest := make(map[string][]string, len(self.refs))
+	for path, refs := range self.refs {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		list := make([]string, 0, len(refs))
+		for ref := range refs {
+			list = append(list, ref)
+		}
+		sort.Strings(list)
+		manifest[filepath.ToSlash(rel)] = list
+	}
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(root, "manifest.json")
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Manifest returns the posts referring to each blob, keyed by the blob's path.
+func (self *CASStore) Manifest() (map[string][]string, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.load(); err != nil {
+		return nil, err
+	}
+	manifest := make(map[string][]string, len(self.refs))
+	for path, refs := range self.refs {
+		for ref := range refs {
+			manifest[path] = append(manifest[path], ref)
+		}
+		sort.Strings(manifest[path])
+	}
+	return manifest, nil
+}
+
+func (self *CASStore) SaveThread(thread *Thread) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.load(); err != nil {
+		return err
+	}
+	if err := self.DirStore.SaveThread(thread); err != nil {
+		return err
+	}
+	// files that were pruned have already been removed through DeleteMedia
+	self.dropRefs(thread)
+	self.addRefs(thread)
+	return self.saveManifest()
+}
+
+func (self *CASStore) DeleteThread(thread *Thread) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.load(); err != nil {
+		return err
+	}
+	if err := self.DirStore.DeleteThread(thread); err != nil {
+		return err
+	}
+	for _, path := range self.dropRefs(thread) {
+		if err := self.DirStore.RemoveMedia(path); err != nil {
+			return err
+		}
+	}
+	return self.saveManifest()
+}
+
+// DeleteMedia removes the post's reference to the file's blob, and the blob
+// itself if no other post refers to it.
+func (self *CASStore) DeleteMedia(thread *Thread, file *File) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.load(); err != nil {
+		return err
+	}
+	path := self.MediaPath(thread, file)
+	if refs, ok := self.refs[path]; ok {
+		for _, post := range thread.Posts {
+			if post.File == file {
+				delete(refs, postRef(thread, post))
+			}
+		}
+		if len(refs) > 0 {
+			return self.saveManifest()
+		}
+		delete(self.refs, path)
+	}
+	if err := self.DirStore.RemoveMedia(path); err != nil {
+		return err
+	}
+	return self.saveManifest()
+}
+
+// Media lists the blobs, plus any files stored with their threads.
+func (self *CASStore) Media() ([]string, error) {
+	media, err := self.DirStore.Media()
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := filepath.Glob(filepath.Join(string(self.DirStore), "blobs", "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	return append(media, blobs...), nil
+}
@@ -0,0 +1,72 @@
+package archiver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCASStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewCASStore(dir)
+
+	md5 := []byte{0xab, 0xcd, 0xef, 0x01}
+	a := &Thread{Board: "g", Id: 1, Posts: []*Post{{Id: 1, File: &File{Id: 10, Ext: ".jpg", MD5: md5}}}}
+	b := &Thread{Board: "v", Id: 2, Posts: []*Post{{Id: 2, File: &File{Id: 20, Ext: ".jpg", MD5: md5}}}}
+	blob := store.MediaPath(a, a.Posts[0].File)
+	if blob != filepath.Join(dir, "blobs", "ab", "cd", "abcdef01.jpg") {
+		t.Fatalf("Unexpected blob path %s", blob)
+	}
+	if store.MediaPath(b, b.Posts[0].File) != blob {
+		t.Fatal("The same file should be stored once across boards")
+	}
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(blob, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, thread := range []*Thread{a, b} {
+		thread.Posts[0].File.Saved = true
+		if err := store.SaveThread(thread); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest map[string][]string
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if refs := manifest["blobs/ab/cd/abcdef01.jpg"]; len(refs) != 2 || refs[0] != "g/1/1" || refs[1] != "v/2/2" {
+		t.Fatalf("Expected the manifest to list both posts, got %v", manifest)
+	}
+
+	if err := store.DeleteMedia(a, a.Posts[0].File); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blob); err != nil {
+		t.Fatal("A blob should be kept while another post refers to it")
+	}
+	a.Posts[0].File.Saved, a.Posts[0].File.Pruned = false, true
+	if err := store.SaveThread(a); err != nil {
+		t.Fatal(err)
+	}
+	// a fresh store rebuilds the manifest from the records
+	store = NewCASStore(dir)
+	if err := store.DeleteThread(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blob); !os.IsNotExist(err) {
+		t.Fatal("A blob should be removed once nothing refers to it")
+	}
+}
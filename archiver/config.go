@@ -0,0 +1,416 @@
+package archiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A Config describes an Archiver in a file, so that common setups don't need
+// any Go. It is read by LoadConfig from JSON, as here, or TOML:
+//
+//	{
+//		"store": {"dir": "/srv/archive", "layout": "cas"},
+//		"boards": ["g"],
+//		"threads": ["v/123456"],
+//		"filter": "subject:general",
+//		"media": true,
+//		"media_filter": {"exts": [".jpg", ".png"], "max_size": 4194304},
+//		"interval": "1m",
+//		"jitter": "10s",
+//		"sweeps": [{"board": "a", "schedule": "@hourly"}],
+//		"budget": {"per_hour": 3000},
+//		"retention": {"media_age": "720h", "min_replies": 5}
+//	}
+//
+// Durations are written as for time.ParseDuration, the filter is an
+// api.ParseQuery query matched against each thread's OP, and schedules are
+// as for ParseSchedule.
+type Config struct {
+	Store       StoreConfig      `json:"store"`
+	Boards      []string         `json:"boards"`
+	Threads     []string         `json:"threads"`
+	Filter      string           `json:"filter"`
+	Media       bool             `json:"media"`
+	MediaFilter *FilterConfig    `json:"media_filter"`
+	Interval    string           `json:"interval"`
+	Jitter      string           `json:"jitter"`
+	Events      bool             `json:"events"`
+	Sweeps      []SweepConfig    `json:"sweeps"`
+	Budget      *BudgetConfig    `json:"budget"`
+	Retention   *RetentionConfig `json:"retention"`
+}
+
+// A StoreConfig says where and how threads are stored. Layout is "dir" for a
+// DirStore, the default, or "cas" for a CASStore.
+type StoreConfig struct {
+	Dir    string `json:"dir"`
+	Layout string `json:"layout"`
+}
+
+// A SweepConfig describes a Sweep.
+type SweepConfig struct {
+	Board    string `json:"board"`
+	Schedule string `json:"schedule"`
+}
+
+// A FilterConfig describes an api.FileFilter.
+type FilterConfig struct {
+	Exts       []string `json:"exts"`
+	MinWidth   int      `json:"min_width"`
+	MinHeight  int      `json:"min_height"`
+	MaxWidth   int      `json:"max_width"`
+	MaxHeight  int      `json:"max_height"`
+	MaxSize    int      `json:"max_size"`
+	NoSpoilers bool     `json:"no_spoilers"`
+}
+
+// A BudgetConfig describes an api.Budget.
+type BudgetConfig struct {
+	PerHour int `json:"per_hour"`
+	PerDay  int `json:"per_day"`
+}
+
+// A RetentionConfig describes a Retention policy.
+type RetentionConfig struct {
+	MediaAge      string `json:"media_age"`
+	MinReplies    int    `json:"min_replies"`
+	MaxMediaBytes int64  `json:"max_media_bytes"`
+}
+
+// A ConfigError is a problem with a Config, pointing at the key responsible,
+// such as "sweeps[1].schedule".
+type ConfigError struct {
+	Path string // the file the config was read from, if any
+	Key  string
+	Err  error
+}
+
+func (self *ConfigError) Error() string {
+	msg := "archiver: "
+	if self.Path != "" {
+		msg += self.Path + ": "
+	}
+	if self.Key != "" {
+		msg += self.Key + ": "
+	}
+	return msg + self.Err.Error()
+}
+
+func (self *ConfigError) Unwrap() error {
+	return self.Err
+}
+
+// LoadConfig reads and checks a Config from a file. Files whose names end in
+// .toml are read as TOML, such as
+//
+//	boards = ["g"]
+//	interval = "1m"
+//
+//	[store]
+//	dir = "/srv/archive"
+//
+//	[[sweeps]]
+//	board = "a"
+//	schedule = "@hourly"
+//
+// and anything else as JSON. Only the parts of TOML that a Config needs are
+// supported: dates and multi-line strings, for instance, are not. Unknown
+// keys are errors, so that typos don't go unnoticed. Every error is a
+// *ConfigError.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config *Config
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		config, err = ParseConfigTOML(data)
+	} else {
+		config, err = ParseConfig(data)
+	}
+	if err != nil {
+		var ce *ConfigError
+		if errors.As(err, &ce) {
+			ce.Path = path
+		}
+		return nil, err
+	}
+	return config, nil
+}
+
+// ParseConfig is like LoadConfig, but reads the config from JSON data.
+func ParseConfig(data []byte) (*Config, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+			err = fmt.Errorf("line %d: %v", line, err)
+		}
+		return nil, &ConfigError{Err: err}
+	}
+	return decodeConfig(doc)
+}
+
+// ParseConfigTOML is like LoadConfig, but reads the config from TOML data.
+func ParseConfigTOML(data []byte) (*Config, error) {
+	doc, err := parseTOML(string(data))
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	return decodeConfig(doc)
+}
+
+// decodeConfig checks and decodes a config document in the form that
+// encoding/json decodes objects into an interface{}.
+func decodeConfig(doc interface{}) (*Config, error) {
+	if err := checkKeys(doc, reflect.TypeOf(Config{}), ""); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	config := new(Config)
+	if err := json.Unmarshal(data, config); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &ConfigError{Key: typeErr.Field, Err: fmt.Errorf("expected %s, got %s", typeName(typeErr.Type), typeErr.Value)}
+		}
+		return nil, &ConfigError{Err: err}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// checkKeys returns an error for the first key in doc, in sorted order, that
+// isn't the JSON name of a field of the struct type t, looking into nested
+// objects and lists. Keys are matched exactly, unlike encoding/json, which
+// ignores case. Values of the wrong type are left for decoding to report.
+func checkKeys(doc interface{}, t reflect.Type, key string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		fields := make(map[string]reflect.Type, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			fields[name] = t.Field(i).Type
+		}
+		names := make([]string, 0, len(doc))
+		for name := range doc {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := name
+			if key != "" {
+				path = key + "." + name
+			}
+			ft, ok := fields[name]
+			if !ok {
+				return &ConfigError{Key: path, Err: errors.New("unknown key")}
+			}
+			if err := checkKeys(doc[name], ft, path); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return nil
+		}
+		for i, v := range doc {
+			if err := checkKeys(v, t.Elem(), fmt.Sprintf("%s[%d]", key, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// typeName describes a type in terms of what it looks like in the config.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice:
+		return "a list"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	}
+	return "a number"
+}
+
+// Validate checks the config for mistakes, returning a *ConfigError for the
+// first one found.
+func (self *Config) Validate() error {
+	bad := func(key, format string, args ...interface{}) error {
+		return &ConfigError{Key: key, Err: fmt.Errorf(format, args...)}
+	}
+	if self.Store.Dir == "" {
+		return bad("store.dir", "missing")
+	}
+	switch self.Store.Layout {
+	case "", "dir", "cas":
+	default:
+		return bad("store.layout", "unknown layout %q, expected \"dir\" or \"cas\"", self.Store.Layout)
+	}
+	if len(self.Boards) == 0 && len(self.Threads) == 0 && len(self.Sweeps) == 0 {
+		return bad("", "nothing to archive: no boards, threads or sweeps")
+	}
+	for i, board := range self.Boards {
+		if board == "" || strings.Contains(board, "/") {
+			return bad(fmt.Sprintf("boards[%d]", i), "bad board name %q", board)
+		}
+	}
+	for i, thread := range self.Threads {
+		if _, err := parseThreadRef(thread); err != nil {
+			return bad(fmt.Sprintf("threads[%d]", i), "%v", err)
+		}
+	}
+	if _, err := api.ParseQuery(self.Filter); err != nil {
+		return bad("filter", "%v", err)
+	}
+	if _, err := parseDuration(self.Interval); err != nil {
+		return bad("interval", "%v", err)
+	}
+	if _, err := parseDuration(self.Jitter); err != nil {
+		return bad("jitter", "%v", err)
+	}
+	for i, sweep := range self.Sweeps {
+		if sweep.Board == "" {
+			return bad(fmt.Sprintf("sweeps[%d].board", i), "missing")
+		}
+		if _, err := ParseSchedule(sweep.Schedule); err != nil {
+			return bad(fmt.Sprintf("sweeps[%d].schedule", i), "%v", err)
+		}
+	}
+	if f := self.MediaFilter; f != nil && (f.MinWidth < 0 || f.MinHeight < 0 || f.MaxWidth < 0 || f.MaxHeight < 0 || f.MaxSize < 0) {
+		return bad("media_filter", "must not be negative")
+	}
+	if b := self.Budget; b != nil && (b.PerHour < 0 || b.PerDay < 0) {
+		return bad("budget", "must not be negative")
+	}
+	if r := self.Retention; r != nil {
+		if _, err := parseDuration(r.MediaAge); err != nil {
+			return bad("retention.media_age", "%v", err)
+		}
+		if r.MinReplies < 0 || r.MaxMediaBytes < 0 {
+			return bad("retention", "must not be negative")
+		}
+	}
+	return nil
+}
+
+// parseDuration parses a duration as for time.ParseDuration, except that ""
+// means 0.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad duration %q, expected something like \"1m\"", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration %q must not be negative", s)
+	}
+	return d, nil
+}
+
+// parseThreadRef parses a thread written as "board/id".
+func parseThreadRef(s string) (ThreadRef, error) {
+	i := strings.IndexByte(s, '/')
+	if i <= 0 {
+		return ThreadRef{}, fmt.Errorf("bad thread %q, expected board/id", s)
+	}
+	id, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil || id <= 0 {
+		return ThreadRef{}, fmt.Errorf("bad thread %q, expected board/id", s)
+	}
+	return ThreadRef{s[:i], id}, nil
+}
+
+// Archiver returns an Archiver set up as the config describes. The request
+// budget isn't part of it, since it applies to every request the process
+// makes; see RequestBudget.
+func (self *Config) Archiver() (*Archiver, error) {
+	if err := self.Validate(); err != nil {
+		return nil, err
+	}
+	a := &Archiver{
+		Boards: self.Boards,
+		Media:  self.Media,
+		Events: self.Events,
+	}
+	a.Interval, _ = parseDuration(self.Interval)
+	a.Jitter, _ = parseDuration(self.Jitter)
+	if self.Store.Layout == "cas" {
+		a.Store = NewCASStore(self.Store.Dir)
+	} else {
+		a.Store = DirStore(self.Store.Dir)
+	}
+	for _, thread := range self.Threads {
+		ref, _ := parseThreadRef(thread)
+		a.Threads = append(a.Threads, ref)
+	}
+	if self.Filter != "" {
+		query, _ := api.ParseQuery(self.Filter)
+		a.Filter = query.Match
+	}
+	if f := self.MediaFilter; f != nil {
+		filter := &api.FileFilter{
+			Exts:       f.Exts,
+			MinWidth:   f.MinWidth,
+			MinHeight:  f.MinHeight,
+			MaxWidth:   f.MaxWidth,
+			MaxHeight:  f.MaxHeight,
+			MaxSize:    f.MaxSize,
+			NoSpoilers: f.NoSpoilers,
+		}
+		a.MediaFilter = filter.Match
+	}
+	for _, sweep := range self.Sweeps {
+		schedule, _ := ParseSchedule(sweep.Schedule)
+		a.Sweeps = append(a.Sweeps, Sweep{sweep.Board, schedule})
+	}
+	if r := self.Retention; r != nil {
+		a.Retention = &Retention{
+			MinReplies:    r.MinReplies,
+			MaxMediaBytes: r.MaxMediaBytes,
+		}
+		a.Retention.MediaAge, _ = parseDuration(r.MediaAge)
+	}
+	return a, nil
+}
+
+// RequestBudget returns the request budget the config describes, or nil if it
+// has none. It is meant to be installed as api.RequestBudget before the
+// archiver is started:
+//
+//	api.RequestBudget = config.RequestBudget()
+func (self *Config) RequestBudget() *api.Budget {
+	if self.Budget == nil {
+		return nil
+	}
+	return &api.Budget{PerHour: self.Budget.PerHour, PerDay: self.Budget.PerDay}
+}
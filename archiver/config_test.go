@@ -0,0 +1,188 @@
+package archiver
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "archiver.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"store": {"dir": "`+filepath.ToSlash(dir)+`", "layout": "cas"},
+		"boards": ["g"],
+		"threads": ["v/123"],
+		"filter": "subject:general",
+		"media": true,
+		"media_filter": {"exts": [".png"], "max_size": 1000},
+		"interval": "1m30s",
+		"sweeps": [{"board": "a", "schedule": "@hourly"}],
+		"budget": {"per_hour": 100},
+		"retention": {"media_age": "24h", "min_replies": 3}
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := config.Archiver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Store.(*CASStore); !ok {
+		t.Errorf("expected a CASStore, got %T", a.Store)
+	}
+	if a.Interval != 90*time.Second {
+		t.Errorf("expected interval 1m30s, got %v", a.Interval)
+	}
+	if len(a.Threads) != 1 || a.Threads[0] != (ThreadRef{"v", 123}) {
+		t.Errorf("bad threads: %v", a.Threads)
+	}
+	if len(a.Sweeps) != 1 || a.Sweeps[0].Board != "a" {
+		t.Errorf("bad sweeps: %v", a.Sweeps)
+	}
+	if a.Retention == nil || a.Retention.MediaAge != 24*time.Hour || a.Retention.MinReplies != 3 {
+		t.Errorf("bad retention: %+v", a.Retention)
+	}
+	if b := config.RequestBudget(); b == nil || b.PerHour != 100 {
+		t.Errorf("bad budget: %+v", b)
+	}
+	if api.RequestBudget != nil {
+		t.Error("Building the archiver shouldn't install the budget")
+	}
+	if a.Filter(&api.Post{Subject: "daily general"}) != true || a.Filter(&api.Post{Subject: "other"}) {
+		t.Error("filter not applied")
+	}
+	if a.MediaFilter(&api.File{Ext: ".png", Size: 500}) != true || a.MediaFilter(&api.File{Ext: ".png", Size: 5000}) {
+		t.Error("media filter not applied")
+	}
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "archiver.toml")
+	err = ioutil.WriteFile(path, []byte(`# archive /g/ and sweep /a/
+boards = ["g"]
+threads = [
+	"v/123", # a multi-line array
+]
+media = true
+interval = '1m30s'
+
+[store]
+dir = "`+filepath.ToSlash(dir)+`"
+
+[media_filter]
+exts = [".png"]
+max_size = 1_000
+
+[[sweeps]]
+board = "a"
+schedule = "@hourly"
+
+[[sweeps]]
+board = "b"
+schedule = "0 */6 * * *"
+
+[retention]
+media_age = "24h"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := config.Archiver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Boards) != 1 || len(a.Threads) != 1 || !a.Media || a.Interval != 90*time.Second {
+		t.Errorf("Unexpected archiver %+v", a)
+	}
+	if len(a.Sweeps) != 2 || a.Sweeps[1].Board != "b" {
+		t.Errorf("bad sweeps: %v", a.Sweeps)
+	}
+	if config.MediaFilter == nil || config.MediaFilter.MaxSize != 1000 {
+		t.Errorf("bad media filter: %+v", config.MediaFilter)
+	}
+	if a.Retention == nil || a.Retention.MediaAge != 24*time.Hour {
+		t.Errorf("bad retention: %+v", a.Retention)
+	}
+}
+
+func TestConfigTOMLErrors(t *testing.T) {
+	for _, test := range []struct {
+		config string
+		key    string
+		msg    string
+	}{
+		{"boards = [\"g\"]\n[store]\ndir = \"x\"\nlayot = \"cas\"\n", "store.layot", "unknown key"},
+		{"boards = [\"g\"]\n[store]\ndir = \"x\n", "", "line 3"},
+		{"boards = [\"g\"]\nboards = [\"a\"]\n", "", "line 2"},
+		{"a = \"abc\\", "", "unterminated string"},
+		{"a = \"abc\\\nb = 1\n", "", "line 1"},
+		{"[store]\ndir = \"x\"\n[[sweeps]]\nboard = \"a\"\nschedule = \"bad\"\n", "sweeps[0].schedule", ""},
+		{"boards = 5\n[store]\ndir = \"x\"\n", "boards", "a list"},
+	} {
+		_, err := ParseConfigTOML([]byte(test.config))
+		var ce *ConfigError
+		if !errors.As(err, &ce) {
+			t.Errorf("%q: expected a ConfigError, got %v", test.config, err)
+			continue
+		}
+		if ce.Key != test.key || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%q: expected key %q and %q in the message, got %v", test.config, test.key, test.msg, err)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		config string
+		key    string
+	}{
+		{`{"boards": ["g"]}`, "store.dir"},
+		{`{"store": {"dir": "x", "layout": "sql"}, "boards": ["g"]}`, "store.layout"},
+		{`{"store": {"dir": "x"}, "board": ["g"]}`, "board"},
+		{`{"store": {"dir": "x"}, "sweeps": [{"board": "a", "schedule": "@hourly", "when": 1}]}`, "sweeps[0].when"},
+		{`{"store": {"dir": "x"}, "boards": "g"}`, "boards"},
+		{`{"store": {"dir": "x"}, "boards": ["g"], "interval": "soon"}`, "interval"},
+		{`{"store": {"dir": "x"}, "threads": ["g/abc"]}`, "threads[0]"},
+		{`{"store": {"dir": "x"}, "sweeps": [{"board": "a", "schedule": "@hourly"}, {"board": "b", "schedule": "61 * * * *"}]}`, "sweeps[1].schedule"},
+		{`{"store": {"dir": "x"}, "boards": ["g"], "budget": {"per_hour": -1}}`, "budget"},
+	} {
+		_, err := ParseConfig([]byte(test.config))
+		var ce *ConfigError
+		if !errors.As(err, &ce) {
+			t.Errorf("%s: expected a ConfigError, got %v", test.config, err)
+			continue
+		}
+		if ce.Key != test.key {
+			t.Errorf("%s: expected key %q, got %q (%v)", test.config, test.key, ce.Key, err)
+		}
+		if !strings.HasPrefix(err.Error(), "archiver: ") {
+			t.Errorf("bad message: %v", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package archiver
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A MediaStore is a Store that can list the files it holds, so that files no
+// record refers to any more can be found and removed.
+type MediaStore interface {
+	Store
+	// Media returns the paths of every file in the store, in the form
+	// returned by MediaPath.
+	Media() ([]string, error)
+	// RemoveMedia removes the file at a path returned by Media.
+	RemoveMedia(path string) error
+}
+
+// Orphans returns the paths of the files in the store that no archived post
+// refers to, such as files left behind by pruning that was interrupted, or
+// by threads whose records were removed by hand. Files of posts that haven't
+// been saved yet, including partial downloads, aren't orphans, since the
+// archiver may still be working on them.
+func Orphans(store MediaStore) ([]string, error) {
+	threads, err := store.Threads()
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	for _, thread := range threads {
+		for _, post := range thread.Posts {
+			if post.File == nil || post.File.Pruned {
+				continue
+			}
+			path := store.MediaPath(thread, post.File)
+			referenced[path] = true
+			referenced[api.ETagPath(path)] = true
+			if !post.File.Saved {
+				referenced[path+".part"] = true
+			}
+		}
+	}
+	paths, err := store.Media()
+	if err != nil {
+		return nil, err
+	}
+	var orphans []string
+	for _, path := range paths {
+		if !referenced[path] {
+			orphans = append(orphans, path)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// CollectGarbage removes the orphaned files from the store, returning their
+// paths. It should not be run while an Archiver is saving files to the same
+// store.
+func CollectGarbage(store MediaStore) ([]string, error) {
+	orphans, err := Orphans(store)
+	if err != nil {
+		return nil, err
+	}
+	for i, path := range orphans {
+		if err := store.RemoveMedia(path); err != nil {
+			return orphans[:i], err
+		}
+	}
+	return orphans, nil
+}
+
+// Media lists every file in the thread directories other than the records
+// and event logs themselves.
+func (self DirStore) Media() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(string(self), "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	media := paths[:0]
+	for _, path := range paths {
+		switch filepath.Base(path) {
+		case "thread.json", "thread.json.tmp", "events.jsonl":
+			continue
+		}
+		if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		media = append(media, path)
+	}
+	return media, nil
+}
+
+// RemoveMedia removes a file along with the ETag the api package's Downloader
+// keeps next to it.
+func (self DirStore) RemoveMedia(path string) error {
+	os.Remove(api.ETagPath(path))
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
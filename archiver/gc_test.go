@@ -0,0 +1,71 @@
+package archiver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirStore(dir)
+
+	thread := &Thread{Board: "g", Id: 1, Posts: []*Post{
+		{Id: 1, File: &File{Id: 10, Ext: ".jpg", Saved: true}},
+		{Id: 2, File: &File{Id: 20, Ext: ".png", Pruned: true}},
+		{Id: 3, File: &File{Id: 30, Ext: ".gif"}},
+	}}
+	if err := store.SaveThread(thread); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, post := range thread.Posts {
+		write(store.MediaPath(thread, post.File))
+	}
+	write(store.MediaPath(thread, thread.Posts[2].File) + ".part")
+	// the ETag the downloader keeps for a file goes with it
+	kept := api.ETagPath(store.MediaPath(thread, thread.Posts[0].File))
+	write(kept)
+	// the record of this thread is gone, but its file was left behind
+	gone := filepath.Join(dir, "g", "2", "50.webm")
+	write(gone)
+
+	removed, err := CollectGarbage(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{store.MediaPath(thread, thread.Posts[1].File), gone}
+	if strings.Join(removed, "|") != strings.Join(want, "|") {
+		t.Fatalf("Expected %q to be removed, got %q", want, removed)
+	}
+	for _, path := range want {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("Expected the ETag of a saved file to be kept: %v", err)
+	}
+	orphans, err := Orphans(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("Expected no orphans left, got %q", orphans)
+	}
+}
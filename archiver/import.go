@@ -0,0 +1,192 @@
+package archiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// ImportDir imports a thread saved by another tool into the store. dir must
+// hold the thread as JSON, in thread.json or <thread>.json, either as the
+// API returns it or in the layout written by 4get and DirStore, with the
+// files posted in it alongside, named <tim><ext> as on 4chan's servers.
+// board is used if the JSON doesn't say which board the thread is from.
+//
+// Files are hard linked into the store where possible, so that importing
+// doesn't need twice the space, and copied otherwise. Files that aren't in
+// dir are left unsaved. Imported threads are marked complete, so that an
+// Archiver doesn't start following them; any existing record of the same
+// thread is replaced.
+func ImportDir(store Store, board, dir string) (*Thread, error) {
+	path, err := findThreadJSON(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	record, err := decodeDump(data, board)
+	if err != nil {
+		return nil, fmt.Errorf("archiver: %s: %v", path, err)
+	}
+	if record.Board == "" {
+		return nil, fmt.Errorf("archiver: %s: unknown board", path)
+	}
+	if fi, err := os.Stat(path); err == nil {
+		record.FirstSeen, record.LastUpdate = fi.ModTime(), fi.ModTime()
+	}
+	record.Complete = true
+
+	for _, post := range record.Posts {
+		file := post.File
+		if file == nil {
+			continue
+		}
+		file.Saved, file.Pruned = false, false
+		src := filepath.Join(dir, fmt.Sprintf("%d%s", file.Id, file.Ext))
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := linkOrCopy(src, store.MediaPath(record, file)); err != nil {
+			return nil, err
+		}
+		file.Saved = true
+	}
+	if err := store.SaveThread(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ImportAll imports every thread found under root with ImportDir. Threads
+// are recognised by their JSON file, and their board is taken from the JSON
+// if it has it, or else from the directory layout: either
+// <board>/<thread>/ or <board>-<thread>/. It returns the number of threads
+// imported.
+func ImportAll(store Store, root string) (int, error) {
+	n := 0
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return err
+		}
+		if _, err := findThreadJSON(path); err != nil {
+			return nil
+		}
+		board := filepath.Base(filepath.Dir(path))
+		if i := strings.LastIndexByte(fi.Name(), '-'); i > 0 {
+			if _, err := strconv.ParseInt(fi.Name()[i+1:], 10, 64); err == nil {
+				board = fi.Name()[:i]
+			}
+		}
+		if _, err := ImportDir(store, board, path); err != nil {
+			return err
+		}
+		n++
+		// the thread's files are all that's left in it
+		return filepath.SkipDir
+	})
+	return n, err
+}
+
+// findThreadJSON returns the path of the thread's JSON in dir.
+func findThreadJSON(dir string) (string, error) {
+	candidates := []string{"thread.json"}
+	name := filepath.Base(dir)
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		name = name[i+1:]
+	}
+	if _, err := strconv.ParseInt(name, 10, 64); err == nil {
+		candidates = append(candidates, name+".json")
+	}
+	for _, c := range candidates {
+		path := filepath.Join(dir, c)
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// decodeDump reads a thread in either the API's layout, whose posts have
+// a "no" field, or this package's, whose posts have an "id".
+func decodeDump(data []byte, board string) (*Thread, error) {
+	var probe struct {
+		Posts []map[string]json.RawMessage `json:"posts"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	if len(probe.Posts) == 0 {
+		return nil, fmt.Errorf("no posts")
+	}
+	for i, post := range probe.Posts {
+		if post == nil {
+			return nil, fmt.Errorf("post %d is null", i)
+		}
+	}
+	if _, ok := probe.Posts[0]["no"]; ok {
+		thread, err := api.ParseThread(bytes.NewReader(data), board)
+		if err != nil {
+			return nil, err
+		}
+		record := &Thread{Board: board, Id: thread.Id()}
+		for _, post := range thread.Posts {
+			record.Posts = append(record.Posts, newPost(post))
+		}
+		return record, nil
+	}
+	record := new(Thread)
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	if record.Board == "" {
+		record.Board = board
+	}
+	if record.Id == 0 {
+		record.Id = record.Posts[0].Id
+	}
+	return record, nil
+}
+
+// linkOrCopy puts the file at src at dst, by hard linking it if possible.
+func linkOrCopy(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(dst); err == nil {
+		// importing a dump in place, into a store at the same location
+		if si, err := os.Stat(src); err == nil && os.SameFile(fi, si) {
+			return nil
+		}
+		os.Remove(dst)
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(dst+".tmp", dst)
+}
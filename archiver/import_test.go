@@ -0,0 +1,95 @@
+package archiver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportAll(t *testing.T) {
+	src, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		// the API's own layout, named after the thread
+		"g/1/1.json":  `{"posts":[{"no":1,"resto":0,"com":"hi","tim":100,"ext":".jpg","filename":"a","fsize":4,"md5":"XrY7u+Ae7tCTyyK7j1rNww=="},{"no":2,"resto":1,"tim":200,"ext":".png","filename":"b","fsize":4}]}`,
+		"g/1/100.jpg": "data",
+		// 4get's flat layout
+		"v-5/thread.json":  `{"board":"v","id":5,"posts":[{"id":5,"subject":"games","file":{"id":500,"ext":".gif","name":"c","size":4}}]}`,
+		"v-5/500.gif":      "data",
+		"unrelated/x.json": `{}`,
+	}
+	for name, data := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := DirStore(dir)
+	n, err := ImportAll(store, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Expected 2 threads to be imported, got %d", n)
+	}
+	threads, err := store.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*Thread)
+	for _, thread := range threads {
+		got[thread.Board] = thread
+		if !thread.Complete {
+			t.Errorf("Expected /%s/%d to be marked complete", thread.Board, thread.Id)
+		}
+	}
+	g, v := got["g"], got["v"]
+	if g == nil || g.Id != 1 || len(g.Posts) != 2 || g.Posts[0].Comment != "hi" {
+		t.Fatalf("Unexpected record for /g/: %+v", g)
+	}
+	if !g.Posts[0].File.Saved || g.Posts[1].File.Saved {
+		t.Error("Only the files present in the dump should be saved")
+	}
+	if v == nil || v.Id != 5 || v.Posts[0].Subject != "games" || !v.Posts[0].File.Saved {
+		t.Fatalf("Unexpected record for /v/: %+v", v)
+	}
+	data, err := ioutil.ReadFile(store.MediaPath(v, v.Posts[0].File))
+	if err != nil || string(data) != "data" {
+		t.Errorf("Expected the file to be imported, got %q, %v", data, err)
+	}
+}
+
+func TestImportNullPost(t *testing.T) {
+	src, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+
+	for _, data := range []string{
+		`{"posts":[null]}`,
+		`{"board":"v","id":5,"posts":[{"id":5},null]}`,
+		`{"posts":[{"no":1,"resto":0},null]}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(src, "thread.json"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ImportDir(DirStore(src), "g", src); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package archiver
+
+import (
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// boardSample is what the archiver remembers of a board's catalog from the
+// last time it looked, to work out how fast new threads are being made.
+type boardSample struct {
+	at      time.Time
+	seen    map[int64]bool
+	catalog api.Catalog
+	// rate is a moving average of new threads per second, or negative if
+	// it isn't known yet
+	rate float64
+}
+
+// sample records the board's catalog and checks the followed threads on it
+// for the risk of being pruned.
+func (self *Archiver) sample(board string, cat api.Catalog, now time.Time) {
+	if self.samples == nil {
+		self.samples = make(map[string]*boardSample)
+		self.atRisk = make(map[ThreadRef]bool)
+	}
+	seen := make(map[int64]bool)
+	type position struct{ page, rank int }
+	positions := make(map[int64]position)
+	rank := 0
+	for _, page := range cat {
+		for _, thread := range page.Threads {
+			rank++
+			seen[thread.Id()] = true
+			positions[thread.Id()] = position{page.Page, rank}
+		}
+	}
+
+	last := self.samples[board]
+	s := &boardSample{at: now, seen: seen, catalog: cat, rate: -1}
+	if last != nil {
+		s.rate = last.rate
+		if elapsed := now.Sub(last.at).Seconds(); elapsed > 0 {
+			created := 0
+			for id := range seen {
+				if !last.seen[id] {
+					created++
+				}
+			}
+			rate := float64(created) / elapsed
+			if s.rate < 0 {
+				s.rate = rate
+			} else {
+				s.rate = (s.rate + rate) / 2
+			}
+		}
+	}
+	self.samples[board] = s
+
+	// stickies already there on the first look aren't new
+	if last != nil && self.OnSticky != nil {
+		for _, thread := range api.NewStickies(last.catalog, cat) {
+			self.OnSticky(board, thread)
+		}
+	}
+
+	for ref := range self.threads {
+		if ref.Board != board {
+			continue
+		}
+		pos, ok := positions[ref.Id]
+		if !ok {
+			continue
+		}
+		// every new thread pushes the last one off the board, so the thread
+		// is gone once as many threads are made as there are below it
+		eta := time.Duration(-1)
+		if s.rate > 0 {
+			eta = time.Duration(float64(rank-pos.rank+1) / s.rate * float64(time.Second))
+		}
+		risk := (self.PrunePage > 0 && pos.page >= self.PrunePage) ||
+			(self.PruneWithin > 0 && eta >= 0 && eta <= self.PruneWithin)
+		if risk && !self.atRisk[ref] && self.OnPruneRisk != nil {
+			self.OnPruneRisk(ref, pos.page, eta)
+		}
+		self.atRisk[ref] = risk
+	}
+}
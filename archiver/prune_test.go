@@ -0,0 +1,84 @@
+package archiver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// stubCatalog makes a catalog with the given thread IDs on each page.
+func stubCatalog(t *testing.T, pages ...[]int64) api.Catalog {
+	cat := make(api.Catalog, len(pages))
+	for i, ids := range pages {
+		cat[i].Page = i + 1
+		for _, id := range ids {
+			thread, err := api.ParseThread(strings.NewReader(fmt.Sprintf(`{"posts":[{"no":%d,"resto":0}]}`, id)), "g")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cat[i].Threads = append(cat[i].Threads, thread)
+		}
+	}
+	return cat
+}
+
+func TestPruneRisk(t *testing.T) {
+	type event struct {
+		ref  ThreadRef
+		page int
+		eta  time.Duration
+	}
+	var events []event
+	a := &Archiver{
+		PrunePage:   3,
+		PruneWithin: 30 * time.Second,
+		OnPruneRisk: func(ref ThreadRef, page int, eta time.Duration) {
+			events = append(events, event{ref, page, eta})
+		},
+	}
+	a.threads = map[ThreadRef]*tracked{{"g", 2}: {}, {"g", 5}: {}}
+
+	now := time.Now()
+	a.sample("g", stubCatalog(t, []int64{1, 2}, []int64{3, 4}, []int64{5}), now)
+	if len(events) != 1 || events[0].ref.Id != 5 || events[0].page != 3 || events[0].eta >= 0 {
+		t.Fatalf("Thread 5 should be at risk by page with no ETA, got %+v", events)
+	}
+
+	// two new threads in 10 seconds: thread 2 has 1 thread below it, so it
+	// should be gone after 2 more, in 10 seconds
+	a.sample("g", stubCatalog(t, []int64{6, 7}, []int64{1, 2}, []int64{3}), now.Add(10*time.Second))
+	if len(events) != 2 || events[1].ref.Id != 2 || events[1].eta != 10*time.Second {
+		t.Fatalf("Thread 2 should be predicted to be pruned, got %+v", events)
+	}
+
+	a.sample("g", stubCatalog(t, []int64{2, 6}, []int64{7, 1}, []int64{3}), now.Add(time.Hour))
+	a.sample("g", stubCatalog(t, []int64{6, 7}, []int64{1, 3}, []int64{2}), now.Add(time.Hour+time.Second))
+	if len(events) != 3 || events[2].ref.Id != 2 {
+		t.Fatalf("Thread 2 should be at risk again after being bumped, got %+v", events)
+	}
+}
+
+func TestOnSticky(t *testing.T) {
+	sticky, err := api.ParseThread(strings.NewReader(`{"posts":[{"no":9,"resto":0,"sticky":1}]}`), "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stickies []int64
+	a := &Archiver{OnSticky: func(board string, thread *api.Thread) {
+		stickies = append(stickies, thread.Id())
+	}}
+	now := time.Now()
+	first := stubCatalog(t, []int64{1})
+	first[0].Threads = append(first[0].Threads, sticky)
+	a.sample("g", first, now)
+	a.sample("g", stubCatalog(t, []int64{1}), now.Add(time.Minute))
+	second := stubCatalog(t, []int64{1})
+	second[0].Threads = append(second[0].Threads, sticky)
+	a.sample("g", second, now.Add(2*time.Minute))
+	if len(stickies) != 1 || stickies[0] != 9 {
+		t.Fatalf("Only the sticky that reappeared should be reported, got %v", stickies)
+	}
+}
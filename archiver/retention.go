@@ -0,0 +1,113 @@
+package archiver
+
+import (
+	"sort"
+	"time"
+)
+
+// A Retention policy limits what is kept in a Store. The zero value keeps
+// everything.
+type Retention struct {
+	// MediaAge is how long files are kept after their thread is complete.
+	// If it is 0, files are kept forever.
+	MediaAge time.Duration
+	// MinReplies drops complete threads with fewer replies than this
+	// entirely, files included.
+	MinReplies int
+	// MaxMediaBytes caps the total size of the stored files. When it is
+	// exceeded, the files of the threads that were updated longest ago are
+	// removed first. If it is 0, there is no cap.
+	MaxMediaBytes int64
+}
+
+// Apply enforces the policy on the store as of the given time. Files that are
+// removed are marked as pruned in their thread's record, so that they aren't
+// downloaded again.
+func (self *Retention) Apply(store Store, now time.Time) error {
+	threads, err := store.Threads()
+	if err != nil {
+		return err
+	}
+
+	kept := threads[:0]
+	for _, thread := range threads {
+		// the OP doesn't count as a reply
+		if thread.Complete && len(thread.Posts)-1 < self.MinReplies {
+			if err := store.DeleteThread(thread); err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, thread)
+	}
+	threads = kept
+
+	if self.MediaAge > 0 {
+		for _, thread := range threads {
+			if thread.Complete && now.Sub(thread.LastUpdate) > self.MediaAge {
+				if err := prune(store, thread, -1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if self.MaxMediaBytes > 0 {
+		var total int64
+		for _, thread := range threads {
+			total += mediaBytes(thread)
+		}
+		// least recently updated first
+		sort.Slice(threads, func(i, j int) bool {
+			return threads[i].LastUpdate.Before(threads[j].LastUpdate)
+		})
+		for _, thread := range threads {
+			if total <= self.MaxMediaBytes {
+				break
+			}
+			excess := total - self.MaxMediaBytes
+			before := mediaBytes(thread)
+			if err := prune(store, thread, excess); err != nil {
+				return err
+			}
+			total -= before - mediaBytes(thread)
+		}
+	}
+	return nil
+}
+
+// mediaBytes is the size of the thread's files that are in the store.
+func mediaBytes(thread *Thread) (n int64) {
+	for _, post := range thread.Posts {
+		if post.File != nil && post.File.Saved {
+			n += int64(post.File.Size)
+		}
+	}
+	return
+}
+
+// prune removes the thread's stored files, oldest first, until at least limit
+// bytes have been removed, or all of them if limit is negative.
+func prune(store Store, thread *Thread, limit int64) error {
+	var removed int64
+	changed := false
+	for _, post := range thread.Posts {
+		if limit >= 0 && removed >= limit {
+			break
+		}
+		file := post.File
+		if file == nil || !file.Saved {
+			continue
+		}
+		if err := store.DeleteMedia(thread, file); err != nil {
+			return err
+		}
+		file.Saved, file.Pruned = false, true
+		removed += int64(file.Size)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return store.SaveThread(thread)
+}
@@ -0,0 +1,62 @@
+package archiver
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := DirStore(dir)
+
+	now := time.Now()
+	file := func(id int64, size int) *File {
+		return &File{Id: id, Ext: ".jpg", Size: size, Saved: true}
+	}
+	threads := []*Thread{
+		// too few replies
+		{Board: "g", Id: 1, Complete: true, LastUpdate: now, Posts: []*Post{{Id: 1}}},
+		// old enough for its files to go
+		{Board: "g", Id: 2, Complete: true, LastUpdate: now.Add(-48 * time.Hour), Posts: []*Post{{Id: 2, File: file(20, 100)}, {Id: 3}}},
+		// live, but the least recently updated of the rest
+		{Board: "g", Id: 4, LastUpdate: now.Add(-time.Hour), Posts: []*Post{{Id: 4, File: file(40, 100)}, {Id: 5, File: file(50, 100)}}},
+		{Board: "g", Id: 6, LastUpdate: now, Posts: []*Post{{Id: 6, File: file(60, 100)}}},
+	}
+	for _, thread := range threads {
+		if err := store.SaveThread(thread); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &Retention{MediaAge: 24 * time.Hour, MinReplies: 1, MaxMediaBytes: 200}
+	if err := r.Apply(store, now); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := store.Threads()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int64]*Thread)
+	for _, thread := range saved {
+		got[thread.Id] = thread
+	}
+	if _, ok := got[1]; ok {
+		t.Fatal("Thread 1 should have been dropped for having no replies")
+	}
+	if f := got[2].Posts[0].File; f.Saved || !f.Pruned {
+		t.Fatal("Thread 2's file should have been pruned for age")
+	}
+	if f := got[4].Posts[0].File; !f.Pruned {
+		t.Fatal("Thread 4's first file should have been evicted for size")
+	}
+	if got[4].Posts[1].File.Pruned || got[6].Posts[0].File.Pruned {
+		t.Fatal("Only as many files as needed should be evicted")
+	}
+}
@@ -0,0 +1,137 @@
+package archiver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule is a set of times given in the five-field syntax of cron:
+// minute, hour, day of the month, month and day of the week, each of which is
+// a *, a number, a range like 1-5, or a comma-separated list of these, and
+// can be followed by a step like */15. As in cron, if both the day of the
+// month and the day of the week are restricted, a day matching either one is
+// in the schedule. The shorthands @hourly, @daily, @weekly and @monthly are
+// also accepted.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// whether dom and dow were given as *
+	anyDom, anyDow bool
+}
+
+var scheduleShorthands = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule parses a cron-style schedule, such as "0 * * * *" for the
+// start of every hour.
+func ParseSchedule(spec string) (*Schedule, error) {
+	if s, ok := scheduleShorthands[spec]; ok {
+		spec = s
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("archiver: schedule %q: expected 5 fields, got %d", spec, len(fields))
+	}
+	self := new(Schedule)
+	bounds := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&self.minute, 0, 59},
+		{&self.hour, 0, 23},
+		{&self.dom, 1, 31},
+		{&self.month, 1, 12},
+		{&self.dow, 0, 7},
+	}
+	for i, field := range fields {
+		bits, err := parseField(field, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("archiver: schedule %q: %v", spec, err)
+		}
+		*bounds[i].bits = bits
+	}
+	// 7 is Sunday too
+	if self.dow&(1<<7) != 0 {
+		self.dow |= 1
+	}
+	self.anyDom = fields[2] == "*"
+	self.anyDow = fields[4] == "*"
+	return self, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step, part = n, part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			var err error
+			if i := strings.IndexByte(part, '-'); i >= 0 {
+				lo, err = strconv.Atoi(part[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(part[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(part)
+				hi = lo
+			}
+			if err != nil || lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			bits |= 1 << uint(n)
+		}
+	}
+	return bits, nil
+}
+
+func (self *Schedule) day(t time.Time) bool {
+	dom := self.dom&(1<<uint(t.Day())) != 0
+	dow := self.dow&(1<<uint(t.Weekday())) != 0
+	if self.anyDom || self.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time in the schedule after t, or the zero time if
+// there is none, as with "0 0 30 2 *".
+func (self *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case self.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !self.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case self.hour&(1<<uint(t.Hour())) == 0:
+			// Truncate works in absolute time, which is off by the
+			// zone's offset in zones like +05:30
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			if !next.After(t) {
+				// a DST change made the hour ambiguous
+				next = t.Add(time.Hour)
+			}
+			t = next
+		case self.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
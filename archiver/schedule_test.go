@@ -0,0 +1,60 @@
+package archiver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	// a Wednesday
+	start := time.Date(2024, 1, 31, 10, 7, 30, 0, time.UTC)
+	for _, test := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 1, 31, 13, 0, 0, 0, time.UTC)},
+		{"30 6 * * 1,5", time.Date(2024, 2, 2, 6, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 7", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		s, err := ParseSchedule(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(start); !got.Equal(test.want) {
+			t.Errorf("%q: expected %v, got %v", test.spec, test.want, got)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestScheduleZones(t *testing.T) {
+	for _, offset := range []int{5*3600 + 1800, 5*3600 + 2700, -(3*3600 + 1800)} {
+		zone := time.FixedZone("", offset)
+		start := time.Date(2024, 1, 31, 10, 7, 30, 0, zone)
+		for _, test := range []struct {
+			spec string
+			want time.Time
+		}{
+			{"0 12 * * *", time.Date(2024, 1, 31, 12, 0, 0, 0, zone)},
+			{"@daily", time.Date(2024, 2, 1, 0, 0, 0, 0, zone)},
+			{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, zone)},
+		} {
+			s, err := ParseSchedule(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(start); !got.Equal(test.want) {
+				t.Errorf("%q at %s: expected %v, got %v", test.spec, start.Format("-07:00"), test.want, got)
+			}
+		}
+	}
+}
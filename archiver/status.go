@@ -0,0 +1,125 @@
+package archiver
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A Status describes what an Archiver is doing, as of the end of its last
+// poll.
+type Status struct {
+	// LastPoll is when the last poll finished, or zero if none has yet, and
+	// PollDuration is how long it took.
+	LastPoll     time.Time     `json:"last_poll"`
+	PollDuration time.Duration `json:"poll_duration"`
+	// Following is the number of threads being followed, each of which
+	// takes a request on every poll, and Swept is the number of threads
+	// only captured by sweeps.
+	Following int `json:"following"`
+	Swept     int `json:"swept"`
+	// Threads describes the followed threads, ordered by board and ID.
+	Threads []ThreadStatus `json:"threads"`
+	// Limiter is the state of the api package's rate limiter, which the
+	// archiver shares with everything else in the process.
+	Limiter api.LimiterStatus `json:"limiter"`
+}
+
+// A ThreadStatus describes a followed thread.
+type ThreadStatus struct {
+	Board      string    `json:"board"`
+	Id         int64     `json:"id"`
+	Posts      int       `json:"posts"`
+	LastUpdate time.Time `json:"last_update"`
+	// Errors counts the updates in a row that have failed, and LastError is
+	// the most recent of their errors.
+	Errors    int    `json:"errors"`
+	LastError string `json:"last_error,omitempty"`
+	// AtRisk is set if the thread is expected to be pruned soon.
+	AtRisk bool `json:"at_risk,omitempty"`
+}
+
+// status is the archiver's state as last published for Status, which can be
+// read while a poll is running.
+type status struct {
+	mu        sync.Mutex
+	firstPoll time.Time
+	Status
+}
+
+// Status returns what the archiver is doing. It is safe to call while the
+// archiver is running, e.g. from an HTTP handler.
+func (self *Archiver) Status() Status {
+	self.status.mu.Lock()
+	s := self.status.Status
+	self.status.mu.Unlock()
+	s.Threads = append([]ThreadStatus(nil), s.Threads...)
+	s.Limiter = api.Limiter()
+	return s
+}
+
+// Healthy returns an error if the archiver seems to be stuck: once it has
+// started polling, a poll should finish at least every few intervals. It is
+// safe to call while the archiver is running.
+func (self *Archiver) Healthy() error {
+	self.status.mu.Lock()
+	last := self.status.LastPoll
+	if last.IsZero() {
+		last = self.status.firstPoll
+	}
+	self.status.mu.Unlock()
+	if last.IsZero() {
+		return nil
+	}
+	limit := 3*self.interval() + self.Jitter
+	if since := api.DefaultClock.Now().Sub(last); since > limit {
+		return fmt.Errorf("archiver: no poll has finished in %v", since.Round(time.Second))
+	}
+	return nil
+}
+
+// startPoll records the start of a poll.
+func (self *Archiver) startPoll(now time.Time) {
+	self.status.mu.Lock()
+	if self.status.firstPoll.IsZero() {
+		self.status.firstPoll = now
+	}
+	self.status.mu.Unlock()
+}
+
+// publish makes the state of the archiver at the end of a poll that started
+// at start available to Status.
+func (self *Archiver) publish(start time.Time) {
+	threads := make([]ThreadStatus, 0, len(self.threads))
+	for ref, t := range self.threads {
+		threads = append(threads, ThreadStatus{
+			Board:      ref.Board,
+			Id:         ref.Id,
+			Posts:      len(t.record.Posts),
+			LastUpdate: t.record.LastUpdate,
+			Errors:     t.errors,
+			LastError:  t.lastError,
+			AtRisk:     self.atRisk[ref],
+		})
+	}
+	sort.Slice(threads, func(i, j int) bool {
+		if threads[i].Board != threads[j].Board {
+			return threads[i].Board < threads[j].Board
+		}
+		return threads[i].Id < threads[j].Id
+	})
+
+	now := api.DefaultClock.Now()
+	self.status.mu.Lock()
+	self.status.Status = Status{
+		LastPoll:     now,
+		PollDuration: now.Sub(start),
+		Following:    len(self.threads),
+		Swept:        len(self.swept),
+		Threads:      threads,
+	}
+	self.status.mu.Unlock()
+}
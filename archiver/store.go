@@ -0,0 +1,214 @@
+package archiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A Thread is the archived record of a thread. Unlike an api.Thread, it keeps
+// posts that have since been deleted.
+type Thread struct {
+	Board      string    `json:"board"`
+	Id         int64     `json:"id"`
+	Posts      []*Post   `json:"posts"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastUpdate time.Time `json:"last_update"`
+	// Complete is set once the thread has 404'd, after which it is no
+	// longer updated.
+	Complete bool `json:"complete"`
+	// Swept is set on threads that have only been captured by sweeps, which
+	// aren't followed in between them.
+	Swept bool `json:"swept,omitempty"`
+}
+
+// A Post is the archived record of a post.
+type Post struct {
+	Id      int64     `json:"id"`
+	Time    time.Time `json:"time"`
+	Name    string    `json:"name,omitempty"`
+	Trip    string    `json:"trip,omitempty"`
+	Special string    `json:"special,omitempty"`
+	Capcode string    `json:"capcode,omitempty"`
+	Country string    `json:"country,omitempty"`
+	Email   string    `json:"email,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	File    *File     `json:"file,omitempty"`
+	// Annotations are the results of the api package's enrichers at the
+	// time the post was first archived.
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	// Deleted is when the post was first noticed to be missing from the
+	// thread, or nil if it hasn't been deleted.
+	Deleted *time.Time `json:"deleted,omitempty"`
+}
+
+// A File is the archived record of a post's file.
+type File struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Ext         string `json:"ext"`
+	Size        int    `json:"size"`
+	MD5         []byte `json:"md5"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ThumbWidth  int    `json:"thumb_width"`
+	ThumbHeight int    `json:"thumb_height"`
+	Deleted     bool   `json:"deleted,omitempty"`
+	Spoiler     bool   `json:"spoiler,omitempty"`
+	// Saved is true once the file itself has been stored.
+	Saved bool `json:"saved,omitempty"`
+	// Pruned is true if the file was removed from the store by the
+	// retention policy. It won't be downloaded again.
+	Pruned bool `json:"pruned,omitempty"`
+	// Skipped is true if the file was left out by the archiver's
+	// MediaFilter.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+func newPost(p *api.Post) *Post {
+	post := &Post{
+		Id:      p.Id,
+		Time:    p.Time,
+		Name:    p.Name,
+		Trip:    p.Trip,
+		Special: p.Special,
+		Capcode: p.Capcode,
+		Country: p.Country,
+		Email:   p.Email,
+		Subject: p.Subject,
+		Comment: p.CommentHTML(),
+
+		Annotations: p.Annotations,
+	}
+	if f := p.File; f != nil {
+		post.File = &File{
+			Id:          f.Id,
+			Name:        f.Name,
+			Ext:         f.Ext,
+			Size:        f.Size,
+			MD5:         f.MD5,
+			Width:       f.Width,
+			Height:      f.Height,
+			ThumbWidth:  f.ThumbWidth,
+			ThumbHeight: f.ThumbHeight,
+			Deleted:     f.Deleted,
+			Spoiler:     f.Spoiler,
+		}
+	}
+	return post
+}
+
+// A Store persists archived threads and their files.
+type Store interface {
+	// Threads returns every thread in the store.
+	Threads() ([]*Thread, error)
+	// SaveThread creates or replaces the record of a thread.
+	SaveThread(thread *Thread) error
+	// MediaPath returns the path that the given file of a thread should be
+	// downloaded to.
+	MediaPath(thread *Thread, file *File) string
+	// DeleteThread removes a thread and all of its files from the store.
+	DeleteThread(thread *Thread) error
+	// DeleteMedia removes a single file of a thread from the store.
+	DeleteMedia(thread *Thread, file *File) error
+}
+
+// An EventStore is a Store that also keeps an append-only log of the events
+// of each thread.
+type EventStore interface {
+	Store
+	// AppendEvents adds events to the end of a thread's log.
+	AppendEvents(thread *Thread, events []api.Event) error
+}
+
+// DirStore stores each thread in its own directory under a root directory,
+// as <board>/<thread>/thread.json, with its files alongside. Its event logs
+// are kept in <board>/<thread>/events.jsonl, one JSON-encoded api.Event per
+// line, which can be tailed by other programs.
+type DirStore string
+
+func (self DirStore) dir(board string, id int64) string {
+	return filepath.Join(string(self), board, strconv.FormatInt(id, 10))
+}
+
+func (self DirStore) Threads() ([]*Thread, error) {
+	paths, err := filepath.Glob(filepath.Join(string(self), "*", "*", "thread.json"))
+	if err != nil {
+		return nil, err
+	}
+	threads := make([]*Thread, 0, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		thread := new(Thread)
+		if err = json.Unmarshal(data, thread); err != nil {
+			return nil, fmt.Errorf("archiver: %s: %v", path, err)
+		}
+		threads = append(threads, thread)
+	}
+	return threads, nil
+}
+
+func (self DirStore) SaveThread(thread *Thread) error {
+	dir := self.dir(thread.Board, thread.Id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(thread, "", "\t")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so that a crash never leaves a
+	// half-written record behind
+	path := filepath.Join(dir, "thread.json")
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (self DirStore) MediaPath(thread *Thread, file *File) string {
+	return filepath.Join(self.dir(thread.Board, thread.Id), fmt.Sprintf("%d%s", file.Id, file.Ext))
+}
+
+func (self DirStore) DeleteThread(thread *Thread) error {
+	return os.RemoveAll(self.dir(thread.Board, thread.Id))
+}
+
+func (self DirStore) DeleteMedia(thread *Thread, file *File) error {
+	return self.RemoveMedia(self.MediaPath(thread, file))
+}
+
+func (self DirStore) AppendEvents(thread *Thread, events []api.Event) error {
+	dir := self.dir(thread.Board, thread.Id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "events.jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// a single write, so that readers never see a partial batch
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
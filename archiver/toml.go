@@ -0,0 +1,295 @@
+package archiver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML reads the subset of TOML that a Config needs: tables, arrays of
+// tables, and keys set to strings, integers, floats, booleans, arrays and
+// inline tables. Dates, multi-line strings and dotted keys aren't supported.
+// The result has the same shape as a JSON document decoded into an
+// interface{}, so that it can be checked and decoded the same way.
+func parseTOML(data string) (map[string]interface{}, error) {
+	p := &tomlParser{s: data}
+	root := make(map[string]interface{})
+	defined := make(map[string]bool)
+	table := root
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return root, nil
+		}
+		if p.peek() == '[' {
+			t, err := p.header(root, defined)
+			if err != nil {
+				return nil, err
+			}
+			table = t
+		} else {
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipBlank(false)
+			if !p.consume('=') {
+				return nil, p.errorf("expected = after %q", key)
+			}
+			p.skipBlank(false)
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := table[key]; ok {
+				return nil, p.errorf("%q is set twice", key)
+			}
+			table[key] = v
+		}
+		p.skipBlank(false)
+		if !p.eof() && !p.consume('\n') {
+			return nil, p.errorf("expected the end of the line")
+		}
+	}
+}
+
+type tomlParser struct {
+	s   string
+	pos int
+}
+
+func (self *tomlParser) eof() bool {
+	return self.pos >= len(self.s)
+}
+
+func (self *tomlParser) peek() byte {
+	if self.eof() {
+		return 0
+	}
+	return self.s[self.pos]
+}
+
+func (self *tomlParser) consume(c byte) bool {
+	if self.peek() == c && !self.eof() {
+		self.pos++
+		return true
+	}
+	return false
+}
+
+func (self *tomlParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(self.s[:self.pos], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank skips spaces and comments, and newlines too if newlines is set.
+func (self *tomlParser) skipBlank(newlines bool) {
+	for !self.eof() {
+		switch c := self.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			self.pos++
+		case c == '\n' && newlines:
+			self.pos++
+		case c == '#':
+			for !self.eof() && self.peek() != '\n' {
+				self.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// header reads a [table] or [[array of tables]] header, returning the table
+// that the keys following it go in.
+func (self *tomlParser) header(root map[string]interface{}, defined map[string]bool) (map[string]interface{}, error) {
+	self.pos++
+	array := self.consume('[')
+	end := strings.IndexByte(self.s[self.pos:], ']')
+	if end < 0 {
+		return nil, self.errorf("unterminated table header")
+	}
+	name := strings.TrimSpace(self.s[self.pos : self.pos+end])
+	self.pos += end + 1
+	if array && !self.consume(']') {
+		return nil, self.errorf("expected ]] after %q", name)
+	}
+	parts := strings.Split(name, ".")
+	table := root
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, self.errorf("bad table name %q", name)
+		}
+		last := i == len(parts)-1
+		switch v := table[part].(type) {
+		case nil:
+			if last && array {
+				t := make(map[string]interface{})
+				table[part] = []interface{}{t}
+				return t, nil
+			}
+			t := make(map[string]interface{})
+			table[part], table = t, t
+		case map[string]interface{}:
+			if last && (array || defined[name]) {
+				return nil, self.errorf("table %q is defined twice", name)
+			}
+			table = v
+		case []interface{}:
+			t, ok := v[len(v)-1].(map[string]interface{})
+			if !ok || (last && !array) {
+				return nil, self.errorf("%q is not a table", name)
+			}
+			if last {
+				t = make(map[string]interface{})
+				table[part] = append(v, t)
+			}
+			table = t
+		default:
+			return nil, self.errorf("%q is not a table", name)
+		}
+	}
+	defined[name] = true
+	return table, nil
+}
+
+func isBareKey(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (self *tomlParser) key() (string, error) {
+	if c := self.peek(); c == '"' || c == '\'' {
+		return self.str()
+	}
+	start := self.pos
+	for !self.eof() && isBareKey(self.peek()) {
+		self.pos++
+	}
+	if self.pos == start {
+		return "", self.errorf("expected a key")
+	}
+	return self.s[start:self.pos], nil
+}
+
+func (self *tomlParser) str() (string, error) {
+	quote := self.peek()
+	start := self.pos
+	self.pos++
+	for !self.eof() {
+		switch c := self.peek(); {
+		case c == '\n':
+			return "", self.errorf("unterminated string")
+		case c == '\\' && quote == '"':
+			self.pos++
+			if self.eof() || self.peek() == '\n' {
+				return "", self.errorf("unterminated string")
+			}
+			self.pos++
+		case c == quote:
+			self.pos++
+			if quote == '\'' {
+				return self.s[start+1 : self.pos-1], nil
+			}
+			s, err := strconv.Unquote(self.s[start:self.pos])
+			if err != nil {
+				return "", self.errorf("bad string %s", self.s[start:self.pos])
+			}
+			return s, nil
+		default:
+			self.pos++
+		}
+	}
+	return "", self.errorf("unterminated string")
+}
+
+func (self *tomlParser) value() (interface{}, error) {
+	switch c := self.peek(); {
+	case c == '"' || c == '\'':
+		return self.str()
+	case c == '[':
+		return self.array()
+	case c == '{':
+		return self.inlineTable()
+	case strings.HasPrefix(self.s[self.pos:], "true"):
+		self.pos += 4
+		return true, nil
+	case strings.HasPrefix(self.s[self.pos:], "false"):
+		self.pos += 5
+		return false, nil
+	}
+	start := self.pos
+	for !self.eof() && strings.IndexByte("0123456789+-_.eE", self.peek()) >= 0 {
+		self.pos++
+	}
+	num := strings.Replace(self.s[start:self.pos], "_", "", -1)
+	if num == "" {
+		return nil, self.errorf("expected a value")
+	}
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(num, 64); err == nil {
+		return f, nil
+	}
+	return nil, self.errorf("bad number %q", num)
+}
+
+func (self *tomlParser) array() (interface{}, error) {
+	self.pos++
+	values := []interface{}{}
+	for {
+		self.skipBlank(true)
+		if self.consume(']') {
+			return values, nil
+		}
+		v, err := self.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		self.skipBlank(true)
+		if self.consume(']') {
+			return values, nil
+		}
+		if !self.consume(',') {
+			return nil, self.errorf("expected , or ] in array")
+		}
+	}
+}
+
+func (self *tomlParser) inlineTable() (interface{}, error) {
+	self.pos++
+	table := make(map[string]interface{})
+	self.skipBlank(false)
+	if self.consume('}') {
+		return table, nil
+	}
+	for {
+		self.skipBlank(false)
+		key, err := self.key()
+		if err != nil {
+			return nil, err
+		}
+		self.skipBlank(false)
+		if !self.consume('=') {
+			return nil, self.errorf("expected = after %q", key)
+		}
+		self.skipBlank(false)
+		v, err := self.value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := table[key]; ok {
+			return nil, self.errorf("%q is set twice", key)
+		}
+		table[key] = v
+		self.skipBlank(false)
+		if self.consume('}') {
+			return table, nil
+		}
+		if !self.consume(',') {
+			return nil, self.errorf("expected , or } in inline table")
+		}
+	}
+}
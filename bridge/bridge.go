@@ -0,0 +1,132 @@
+// Package bridge relays 4chan posts to chat services.
+//
+// A Sink delivers a single post somewhere. Discord and Matrix sinks are
+// provided, and a Router picks which sinks a post goes to based on its board
+// and thread. Feed it the new posts after each (*api.Thread).Update:
+//
+//	n, _, err := thread.Update()
+//	...
+//	for _, post := range thread.Posts[len(thread.Posts)-n:] {
+//		router.Send(post)
+//	}
+package bridge
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A Sink delivers posts to some destination.
+type Sink interface {
+	Send(post *api.Post) error
+}
+
+// A Router sends each post to the sinks registered for its thread, or if there
+// are none, to the sinks registered for its board.
+type Router struct {
+	boards  map[string][]Sink
+	threads map[threadKey][]Sink
+}
+
+type threadKey struct {
+	board string
+	id    int64
+}
+
+// Board registers sinks for every post on a board.
+func (self *Router) Board(board string, sinks ...Sink) {
+	if self.boards == nil {
+		self.boards = make(map[string][]Sink)
+	}
+	self.boards[board] = append(self.boards[board], sinks...)
+}
+
+// Thread registers sinks for the posts in a single thread. These take the
+// place of the board's sinks for that thread.
+func (self *Router) Thread(board string, id int64, sinks ...Sink) {
+	if self.threads == nil {
+		self.threads = make(map[threadKey][]Sink)
+	}
+	key := threadKey{board, id}
+	self.threads[key] = append(self.threads[key], sinks...)
+}
+
+// Send delivers the post to all of its sinks. Every sink is tried even if an
+// earlier one fails; the first error is returned.
+func (self *Router) Send(post *api.Post) error {
+	if err := checkThread(post); err != nil {
+		return err
+	}
+	board, id := post.Thread.Board, post.Thread.Id()
+	sinks, ok := self.threads[threadKey{board, id}]
+	if !ok {
+		sinks = self.boards[board]
+	}
+	var first error
+	for _, sink := range sinks {
+		if err := sink.Send(post); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Markdown renders a post's comment as Markdown: greentext becomes a quote
+// block, quotelinks are kept as plain text and Markdown syntax in the comment
+// is escaped.
+func Markdown(post *api.Post) string {
+	lines := strings.Split(post.PlainText(), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, ">>"):
+			lines[i] = "**" + escapeMarkdown(line) + "**"
+		case strings.HasPrefix(line, ">"):
+			lines[i] = "> " + escapeMarkdown(strings.TrimPrefix(line, ">"))
+		default:
+			lines[i] = escapeMarkdown(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`, "[", `\[`, "]", `\]`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// checkThread returns an error if the post doesn't know which thread it is
+// in, as with posts that were decoded or built on their own, since it can't
+// be routed or linked to.
+func checkThread(post *api.Post) error {
+	if post.Thread == nil {
+		return fmt.Errorf("bridge: post %d has no thread", post.Id)
+	}
+	return nil
+}
+
+// title is the one line summary of a post used as a message heading.
+func title(post *api.Post) string {
+	s := fmt.Sprintf("/%s/ No.%d", post.Thread.Board, post.Id)
+	if post.Subject != "" {
+		s = html.UnescapeString(post.Subject) + " – " + s
+	}
+	return s
+}
+
+func checkResponse(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bridge: %s: %s", resp.Request.URL.Host, resp.Status)
+	}
+	return nil
+}
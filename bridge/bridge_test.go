@@ -0,0 +1,93 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+func testPost() *api.Post {
+	thread := &api.Thread{Board: "g"}
+	post := &api.Post{Id: 2, Thread: thread, Name: "Anonymous", Comment: `<span class="quote">&gt;implying</span><br>*bold*`}
+	thread.OP = &api.Post{Id: 1, Thread: thread}
+	thread.Posts = []*api.Post{thread.OP, post}
+	return post
+}
+
+func TestMarkdown(t *testing.T) {
+	got := Markdown(testPost())
+	want := "> implying\n\\*bold\\*"
+	if got != want {
+		t.Fatalf("Markdown should be %q, got %q", want, got)
+	}
+}
+
+type recorder []*api.Post
+
+func (self *recorder) Send(post *api.Post) error {
+	*self = append(*self, post)
+	return nil
+}
+
+func TestRouter(t *testing.T) {
+	var board, thread recorder
+	var r Router
+	r.Board("g", &board)
+	r.Thread("g", 1, &thread)
+
+	post := testPost()
+	if err := r.Send(post); err != nil {
+		t.Fatal(err)
+	}
+	post.Thread.OP.Id = 3
+	if err := r.Send(post); err != nil {
+		t.Fatal(err)
+	}
+	if len(thread) != 1 || len(board) != 1 {
+		t.Fatalf("Thread sinks should take the place of board sinks (thread got %d, board got %d)", len(thread), len(board))
+	}
+
+	if err := r.Send(&api.Post{Id: 4}); err == nil {
+		t.Fatal("A post without a thread should be an error")
+	}
+	if err := (&Discord{}).Send(&api.Post{Id: 4}); err == nil {
+		t.Fatal("Sinks should reject posts without a thread too")
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	var msg discordMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	defer func(ssl bool) { api.SSL = ssl }(api.SSL)
+	api.SSL = true
+	d := &Discord{WebhookURL: srv.URL}
+	post := testPost()
+	post.Subject = "Tom &amp; Jerry"
+	if err := d.Send(post); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Embeds) != 1 || msg.Embeds[0].URL != "https://boards.4chan.org/g/thread/1#p2" {
+		t.Fatalf("Unexpected message: %+v", msg)
+	}
+	if msg.Embeds[0].Title != "Tom & Jerry – /g/ No.2" {
+		t.Errorf("The subject should be unescaped, got %q", msg.Embeds[0].Title)
+	}
+
+	api.SSL = false
+	if err := d.Send(post); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Embeds[0].URL != "http://boards.4chan.org/g/thread/1#p2" {
+		t.Errorf("Links should follow api.SSL, got %q", msg.Embeds[0].URL)
+	}
+}
@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// Discord sends posts to a Discord channel through a webhook. Posts with
+// images get the thumbnail embedded.
+type Discord struct {
+	// WebhookURL is the URL of the channel's webhook.
+	WebhookURL string
+	// Username overrides the webhook's display name if it is not empty.
+	Username string
+	// Client is used to make requests. If it is nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+}
+
+type discordMessage struct {
+	Username string         `json:"username,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string            `json:"title"`
+	URL         string            `json:"url"`
+	Description string            `json:"description"`
+	Author      *discordAuthor    `json:"author,omitempty"`
+	Thumbnail   *discordThumbnail `json:"thumbnail,omitempty"`
+}
+
+type discordAuthor struct {
+	Name string `json:"name"`
+}
+
+type discordThumbnail struct {
+	URL string `json:"url"`
+}
+
+// Send posts a message for the post to the webhook.
+func (self *Discord) Send(post *api.Post) error {
+	if err := checkThread(post); err != nil {
+		return err
+	}
+	embed := discordEmbed{
+		Title:       title(post),
+		URL:         post.URL(),
+		Description: Markdown(post),
+		Author:      &discordAuthor{post.Name + post.Trip},
+	}
+	if post.File != nil && !post.File.Deleted && !post.File.Spoiler {
+		embed.Thumbnail = &discordThumbnail{post.ThumbURL()}
+	}
+	body, err := json.Marshal(discordMessage{self.Username, []discordEmbed{embed}})
+	if err != nil {
+		return err
+	}
+
+	client := self.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return checkResponse(client.Post(self.WebhookURL, "application/json", bytes.NewReader(body)))
+}
@@ -0,0 +1,74 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// Matrix sends posts as messages to a Matrix room.
+type Matrix struct {
+	txn int64 // first for 64-bit alignment of atomic operations
+
+	// Homeserver is the base URL of the homeserver, e.g.
+	// "https://matrix.org".
+	Homeserver string
+	// AccessToken authenticates the user that sends the messages.
+	AccessToken string
+	// RoomID is the ID of the room to send to (not an alias).
+	RoomID string
+	// Client is used to make requests. If it is nil, http.DefaultClient is
+	// used.
+	Client *http.Client
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// Send posts a message for the post to the room. The message is formatted
+// with the post's sanitized HTML, with a plain text fallback.
+func (self *Matrix) Send(post *api.Post) error {
+	if err := checkThread(post); err != nil {
+		return err
+	}
+	link := post.URL()
+	msg := matrixMessage{
+		MsgType:       "m.text",
+		Body:          title(post) + "\n" + post.PlainText() + "\n" + link,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: fmt.Sprintf(`<a href="%s"><b>%s</b></a><br>%s`, html.EscapeString(link), html.EscapeString(title(post)), post.SanitizedHTML()),
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// transaction IDs only need to be unique per access token
+	txn := fmt.Sprintf("4chan-%d-%d", time.Now().UnixNano(), atomic.AddInt64(&self.txn, 1))
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimRight(self.Homeserver, "/"), url.PathEscape(self.RoomID), txn)
+	req, err := http.NewRequest("PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+self.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := self.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return checkResponse(client.Do(req))
+}
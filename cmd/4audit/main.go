@@ -0,0 +1,65 @@
+// Command 4audit checks an archive made with the archiver package for
+// missing and corrupted files.
+//
+// Usage:
+//
+//	4audit [flags] dir
+//
+// Every file recorded as saved is checked against its MD5, and files that
+// should have been saved but weren't, as well as files that no record refers
+// to, are reported. The report is printed to standard output as JSON (see
+// archiver.AuditReport), and 4audit exits with status 1 if anything was
+// wrong. With -fix, orphaned files are removed afterwards.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/moshee/go-4chan-api/archiver"
+)
+
+var (
+	flagCAS = flag.Bool("cas", false, "the archive uses the content-addressed layout (archiver.CASStore)")
+	flagFix = flag.Bool("fix", false, "remove orphaned files")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] dir\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var store archiver.MediaStore = archiver.DirStore(flag.Arg(0))
+	if *flagCAS {
+		store = archiver.NewCASStore(flag.Arg(0))
+	}
+	report, err := archiver.Audit(store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+
+	if *flagFix {
+		removed, err := archiver.CollectGarbage(store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("removed %d orphaned files", len(removed))
+	}
+	if len(report.Problems) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,352 @@
+// Command 4get downloads 4chan threads, along with the files posted in them.
+//
+// Usage:
+//
+//	4get [flags] board/thread...
+//	4get [flags] -board board
+//
+// Each thread is saved as thread.json in its own directory, next to the files
+// posted in it. With -watch, 4get keeps updating the threads until they 404.
+// With -board, every live thread on the board is downloaded once, or only the
+// threads whose OP matches the -filter query (see api.ParseQuery).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+var (
+	flagOut     = flag.String("o", ".", "directory to save threads in")
+	flagBoard   = flag.String("board", "", "mirror every live thread on this board")
+	flagWatch   = flag.Bool("watch", false, "keep updating threads until they 404")
+	flagJobs    = flag.Int("j", 4, "number of files to download at once")
+	flagMedia   = flag.String("media", "", "comma separated list of file extensions to download, e.g. jpg,webm (default all)")
+	flagNoMedia = flag.Bool("nomedia", false, "only save thread.json, no files")
+	flagGallery = flag.Bool("gallery", false, "also write gallery.json, a manifest of the thread's files for image viewers")
+	flagLayout  = flag.String("layout", "tree", "output layout: tree (board/thread/) or flat (board-thread/)")
+	flagNaming  = flag.String("naming", "tim", "file naming: tim (server filename), original (uploaded filename) or md5")
+	flagFilter  = flag.String("filter", "", "with -board, only download threads whose OP matches this query")
+	flagSSL     = flag.Bool("ssl", true, "use HTTPS")
+
+	downloader api.Downloader
+
+	// files that have been saved already or are being saved, so that
+	// watching a thread doesn't check every file again on each update
+	downloaded      = make(map[int64]bool)
+	downloadedMutex sync.Mutex
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] board/thread...\n       %s [flags] -board board\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	api.SSL = *flagSSL
+
+	if *flagLayout != "tree" && *flagLayout != "flat" {
+		log.Fatalf("unknown layout %q", *flagLayout)
+	}
+	switch *flagNaming {
+	case "tim":
+		downloader.Naming = api.NameByTim
+	case "original":
+		downloader.Naming = api.NameByOriginal
+	case "md5":
+		downloader.Naming = api.NameByMD5
+	default:
+		log.Fatalf("unknown naming %q", *flagNaming)
+	}
+	if *flagBoard == "" && flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *flagBoard != "" {
+		mirrorBoard(*flagBoard)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, arg := range flag.Args() {
+		board, id, err := parseThreadArg(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		wg.Add(1)
+		go func(board string, id int64) {
+			defer wg.Done()
+			if err := getThread(board, id); err != nil {
+				log.Printf("/%s/%d: %v", board, id, err)
+			}
+		}(board, id)
+	}
+	wg.Wait()
+}
+
+// parseThreadArg accepts either board/thread or a thread URL.
+func parseThreadArg(arg string) (string, int64, error) {
+	arg = strings.TrimPrefix(arg, "https://")
+	arg = strings.TrimPrefix(arg, "http://")
+	arg = strings.TrimPrefix(arg, "boards.4chan.org")
+	arg = strings.TrimPrefix(arg, "boards.4channel.org")
+	parts := strings.Split(strings.Trim(arg, "/"), "/")
+	if len(parts) >= 3 && parts[1] == "thread" {
+		parts = append(parts[:1], parts[2])
+	}
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("%q is not of the form board/thread", arg)
+	}
+	id, err := strconv.ParseInt(strings.SplitN(parts[1], "#", 2)[0], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("%q is not of the form board/thread", arg)
+	}
+	return parts[0], id, nil
+}
+
+func mirrorBoard(board string) {
+	filter, err := api.ParseQuery(*flagFilter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cat, err := api.GetCatalog(board)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var ids []int64
+	for _, page := range cat {
+		for _, thread := range page.Threads {
+			if filter.Match(thread.OP) {
+				ids = append(ids, thread.Id())
+			}
+		}
+	}
+	log.Printf("/%s/: %d threads", board, len(ids))
+
+	for result := range api.GetThreadsFull(context.Background(), board, ids, nil) {
+		if result.Err != nil {
+			log.Printf("/%s/%d: %v", board, result.Id, result.Err)
+			continue
+		}
+		if err := save(result.Thread); err != nil {
+			log.Printf("/%s/%d: %v", board, result.Id, err)
+		}
+	}
+}
+
+func getThread(board string, id int64) error {
+	thread, err := api.GetThread(board, id)
+	if err != nil {
+		return err
+	}
+	if err = save(thread); err != nil {
+		return err
+	}
+	for *flagWatch {
+		n, _, err := thread.Update()
+		if errors.Is(err, api.ErrNotFound) {
+			log.Printf("/%s/%d: thread is gone", board, id)
+			return nil
+		}
+		if err != nil {
+			log.Printf("/%s/%d: %v", board, id, err)
+			time.Sleep(api.UpdateCooldown)
+			continue
+		}
+		if n > 0 {
+			log.Printf("/%s/%d: %d new posts", board, id, n)
+			if err = save(thread); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func threadDir(thread *api.Thread) string {
+	if *flagLayout == "flat" {
+		return filepath.Join(*flagOut, fmt.Sprintf("%s-%d", thread.Board, thread.Id()))
+	}
+	return filepath.Join(*flagOut, thread.Board, strconv.FormatInt(thread.Id(), 10))
+}
+
+// save writes out thread.json and downloads any files that haven't been
+// downloaded yet.
+func save(thread *api.Thread) error {
+	dir := threadDir(thread)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(dir, "thread.json"), thread); err != nil {
+		return err
+	}
+	if *flagGallery {
+		if err := writeGallery(filepath.Join(dir, "gallery.json"), thread); err != nil {
+			return err
+		}
+	}
+	if *flagNoMedia {
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, *flagJobs)
+		errs = make(chan error, 1)
+	)
+	for _, file := range thread.Files() {
+		if !wantMedia(file) || !claim(file.Id) {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(file *api.File) {
+			defer func() { <-sem; wg.Done() }()
+			if _, err := downloader.DownloadTo(file.Post, dir); err != nil {
+				downloadedMutex.Lock()
+				delete(downloaded, file.Id)
+				downloadedMutex.Unlock()
+				select {
+				case errs <- fmt.Errorf("%d%s: %v", file.Id, file.Ext, err):
+				default:
+				}
+			}
+		}(file)
+	}
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// claim marks a file as downloaded, returning false if it already was (or is
+// being downloaded by another goroutine). Failed downloads are unmarked so
+// that they're tried again.
+func claim(id int64) bool {
+	downloadedMutex.Lock()
+	defer downloadedMutex.Unlock()
+	if downloaded[id] {
+		return false
+	}
+	downloaded[id] = true
+	return true
+}
+
+func wantMedia(file *api.File) bool {
+	if file.Deleted {
+		return false
+	}
+	if *flagMedia == "" {
+		return true
+	}
+	ext := strings.TrimPrefix(file.Ext, ".")
+	for _, want := range strings.Split(*flagMedia, ",") {
+		if strings.EqualFold(strings.TrimPrefix(strings.TrimSpace(want), "."), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// The layout of thread.json. Threads can't be marshalled directly because
+// posts point back to their thread.
+type jsonThread struct {
+	Board string     `json:"board"`
+	Id    int64      `json:"id"`
+	Posts []jsonPost `json:"posts"`
+}
+
+type jsonPost struct {
+	Id      int64     `json:"id"`
+	Time    time.Time `json:"time"`
+	Name    string    `json:"name,omitempty"`
+	Trip    string    `json:"trip,omitempty"`
+	Special string    `json:"special,omitempty"`
+	Capcode string    `json:"capcode,omitempty"`
+	Country string    `json:"country,omitempty"`
+	Email   string    `json:"email,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	File    *jsonFile `json:"file,omitempty"`
+}
+
+type jsonFile struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`
+	Ext     string `json:"ext"`
+	Size    int    `json:"size"`
+	MD5     []byte `json:"md5"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Spoiler bool   `json:"spoiler,omitempty"`
+}
+
+func writeJSON(path string, thread *api.Thread) error {
+	t := jsonThread{thread.Board, thread.Id(), make([]jsonPost, len(thread.Posts))}
+	for i, post := range thread.Posts {
+		t.Posts[i] = jsonPost{
+			Id:      post.Id,
+			Time:    post.Time,
+			Name:    post.Name,
+			Trip:    post.Trip,
+			Special: post.Special,
+			Capcode: post.Capcode,
+			Country: post.Country,
+			Email:   post.Email,
+			Subject: post.Subject,
+			Comment: post.CommentHTML(),
+		}
+		if f := post.File; f != nil {
+			t.Posts[i].File = &jsonFile{f.Id, f.Name, f.Ext, f.Size, f.MD5, f.Width, f.Height, f.Deleted, f.Spoiler}
+		}
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	if err = enc.Encode(t); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeGallery(path string, thread *api.Thread) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = api.WriteGallery(f, thread); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
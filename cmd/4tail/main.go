@@ -0,0 +1,170 @@
+// Command 4tail follows a 4chan thread like tail -f, printing new posts as
+// they are made.
+//
+// Usage:
+//
+//	4tail [flags] board/thread
+//
+// With -exec, the given command is run through the shell for every new post,
+// with the post's text on standard input and details about it in the
+// environment (POST_ID, POST_NAME, POST_SUBJECT, POST_BOARD and
+// POST_THREAD), which can be used for desktop notifications, e.g.
+//
+//	4tail -exec 'notify-send "/$POST_BOARD/ #$POST_ID" "$(cat)"' g/12345
+//
+// With -filter, only posts matching the query are printed; see api.ParseQuery
+// for the query syntax.
+//
+//	4tail -filter 'has:trip OR capcode:mod' g/12345
+//
+// With -format, posts are printed with a text/template instead, which is
+// given an api.Post:
+//
+//	4tail -format '{{.DisplayName}}: {{.PlainText}}{{"\n"}}' g/12345
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+var (
+	flagLines  = flag.Int("n", 10, "number of existing posts to print before following")
+	flagColor  = flag.Bool("color", true, "highlight greentext and quotes")
+	flagExec   = flag.String("exec", "", "shell command to run for every new post")
+	flagSSL    = flag.Bool("ssl", true, "use HTTPS")
+	flagFilter = flag.String("filter", "", "only show posts matching this query")
+	flagFormat = flag.String("format", "", "text/template to print each post with")
+
+	filter    *api.Query
+	formatter *api.Formatter
+)
+
+const (
+	green = "\x1b[32m"
+	red   = "\x1b[31m"
+	bold  = "\x1b[1m"
+	reset = "\x1b[0m"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] board/thread\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	api.SSL = *flagSSL
+	var err error
+	if filter, err = api.ParseQuery(*flagFilter); err != nil {
+		log.Fatal(err)
+	}
+	if *flagFormat != "" {
+		if formatter, err = api.NewFormatter(*flagFormat); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	parts := strings.Split(strings.Trim(flag.Arg(0), "/"), "/")
+	if len(parts) != 2 {
+		log.Fatalf("%q is not of the form board/thread", flag.Arg(0))
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		log.Fatalf("%q is not of the form board/thread", flag.Arg(0))
+	}
+
+	thread, err := api.GetThread(parts[0], id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start := len(thread.Posts) - *flagLines
+	if start < 0 {
+		start = 0
+	}
+	for _, post := range filter.Filter(thread.Posts[start:]) {
+		printPost(post)
+	}
+
+	for {
+		n, _, err := thread.Update()
+		if errors.Is(err, api.ErrNotFound) {
+			fmt.Println("--- thread has 404'd ---")
+			return
+		}
+		if err != nil {
+			log.Print(err)
+			time.Sleep(api.UpdateCooldown)
+			continue
+		}
+		for _, post := range filter.Filter(thread.Posts[len(thread.Posts)-n:]) {
+			printPost(post)
+			if *flagExec != "" {
+				notify(post)
+			}
+		}
+	}
+}
+
+func printPost(post *api.Post) {
+	if formatter != nil {
+		if err := formatter.FormatPost(os.Stdout, post); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	header := fmt.Sprintf("%s%s No.%d", post.Name, post.Trip, post.Id)
+	if post.Subject != "" {
+		header = post.Subject + " " + header
+	}
+	header += " " + post.Time.Format("01/02/06(Mon)15:04:05")
+	if post.File != nil {
+		header += fmt.Sprintf(" [%s%s %dx%d]", post.File.Name, post.File.Ext, post.File.Width, post.File.Height)
+	}
+	if *flagColor {
+		header = bold + header + reset
+	}
+	fmt.Println(header)
+
+	for _, line := range strings.Split(post.PlainText(), "\n") {
+		if *flagColor {
+			switch {
+			case strings.HasPrefix(line, ">>"):
+				line = red + line + reset
+			case strings.HasPrefix(line, ">"):
+				line = green + line + reset
+			}
+		}
+		fmt.Println(line)
+	}
+	fmt.Println()
+}
+
+func notify(post *api.Post) {
+	cmd := exec.Command("sh", "-c", *flagExec)
+	cmd.Stdin = strings.NewReader(post.PlainText())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"POST_ID="+strconv.FormatInt(post.Id, 10),
+		"POST_NAME="+post.Name,
+		"POST_SUBJECT="+post.Subject,
+		"POST_BOARD="+post.Thread.Board,
+		"POST_THREAD="+strconv.FormatInt(post.Thread.Id(), 10),
+	)
+	if err := cmd.Run(); err != nil {
+		log.Printf("-exec: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A fieldMask selects which fields of a response are sent, as given by the
+// fields parameter of a request: a comma-separated list of field names, with
+// fields of nested objects named by dotted paths. Lists are masked element by
+// element, so "id,posts.id,posts.file.name" selects the ID of a thread and
+// the ID and file name of each of its posts. A nil mask selects everything.
+type fieldMask map[string]fieldMask
+
+func parseFieldMask(s string) (fieldMask, error) {
+	if s == "" {
+		return nil, nil
+	}
+	mask := make(fieldMask)
+	for _, path := range strings.Split(s, ",") {
+		m := mask
+		for _, name := range strings.Split(strings.TrimSpace(path), ".") {
+			if name == "" {
+				return nil, fmt.Errorf("server: bad field %q", path)
+			}
+			if m[name] == nil {
+				m[name] = make(fieldMask)
+			}
+			m = m[name]
+		}
+	}
+	return mask, nil
+}
+
+// apply returns the value with only the selected fields left in it.
+func (self fieldMask) apply(v interface{}) interface{} {
+	if self == nil {
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep post IDs exact rather than turning them into floats
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return v
+	}
+	return self.filter(generic)
+}
+
+func (self fieldMask) filter(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			sub, ok := self[name]
+			switch {
+			case !ok:
+				delete(v, name)
+			case len(sub) > 0:
+				v[name] = sub.filter(field)
+			}
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = self.filter(elem)
+		}
+	}
+	return v
+}
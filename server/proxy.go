@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A Proxy is an http.Handler that serves the 4chan API's own endpoints, such
+// as /g/thread/123.json, from a cache shared by all of its clients. Each path
+// is fetched from upstream at most once every MaxAge, with a conditional
+// request, and concurrent requests for the same path wait for a single fetch.
+// Upstream requests go through the api package, so they are subject to its
+// rate limit and circuit breaker, which keeps the aggregate traffic of any
+// number of clients within 4chan's guidelines.
+//
+// Clients using this package can be pointed at a Proxy by setting api.APIURL
+// to its address (and api.SSL as appropriate).
+type Proxy struct {
+	// MaxAge is how long a response is served from the cache before it is
+	// checked for changes. It defaults to 10 seconds, the shortest interval
+	// 4chan allows between requests for the same thread.
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*proxyEntry
+}
+
+type proxyEntry struct {
+	sync.Mutex // held while fetching
+	body       []byte
+	modified   time.Time
+	fetched    time.Time
+	used       time.Time
+}
+
+// entries that haven't been asked for in this long are dropped
+const proxyIdle = time.Hour
+
+func (self *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("server: method %s not allowed", r.Method))
+		return
+	}
+	path := r.URL.Path
+	// only JSON endpoints are passed on, so that the proxy can't be used to
+	// fetch anything else from the server
+	if !strings.HasSuffix(path, ".json") || strings.Contains(path, "..") {
+		http.NotFound(w, r)
+		return
+	}
+
+	entry := self.entry(path)
+	entry.Lock()
+	body, modified, err := self.fetch(r, entry)
+	entry.Unlock()
+	switch {
+	case err == nil:
+	case errors.Is(err, api.ErrNotFound):
+		self.forget(path)
+		writeError(w, http.StatusNotFound, err)
+		return
+	default:
+		writeError(w, statusFor(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.Truncate(time.Second).After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(body)
+}
+
+// fetch returns the entry's body, first bringing it up to date if it is older
+// than MaxAge. The entry must be locked.
+func (self *Proxy) fetch(r *http.Request, entry *proxyEntry) ([]byte, time.Time, error) {
+	now := api.DefaultClock.Now()
+	entry.used = now
+	maxAge := self.MaxAge
+	if maxAge <= 0 {
+		maxAge = 10 * time.Second
+	}
+	if entry.body != nil && now.Sub(entry.fetched) < maxAge {
+		return entry.body, entry.modified, nil
+	}
+	body, modified, err := api.GetRaw(r.Context(), r.URL.Path, entry.modified)
+	switch {
+	case err == nil:
+		entry.body, entry.modified = body, modified
+	case errors.Is(err, api.ErrNotModified):
+	default:
+		return nil, time.Time{}, err
+	}
+	entry.fetched = now
+	return entry.body, entry.modified, nil
+}
+
+func (self *Proxy) entry(path string) *proxyEntry {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.entries == nil {
+		self.entries = make(map[string]*proxyEntry)
+	}
+	entry, ok := self.entries[path]
+	if !ok {
+		self.sweep()
+		entry = new(proxyEntry)
+		self.entries[path] = entry
+	}
+	return entry
+}
+
+// sweep drops the entries that haven't been used for a while. self.mu must be
+// held.
+func (self *Proxy) sweep() {
+	cutoff := api.DefaultClock.Now().Add(-proxyIdle)
+	for path, entry := range self.entries {
+		// entries being fetched are in use
+		if !entry.TryLock() {
+			continue
+		}
+		if entry.used.Before(cutoff) {
+			delete(self.entries, path)
+		}
+		entry.Unlock()
+	}
+}
+
+func (self *Proxy) forget(path string) {
+	self.mu.Lock()
+	delete(self.entries, path)
+	self.mu.Unlock()
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (self roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return self(req)
+}
+
+func TestProxy(t *testing.T) {
+	var upstream []*http.Request
+	defer func(c *http.Client) { api.HTTPClient = c }(api.HTTPClient)
+	api.HTTPClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		upstream = append(upstream, req)
+		status, body := http.StatusOK, `{"posts":[{"no":1,"resto":0}]}`
+		switch {
+		case req.URL.Path != "/g/thread/1.json":
+			status, body = http.StatusNotFound, ""
+		case req.Header.Get("If-Modified-Since") != "":
+			status, body = http.StatusNotModified, ""
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Last-Modified": {"Thu, 06 Sep 2012 22:38:41 GMT"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})}
+
+	proxy := &Proxy{MaxAge: time.Hour}
+	ts := httptest.NewServer(&Server{Proxy: proxy})
+	defer ts.Close()
+	get := func(path string, header http.Header) (int, string) {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	for i := 0; i < 3; i++ {
+		if code, body := get("/g/thread/1.json", nil); code != 200 || !strings.Contains(body, `"no":1`) {
+			t.Fatalf("Expected the thread, got %d %s", code, body)
+		}
+	}
+	if len(upstream) != 1 {
+		t.Fatalf("Repeated requests should be served from the cache, made %d upstream", len(upstream))
+	}
+	if code, _ := get("/g/thread/1.json", http.Header{"If-Modified-Since": {"Thu, 06 Sep 2012 22:38:41 GMT"}}); code != 304 {
+		t.Errorf("Conditional request should give 304, got %d", code)
+	}
+
+	proxy.MaxAge = time.Nanosecond
+	if code, body := get("/g/thread/1.json", nil); code != 200 || !strings.Contains(body, `"no":1`) {
+		t.Fatalf("Expected the cached thread after a 304 upstream, got %d %s", code, body)
+	}
+	if len(upstream) != 2 || upstream[1].Header.Get("If-Modified-Since") == "" {
+		t.Fatal("Stale entries should be checked with a conditional request")
+	}
+	if code, _ := get("/g/thread/2.json", nil); code != 404 {
+		t.Errorf("Missing thread should give 404, got %d", code)
+	}
+}
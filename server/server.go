@@ -0,0 +1,333 @@
+// Package server serves 4chan data over HTTP, so that many consumers can share
+// one client of the API instead of each fetching from 4chan themselves.
+//
+//	srv := &server.Server{}
+//	log.Fatal(http.ListenAndServe(":8080", srv))
+//
+// The endpoints are:
+//
+//	GET /boards                    the list of boards
+//	GET /<board>/catalog?q=<query> the OPs of the threads on a board, optionally
+//	                               filtered with an api.ParseQuery query
+//	GET /<board>/thread/<id>       a thread with all of its posts
+//	GET /<board>/thread/<id>/watch a stream of a thread's posts, one JSON
+//	                               object per line, which stays open and
+//	                               sends new posts as they are made until the
+//	                               thread 404s
+//
+// The fields parameter limits every endpoint's response to the given fields,
+// which keeps payloads small for clients that only need a few of them, e.g.
+//
+//	GET /g/catalog?fields=id,replies,posts.subject
+//	GET /g/thread/123/watch?fields=id,comment,file.name
+//
+// Fields are named by their JSON keys, with fields of nested objects and of
+// the objects in lists given as dotted paths.
+//
+// If the server is set up for it, there are also endpoints for monitoring:
+//
+//	GET /healthz                   200 if the process is working, or 503
+//	GET /status                    details of what the process is doing
+//
+// A Proxy can also be set up to serve the API's own endpoints from a shared
+// cache, for clients that speak 4chan's JSON.
+//
+// Errors are reported with the appropriate status code and a JSON body of the
+// form {"error": "..."}.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// A Server is an http.Handler serving the endpoints described in the package
+// documentation. Its fields should not be changed while it is serving.
+type Server struct {
+	// Source is where boards and threads are fetched from. It defaults to
+	// api.Live.
+	Source api.Source
+	// WatchInterval is how often watched threads are checked for new
+	// posts. It defaults to api.UpdateCooldown. A random amount of up to
+	// WatchJitter is added to each wait, so that many clients watching at
+	// once don't all cause requests at the same moment.
+	WatchInterval time.Duration
+	WatchJitter   time.Duration
+	// If Proxy is set, requests for the API's own endpoints, whose paths
+	// end in .json, are passed on to it.
+	Proxy *Proxy
+	// If Health is set, GET /healthz reports whether the process is
+	// working, for load balancers and monitoring: it responds with 200 if
+	// Health returns nil, and 503 with the error otherwise. If Status is
+	// set, GET /status serves what it returns as JSON. An
+	// archiver.Archiver's Healthy and Status methods can be used here.
+	Health func() error
+	Status func() interface{}
+}
+
+// A Thread is a thread as it is sent to clients.
+type Thread struct {
+	Board    string  `json:"board"`
+	Id       int64   `json:"id"`
+	Replies  int     `json:"replies"`
+	Images   int     `json:"images"`
+	Sticky   bool    `json:"sticky,omitempty"`
+	Closed   bool    `json:"closed,omitempty"`
+	Archived bool    `json:"archived,omitempty"`
+	Posts    []*Post `json:"posts"`
+}
+
+// A Post is a post as it is sent to clients. Comment is 4chan's HTML.
+type Post struct {
+	Id      int64     `json:"id"`
+	Time    time.Time `json:"time"`
+	Name    string    `json:"name,omitempty"`
+	Trip    string    `json:"trip,omitempty"`
+	Special string    `json:"special,omitempty"`
+	Capcode string    `json:"capcode,omitempty"`
+	Country string    `json:"country,omitempty"`
+	Email   string    `json:"email,omitempty"`
+	Subject string    `json:"subject,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	File    *File     `json:"file,omitempty"`
+}
+
+// A File is a post's file as it is sent to clients.
+type File struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`
+	Ext     string `json:"ext"`
+	Size    int    `json:"size"`
+	MD5     []byte `json:"md5"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Spoiler bool   `json:"spoiler,omitempty"`
+}
+
+func newThread(t *api.Thread) *Thread {
+	thread := &Thread{
+		Board:    t.Board,
+		Id:       t.Id(),
+		Replies:  t.Replies(),
+		Images:   t.Images(),
+		Sticky:   t.Sticky(),
+		Closed:   t.Closed(),
+		Archived: t.Archived(),
+		Posts:    make([]*Post, len(t.Posts)),
+	}
+	for i, post := range t.Posts {
+		thread.Posts[i] = newPost(post)
+	}
+	return thread
+}
+
+func newPost(p *api.Post) *Post {
+	post := &Post{
+		Id:      p.Id,
+		Time:    p.Time,
+		Name:    p.Name,
+		Trip:    p.Trip,
+		Special: p.Special,
+		Capcode: p.Capcode,
+		Country: p.Country,
+		Email:   p.Email,
+		Subject: p.Subject,
+		Comment: p.CommentHTML(),
+	}
+	if f := p.File; f != nil {
+		post.File = &File{
+			Id:      f.Id,
+			Name:    f.Name,
+			Ext:     f.Ext,
+			Size:    f.Size,
+			MD5:     f.MD5,
+			Width:   f.Width,
+			Height:  f.Height,
+			Deleted: f.Deleted,
+			Spoiler: f.Spoiler,
+		}
+	}
+	return post
+}
+
+func (self *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("server: method %s not allowed", r.Method))
+		return
+	}
+	if self.Proxy != nil && strings.HasSuffix(r.URL.Path, ".json") {
+		self.Proxy.ServeHTTP(w, r)
+		return
+	}
+	mask, err := parseFieldMask(r.FormValue("fields"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "boards":
+		self.serveBoards(w, r, mask)
+	case len(parts) == 1 && parts[0] == "healthz" && self.Health != nil:
+		self.serveHealth(w, r)
+	case len(parts) == 1 && parts[0] == "status" && self.Status != nil:
+		writeJSON(w, mask.apply(self.Status()))
+	case len(parts) == 2 && parts[1] == "catalog":
+		self.serveCatalog(w, r, mask, parts[0])
+	case len(parts) >= 3 && len(parts) <= 4 && parts[1] == "thread":
+		id, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("server: bad thread ID %q", parts[2]))
+			return
+		}
+		switch {
+		case len(parts) == 3:
+			self.serveThread(w, r, mask, parts[0], id)
+		case parts[3] == "watch":
+			self.serveWatch(w, r, mask, parts[0], id)
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (self *Server) serveBoards(w http.ResponseWriter, r *http.Request, mask fieldMask) {
+	boards, err := self.source().GetBoards()
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, mask.apply(boards))
+}
+
+func (self *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if err := self.Health(); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+func (self *Server) serveCatalog(w http.ResponseWriter, r *http.Request, mask fieldMask, board string) {
+	filter, err := api.ParseQuery(r.FormValue("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cat, err := self.source().GetCatalog(board)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	threads := []*Thread{}
+	for _, page := range cat {
+		for _, thread := range page.Threads {
+			if thread.OP != nil && filter.Match(thread.OP) {
+				threads = append(threads, newThread(thread))
+			}
+		}
+	}
+	writeJSON(w, mask.apply(threads))
+}
+
+func (self *Server) serveThread(w http.ResponseWriter, r *http.Request, mask fieldMask, board string, id int64) {
+	thread, err := self.source().GetThread(board, id)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, mask.apply(newThread(thread)))
+}
+
+// serveWatch streams the posts of a thread, checking for new ones every
+// WatchInterval until the thread 404s or the client goes away.
+func (self *Server) serveWatch(w http.ResponseWriter, r *http.Request, mask fieldMask, board string, id int64) {
+	thread, err := self.source().GetThread(board, id)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	var last int64
+	send := func(thread *api.Thread) error {
+		for _, post := range thread.Posts {
+			if post.Id <= last {
+				continue
+			}
+			if err := enc.Encode(mask.apply(newPost(post))); err != nil {
+				return err
+			}
+			last = post.Id
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	interval := self.WatchInterval
+	if interval <= 0 {
+		interval = api.UpdateCooldown
+	}
+	for {
+		if err := send(thread); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-api.DefaultClock.After(interval + api.Jitter(self.WatchJitter)):
+		}
+		next, err := self.source().GetThread(board, id)
+		switch {
+		case err == nil:
+			thread = next
+		case errors.Is(err, api.ErrNotFound):
+			return
+		default:
+			// the stream has already started, so the best that can be done
+			// is to try again next time
+		}
+	}
+}
+
+func (self *Server) source() api.Source {
+	if self.Source == nil {
+		return api.Live
+	}
+	return self.Source
+}
+
+// statusFor picks the status code to report an error from the API with.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, api.ErrNotFound), errors.Is(err, api.ErrBoardNotFound), errors.Is(err, api.ErrEmptyThread):
+		return http.StatusNotFound
+	case errors.Is(err, api.ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
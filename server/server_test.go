@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moshee/go-4chan-api/api"
+)
+
+// fakeSource serves threads from JSON documents that the test can change.
+type fakeSource struct {
+	sync.Mutex
+	threads map[int64]string
+}
+
+func (self *fakeSource) set(id int64, doc string) {
+	self.Lock()
+	defer self.Unlock()
+	if doc == "" {
+		delete(self.threads, id)
+	} else {
+		self.threads[id] = doc
+	}
+}
+
+func (self *fakeSource) GetThread(board string, id int64) (*api.Thread, error) {
+	self.Lock()
+	doc, ok := self.threads[id]
+	self.Unlock()
+	if !ok {
+		return nil, api.ErrNotFound
+	}
+	return api.ParseThread(strings.NewReader(doc), board)
+}
+
+func (self *fakeSource) GetCatalog(board string) (api.Catalog, error) {
+	cat := api.Catalog{{Page: 1}}
+	for _, id := range []int64{1, 5} {
+		thread, err := self.GetThread(board, id)
+		if err != nil {
+			continue
+		}
+		cat[0].Threads = append(cat[0].Threads, thread)
+	}
+	return cat, nil
+}
+
+func (self *fakeSource) GetIndex(board string, page int) ([]*api.Thread, error) {
+	return nil, api.ErrNotFound
+}
+
+func (self *fakeSource) GetBoards() ([]api.Board, error) {
+	return []api.Board{{Board: "g", Title: "Technology"}}, nil
+}
+
+func newTestServer() (*httptest.Server, *fakeSource) {
+	src := &fakeSource{threads: map[int64]string{
+		1: `{"posts":[{"no":1,"resto":0,"sub":"rust","replies":1},{"no":2,"resto":1,"com":"hi"}]}`,
+		5: `{"posts":[{"no":5,"resto":0,"sub":"go"}]}`,
+	}}
+	return httptest.NewServer(&Server{Source: src, WatchInterval: 10 * time.Millisecond}), src
+}
+
+func getJSON(t *testing.T, url string, v interface{}) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	ts, _ := newTestServer()
+	defer ts.Close()
+
+	var thread Thread
+	if code := getJSON(t, ts.URL+"/g/thread/1", &thread); code != 200 {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if thread.Id != 1 || thread.Replies != 1 || len(thread.Posts) != 2 || thread.Posts[1].Comment != "hi" {
+		t.Errorf("Unexpected thread %+v", thread)
+	}
+
+	var threads []Thread
+	getJSON(t, ts.URL+"/g/catalog?q=subject:go", &threads)
+	if len(threads) != 1 || threads[0].Id != 5 {
+		t.Errorf("Catalog search should only find thread 5, got %+v", threads)
+	}
+
+	var e map[string]string
+	if code := getJSON(t, ts.URL+"/g/thread/3", &e); code != 404 || e["error"] == "" {
+		t.Errorf("Missing thread should give 404 with an error, got %d %v", code, e)
+	}
+	if code := getJSON(t, ts.URL+"/g/catalog?q=(", &e); code != 400 {
+		t.Errorf("Bad query should give 400, got %d", code)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ts, src := newTestServer()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/g/thread/1/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	next := func() int64 {
+		if !lines.Scan() {
+			t.Fatalf("Stream ended early: %v", lines.Err())
+		}
+		var post Post
+		if err := json.Unmarshal(lines.Bytes(), &post); err != nil {
+			t.Fatal(err)
+		}
+		return post.Id
+	}
+
+	if a, b := next(), next(); a != 1 || b != 2 {
+		t.Fatalf("Stream should start with the existing posts, got %d, %d", a, b)
+	}
+	src.set(1, `{"posts":[{"no":1,"resto":0},{"no":2,"resto":1},{"no":3,"resto":1}]}`)
+	if id := next(); id != 3 {
+		t.Fatalf("Stream should send the new post, got %d", id)
+	}
+	src.set(1, "")
+	if lines.Scan() {
+		t.Fatalf("Stream should end when the thread 404s, got %s", lines.Text())
+	}
+}
+
+func TestFields(t *testing.T) {
+	ts, _ := newTestServer()
+	defer ts.Close()
+
+	var thread map[string]interface{}
+	getJSON(t, ts.URL+"/g/thread/1?fields=id,posts.id,posts.comment", &thread)
+	if len(thread) != 2 || thread["id"] != 1.0 {
+		t.Fatalf("Expected only the thread's id and posts, got %v", thread)
+	}
+	posts := thread["posts"].([]interface{})
+	if len(posts) != 2 {
+		t.Fatalf("Expected both posts, got %v", posts)
+	}
+	for _, post := range posts {
+		for name := range post.(map[string]interface{}) {
+			if name != "id" && name != "comment" {
+				t.Errorf("Unexpected field %q in %v", name, post)
+			}
+		}
+	}
+	if comment := posts[1].(map[string]interface{})["comment"]; comment != "hi" {
+		t.Errorf("Expected the comment to be kept, got %v", comment)
+	}
+
+	var e map[string]string
+	if code := getJSON(t, ts.URL+"/g/thread/1?fields=id,,posts", &e); code != 400 {
+		t.Errorf("Bad field mask should give 400, got %d", code)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	var health error
+	srv := &Server{
+		Health: func() error { return health },
+		Status: func() interface{} { return map[string]int{"following": 3} },
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	var v map[string]interface{}
+	if code := getJSON(t, ts.URL+"/healthz", &v); code != 200 || v["status"] != "ok" {
+		t.Errorf("Expected 200 ok, got %d %v", code, v)
+	}
+	health = errors.New("stuck")
+	if code := getJSON(t, ts.URL+"/healthz", &v); code != 503 || v["error"] != "stuck" {
+		t.Errorf("Expected 503 with the error, got %d %v", code, v)
+	}
+	if code := getJSON(t, ts.URL+"/status", &v); code != 200 || v["following"] != 3.0 {
+		t.Errorf("Unexpected status %d %v", code, v)
+	}
+
+	// the endpoints are only there if they're set up
+	plain, _ := newTestServer()
+	defer plain.Close()
+	for _, path := range []string{"/healthz", "/status"} {
+		resp, err := http.Get(plain.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 404 {
+			t.Errorf("%s: expected 404, got %d", path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package trip implements 4chan's tripcodes.
+//
+// A tripcode is computed from a password given in the name field after a #,
+// as in "Anonymous#password", and shows up after the name as !Ep8pui8Vw2. The
+// same password always gives the same tripcode, so it can be used to prove
+// who made a post without registering. Secure tripcodes (##password) are
+// computed with a secret kept on the server and can't be generated or
+// verified here.
+package trip
+
+import (
+	"context"
+	"crypto/rand"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Tripcode computes the tripcode for a password, as 4chan would show it
+// (e.g. !Ep8pui8Vw2).
+//
+// 4chan converts passwords to Shift JIS before hashing them. This package
+// only has the standard library to work with, so passwords containing
+// characters outside of ASCII are hashed as UTF-8 instead and will give
+// different tripcodes than the site does.
+func Tripcode(password string) string {
+	pw := escaper.Replace(password)
+	salt := []byte(pw + "H..")[1:3]
+	for i, c := range salt {
+		switch {
+		case c < '.' || c > 'z':
+			salt[i] = '.'
+		case c >= ':' && c <= '@':
+			salt[i] = c - ':' + 'A'
+		case c >= '[' && c <= '`':
+			salt[i] = c - '[' + 'a'
+		}
+	}
+	hash := Crypt(pw, string(salt))
+	return "!" + hash[len(hash)-10:]
+}
+
+// 4chan escapes HTML special characters in the password before hashing it
+var escaper = strings.NewReplacer(
+	"&", "&amp;",
+	`"`, "&quot;",
+	"'", "&#39;",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+// Verify reports whether password gives the tripcode trip. The leading ! of
+// trip is optional.
+func Verify(password, trip string) bool {
+	return Tripcode(password) == "!"+strings.TrimPrefix(trip, "!")
+}
+
+// Split separates a name field as it would be typed when posting into the
+// name and the tripcode it produces: "moot#faggot" gives "moot" and
+// "!Ep8pui8Vw2". If there is no password, trip is empty. Secure tripcode
+// passwords (after ##) are dropped, since only the server can compute them.
+func Split(field string) (name, trip string) {
+	i := strings.IndexByte(field, '#')
+	if i < 0 {
+		return field, ""
+	}
+	name, password := field[:i], field[i+1:]
+	if j := strings.Index(password, "##"); j >= 0 {
+		password = password[:j]
+	} else if strings.HasPrefix(password, "#") {
+		return name, ""
+	}
+	if password == "" {
+		return name, ""
+	}
+	return name, Tripcode(password)
+}
+
+// Vanity searches for a password whose tripcode satisfies match, trying
+// random passwords on every CPU until one is found or ctx is done. match is
+// given tripcodes without the leading ! and may be called concurrently.
+func Vanity(ctx context.Context, match func(trip string) bool) (password, trip string, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once  sync.Once
+		wg    sync.WaitGroup
+		found [2]string
+	)
+	for n := runtime.NumCPU(); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 8)
+			for ctx.Err() == nil {
+				pw := randomPassword(buf)
+				if t := Tripcode(pw); match(t[1:]) {
+					once.Do(func() {
+						found = [2]string{pw, t}
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if found[0] == "" {
+		return "", "", ctx.Err()
+	}
+	return found[0], found[1], nil
+}
+
+// passwordChars are the characters random passwords are made of. They are
+// all left alone by the HTML escaping and Shift JIS conversion, so the
+// tripcodes found are the same as the site's.
+const passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789./"
+
+func randomPassword(buf []byte) string {
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	for i, b := range buf {
+		buf[i] = passwordChars[int(b)%len(passwordChars)]
+	}
+	return string(buf)
+}
@@ -0,0 +1,72 @@
+package trip
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generated with perl's crypt, escaping and salting the way 4chan does
+var tripcodes = []struct{ password, trip string }{
+	{"faggot", "!Ep8pui8Vw2"},
+	{"tea", "!WokonZwxw2"},
+	{"a", "!ZnBI2EKkq."},
+	{"", "!8NBuQ4l6uQ"},
+	{`hello&"`, "!RaHLyVMzrY"},
+	{"longpassword1", "!XK69x/vEPo"},
+	{":;<=>?@", "!djbe4UiURM"},
+	{"[\\]^_`{", "!tCHiNaozl."},
+}
+
+func TestTripcode(t *testing.T) {
+	for _, c := range tripcodes {
+		if got := Tripcode(c.password); got != c.trip {
+			t.Errorf("Tripcode(%q) = %s, expected %s", c.password, got, c.trip)
+		}
+	}
+}
+
+func TestCrypt(t *testing.T) {
+	if got := Crypt("faggot", "ag"); got != "agPEp8pui8Vw2" {
+		t.Errorf("Crypt(faggot, ag) = %s", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if !Verify("faggot", "Ep8pui8Vw2") || !Verify("faggot", "!Ep8pui8Vw2") {
+		t.Error("The right password should verify")
+	}
+	if Verify("faggit", "!Ep8pui8Vw2") {
+		t.Error("The wrong password should not verify")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	cases := []struct{ field, name, trip string }{
+		{"moot#faggot", "moot", "!Ep8pui8Vw2"},
+		{"#faggot##secure", "", "!Ep8pui8Vw2"},
+		{"moot##secure", "moot", ""},
+		{"moot#", "moot", ""},
+		{"moot", "moot", ""},
+	}
+	for _, c := range cases {
+		if name, trip := Split(c.field); name != c.name || trip != c.trip {
+			t.Errorf("Split(%q) = %q, %q, expected %q, %q", c.field, name, trip, c.name, c.trip)
+		}
+	}
+}
+
+func TestVanity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	password, trip, err := Vanity(ctx, func(trip string) bool {
+		return strings.HasPrefix(strings.ToLower(trip), "a")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(strings.ToLower(trip), "!a") || Tripcode(password) != trip {
+		t.Errorf("Vanity gave %q for %q", trip, password)
+	}
+}